@@ -0,0 +1,58 @@
+// Local receiver of updater webhooks, verifies signatures and prints events:
+//
+//	go run ./cmd/ipinfo_webhook_receiver -addr :9090 -secret <secret of subscription>
+//
+// Non 2xx status can be answered to check retries and dead letters:
+//
+//	go run ./cmd/ipinfo_webhook_receiver -secret <secret> -status 500
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/webhook"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "listen address")
+	secret := flag.String("secret", "", "secret of subscription, empty to skip verification")
+	tolerance := flag.Duration("tolerance", 5*time.Minute, "max age of signature timestamp, 0 to skip check")
+	status := flag.Int("status", http.StatusNoContent, "status answered to valid deliveries")
+	flag.Parse()
+
+	http.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if *secret != "" {
+			err = webhook.VerifySignature(*secret, r.Header.Get(webhook.SignatureHeader), body, time.Now(), *tolerance)
+			if err != nil {
+				log.Printf("rejected delivery %s: %s", r.Header.Get(webhook.DeliveryHeader), err)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		var indented bytes.Buffer
+		if err = json.Indent(&indented, body, "", "  "); err != nil {
+			indented.Write(body)
+		}
+		log.Printf("delivery %s, event %s, answered %d\n%s", r.Header.Get(webhook.DeliveryHeader), r.Header.Get(webhook.EventHeader), *status, indented.String())
+		w.WriteHeader(*status)
+	})
+
+	log.Printf("listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, nil); err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+}
@@ -6,4 +6,9 @@ toolchain go1.24.9
 
 require github.com/joho/godotenv v1.5.1
 
-require github.com/lib/pq v1.10.9
+require (
+	github.com/lib/pq v1.10.9
+	github.com/oschwald/maxminddb-golang v1.13.1
+)
+
+require golang.org/x/sys v0.21.0 // indirect
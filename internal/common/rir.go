@@ -0,0 +1,46 @@
+package common
+
+// Registry with own tables, DbName is its name in rirs table. Domain, PathName and FileName
+// are parts of url and file name of its official feed.
+type Rir struct {
+	Domain   string
+	PathName string
+	FileName string
+	DbName   string
+}
+
+func NewRir(domain, pathName, fileName, dbName string) *Rir {
+	return &Rir{
+		Domain:   domain,
+		PathName: pathName,
+		FileName: fileName,
+		DbName:   dbName,
+	}
+}
+
+var Rirs = [5]*Rir{
+	NewRir("arin", "arin", "arin-extended", "arin"),
+	NewRir("apnic", "apnic", "apnic-extended", "apnic"),
+	NewRir("afrinic", "afrinic", "afrinic-extended", "afrinic"),
+	NewRir("lacnic", "lacnic", "lacnic-extended", "lacnic"),
+	NewRir("ripe", "ripencc", "ripencc-extended", "ripencc"),
+}
+
+func FindRirByDbName(name string) int {
+	for i, rir := range Rirs {
+		if rir.DbName == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Names of Rirs as they are stored and accepted by api
+func RirNames() []string {
+	names := make([]string, len(Rirs))
+	for i, rir := range Rirs {
+		names[i] = rir.DbName
+	}
+	return names
+}
@@ -3,6 +3,8 @@ package common
 import (
 	"context"
 	"database/sql"
+	"iter"
+	"math/big"
 )
 
 type Storage interface {
@@ -11,16 +13,44 @@ type Storage interface {
 
 	UpdateOption(name, value string, ctx context.Context) error
 	GetOption(name string, ctx context.Context) (string, error)
-	UpdateRirData(rirTableName string, ip_ranges []IpRange, ctx context.Context) error
+	// Data is replaced only when all records are read without error
+	UpdateRirData(rirTableName, serial string, records iter.Seq2[RirRecord, error], ctx context.Context) (*RirDataChanges, error)
+}
+
+// Ranges changed by upload compared with previous data of rir
+type RirDataChanges struct {
+	SnapshotId int64 `json:"snapshotId"`
+	// First upload of rir, changes are not recorded
+	Baseline bool   `json:"baseline,omitempty"`
+	Added    uint64 `json:"added"`
+	Removed  uint64 `json:"removed"`
+	Changed  uint64 `json:"changed"`
+}
+
+// Record of registry data, exactly one of ranges is set
+type RirRecord struct {
+	IpRange  *IpRange
+	AsnRange *AsnRange
 }
 
 type IpRange struct {
-	RirId           int
 	CountryCode     string
 	IpVersionId     int
 	StartIp         string
 	EndIp           string
+	Quantity        *big.Int
+	PrefixLength    sql.NullInt32
+	StatusId        int
+	StatusChangedAt sql.NullTime
+	OpaqueId        sql.NullString
+}
+
+type AsnRange struct {
+	CountryCode     string
+	StartAsn        uint32
+	EndAsn          uint64
 	Quantity        uint64
 	StatusId        int
 	StatusChangedAt sql.NullTime
+	OpaqueId        sql.NullString
 }
@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+type AsnRepository interface {
+	GetAsn(asn uint32) (*entity.AsnInfo, error)
+	GetAsnAt(asn uint32, at time.Time) (*entity.AsnInfo, error)
+}
+
+type Asn struct {
+	Db database.Database
+}
+
+func (p *Asn) GetAsn(asn uint32) (*entity.AsnInfo, error) {
+	row, err := p.Db.GetAsnInfo(asn)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, nil
+	}
+	asnInfo := newAsnInfo(row)
+	asnInfo.Asn = asn
+	return asnInfo, nil
+}
+
+func (p *Asn) GetAsnAt(asn uint32, at time.Time) (*entity.AsnInfo, error) {
+	row, err := p.Db.GetAsnInfoAt(asn, at)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, nil
+	}
+	asnInfo := newAsnInfo(row)
+	asnInfo.Asn = asn
+	return asnInfo, nil
+}
+
+func newAsnInfo(row *database.AsnInfoRow) *entity.AsnInfo {
+	return &entity.AsnInfo{
+		RirName:          row.RirName,
+		CountryCode:      row.CountryCode,
+		AsnRangeStart:    row.AsnRangeStart,
+		AsnRangeEnd:      row.AsnRangeEnd,
+		AsnRangeQuantity: row.AsnRangeQuantity,
+		Status:           row.Status,
+		StatusUpdatedAt:  row.StatusUpdatedAt,
+		HolderId:         row.HolderId,
+	}
+}
+
+func NewAsnRepository(db database.Database) *Asn {
+	return &Asn{
+		Db: db,
+	}
+}
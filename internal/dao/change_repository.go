@@ -0,0 +1,68 @@
+package dao
+
+import (
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+type ChangeRepository interface {
+	GetChanges(query *entity.ChangeQuery) ([]*entity.RangeChange, error)
+}
+
+type Change struct {
+	Db database.Database
+}
+
+// Nil when since serial is unknown
+func (p *Change) GetChanges(query *entity.ChangeQuery) ([]*entity.RangeChange, error) {
+	filter := &database.RangeChangeFilter{
+		RirName:    query.RirName,
+		SinceTime:  query.SinceTime,
+		SnapshotId: query.SnapshotId,
+		After:      query.After,
+		Limit:      query.Limit,
+	}
+	if query.SinceSerial != "" {
+		snapshotId, err := p.Db.GetRirSnapshotId(query.RirName, query.SinceSerial)
+		if err != nil {
+			return nil, err
+		}
+		if snapshotId == 0 {
+			return nil, nil
+		}
+		filter.SinceSnapshotId = snapshotId
+	}
+
+	rows, err := p.Db.GetRangeChanges(filter)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]*entity.RangeChange, len(rows))
+	for i, row := range rows {
+		changes[i] = newRangeChange(row)
+	}
+	return changes, nil
+}
+
+func newRangeChange(row *database.RangeChangeRow) *entity.RangeChange {
+	return &entity.RangeChange{
+		Id:             row.Id,
+		RirName:        row.RirName,
+		Serial:         row.Serial,
+		PublishedAt:    row.PublishedAt,
+		Kind:           row.Kind,
+		RangeType:      row.RangeType,
+		RangeStart:     row.RangeStart,
+		RangeEnd:       row.RangeEnd,
+		OldCountryCode: row.OldCountryCode,
+		NewCountryCode: row.NewCountryCode,
+		OldStatus:      row.OldStatus,
+		NewStatus:      row.NewStatus,
+	}
+}
+
+func NewChangeRepository(db database.Database) *Change {
+	return &Change{
+		Db: db,
+	}
+}
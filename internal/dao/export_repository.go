@@ -0,0 +1,64 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+	"github.com/KeilWin/ipinfo/internal/export"
+)
+
+type ExportRepository interface {
+	GetDataVersion(ctx context.Context) (string, error)
+	// Ranges are returned with data version they are loaded for
+	GetIpRanges(ctx context.Context) (string, []*export.Range, error)
+}
+
+// Ranges are kept in memory until data version changes
+type Export struct {
+	Db database.Database
+
+	mu      sync.Mutex
+	version string
+	ranges  []*export.Range
+}
+
+func (p *Export) GetDataVersion(ctx context.Context) (string, error) {
+	version, err := getDataVersion(p.Db, ctx)
+	if err != nil {
+		return "", fmt.Errorf("get data version: %w", err)
+	}
+	return version, nil
+}
+
+func (p *Export) GetIpRanges(ctx context.Context) (string, []*export.Range, error) {
+	version, err := p.GetDataVersion(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ranges != nil && p.version == version {
+		return version, p.ranges, nil
+	}
+	rows, err := p.Db.GetIpRanges(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("get ip ranges: %w", err)
+	}
+	ranges, err := export.NewRanges(rows)
+	if err != nil {
+		return "", nil, err
+	}
+	p.version, p.ranges = version, ranges
+	slog.Info("export ranges loaded", "version", version, "ranges", len(ranges))
+	return version, ranges, nil
+}
+
+func NewExportRepository(db database.Database) *Export {
+	return &Export{
+		Db: db,
+	}
+}
@@ -0,0 +1,47 @@
+package dao
+
+import (
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+type HolderRepository interface {
+	GetHolder(opaqueId string) (*entity.HolderInfo, error)
+}
+
+type Holder struct {
+	Db database.Database
+}
+
+func (p *Holder) GetHolder(opaqueId string) (*entity.HolderInfo, error) {
+	ipRows, err := p.Db.GetHolderIpRanges(opaqueId)
+	if err != nil {
+		return nil, err
+	}
+	asnRows, err := p.Db.GetHolderAsnRanges(opaqueId)
+	if err != nil {
+		return nil, err
+	}
+	if len(ipRows) == 0 && len(asnRows) == 0 {
+		return nil, nil
+	}
+
+	holderInfo := &entity.HolderInfo{
+		HolderId:  opaqueId,
+		IpRanges:  make([]*entity.IpRangeInfo, len(ipRows)),
+		AsnRanges: make([]*entity.AsnInfo, len(asnRows)),
+	}
+	for i, row := range ipRows {
+		holderInfo.IpRanges[i] = newIpRangeInfo(row)
+	}
+	for i, row := range asnRows {
+		holderInfo.AsnRanges[i] = newAsnInfo(row)
+	}
+	return holderInfo, nil
+}
+
+func NewHolderRepository(db database.Database) *Holder {
+	return &Holder{
+		Db: db,
+	}
+}
@@ -0,0 +1,45 @@
+package dao
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/KeilWin/ipinfo/internal/dto/cache"
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+// Read-through cache of single database lookups, in-memory index is not put behind it
+type CachedIpAddress struct {
+	IpAddressRepository
+
+	Cache cache.Cache
+}
+
+func (p *CachedIpAddress) GetIpAddress(ipAddress string) (*entity.IpAddressInfo, error) {
+	ctx := context.Background()
+
+	// Cache failures must not break lookups, fall back to repository
+	ipAddressInfo, err := p.Cache.GetIpInfo(ipAddress, ctx)
+	if err != nil {
+		slog.Warn("can't get ip address info from cache", "ipAddress", ipAddress, "err", err)
+	} else if ipAddressInfo != nil {
+		ipAddressInfo.IpAddress = ipAddress
+		return ipAddressInfo, nil
+	}
+
+	ipAddressInfo, err = p.IpAddressRepository.GetIpAddress(ipAddress)
+	if err != nil || ipAddressInfo == nil {
+		return ipAddressInfo, err
+	}
+	if err = p.Cache.AddIpInfo(ipAddressInfo, ctx); err != nil {
+		slog.Warn("can't add ip address info to cache", "ipAddress", ipAddress, "err", err)
+	}
+	return ipAddressInfo, nil
+}
+
+func NewCachedIpAddressRepository(repository IpAddressRepository, cache cache.Cache) *CachedIpAddress {
+	return &CachedIpAddress{
+		IpAddressRepository: repository,
+		Cache:               cache,
+	}
+}
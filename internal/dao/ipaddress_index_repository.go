@@ -0,0 +1,115 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/index"
+)
+
+// Answers lookups from in-memory index, database stays source of truth and is used until index is loaded
+type IndexedIpAddress struct {
+	IpAddress
+
+	// Lookups until index is loaded
+	Fallback IpAddressRepository
+	index    atomic.Pointer[index.IpRangeIndex]
+}
+
+func (p *IndexedIpAddress) GetIpAddress(ipAddress string) (*entity.IpAddressInfo, error) {
+	idx := p.index.Load()
+	if idx == nil {
+		return p.Fallback.GetIpAddress(ipAddress)
+	}
+	addr, err := netip.ParseAddr(ipAddress)
+	if err != nil {
+		return nil, fmt.Errorf("parse ip address: %w", err)
+	}
+	row := idx.Lookup(addr)
+	if row == nil {
+		return nil, nil
+	}
+	return newIpAddressInfo(ipAddress, row), nil
+}
+
+func (p *IndexedIpAddress) GetIpAddresses(ipAddresses []string) ([]*entity.IpAddressInfo, error) {
+	idx := p.index.Load()
+	if idx == nil {
+		return p.Fallback.GetIpAddresses(ipAddresses)
+	}
+	ipAddressInfos := make([]*entity.IpAddressInfo, len(ipAddresses))
+	for i, ipAddress := range ipAddresses {
+		addr, err := netip.ParseAddr(ipAddress)
+		if err != nil {
+			return nil, fmt.Errorf("parse ip address: %w", err)
+		}
+		if row := idx.Lookup(addr); row != nil {
+			ipAddressInfos[i] = newIpAddressInfo(ipAddress, row)
+		}
+	}
+	return ipAddressInfos, nil
+}
+
+func getDataVersion(db database.Database, ctx context.Context) (string, error) {
+	version, err := db.GetOption(database.DataVersionOptionName, ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return version, err
+}
+
+// Rebuilds index when data version changed, readers keep using previous index until new one is ready
+func (p *IndexedIpAddress) Refresh(ctx context.Context) error {
+	version, err := getDataVersion(p.Db, ctx)
+	if err != nil {
+		return fmt.Errorf("get data version: %w", err)
+	}
+	if idx := p.index.Load(); idx != nil && idx.Version == version {
+		return nil
+	}
+
+	startedAt := time.Now()
+	rows, err := p.Db.GetIpRanges(ctx)
+	if err != nil {
+		return fmt.Errorf("get ip ranges: %w", err)
+	}
+	idx, err := index.NewIpRangeIndex(version, rows)
+	if err != nil {
+		return fmt.Errorf("build index: %w", err)
+	}
+	p.index.Store(idx)
+	slog.Info("index rebuilt", "version", version, "ranges", idx.Len(), "duration", time.Since(startedAt).String())
+	return nil
+}
+
+func (p *IndexedIpAddress) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Refresh(ctx); err != nil {
+				slog.Error("refresh index", "error", err)
+			}
+		}
+	}
+}
+
+func NewIndexedIpAddressRepository(db database.Database, fallback IpAddressRepository) *IndexedIpAddress {
+	return &IndexedIpAddress{
+		IpAddress: IpAddress{
+			Db: db,
+		},
+		Fallback: fallback,
+	}
+}
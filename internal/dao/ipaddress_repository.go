@@ -1,12 +1,18 @@
 package dao
 
 import (
+	"time"
+
 	"github.com/KeilWin/ipinfo/internal/dto/database"
 	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/utils"
 )
 
 type IpAddressRepository interface {
 	GetIpAddress(ipAddress string) (*entity.IpAddressInfo, error)
+	GetIpAddressAt(ipAddress string, at time.Time) (*entity.IpAddressInfo, error)
+	GetIpAddresses(ipAddresses []string) ([]*entity.IpAddressInfo, error)
+	GetIpRanges(query *entity.PrefixQuery) ([]*entity.IpRangeInfo, error)
 }
 
 type IpAddress struct {
@@ -21,6 +27,70 @@ func (p *IpAddress) GetIpAddress(ipAddress string) (*entity.IpAddressInfo, error
 	if addr == nil {
 		return nil, nil
 	}
+	return newIpAddressInfo(ipAddress, addr), nil
+}
+
+func (p *IpAddress) GetIpAddressAt(ipAddress string, at time.Time) (*entity.IpAddressInfo, error) {
+	addr, err := p.Db.GetIpInfoAt(ipAddress, at)
+	if err != nil {
+		return nil, err
+	}
+	if addr == nil {
+		return nil, nil
+	}
+	return newIpAddressInfo(ipAddress, addr), nil
+}
+
+func (p *IpAddress) GetIpAddresses(ipAddresses []string) ([]*entity.IpAddressInfo, error) {
+	addrs, err := p.Db.GetIpInfoBatch(ipAddresses)
+	if err != nil {
+		return nil, err
+	}
+	ipAddressInfos := make([]*entity.IpAddressInfo, len(ipAddresses))
+	for i, addr := range addrs {
+		if addr != nil {
+			ipAddressInfos[i] = newIpAddressInfo(ipAddresses[i], addr)
+		}
+	}
+	return ipAddressInfos, nil
+}
+
+func (p *IpAddress) GetIpRanges(query *entity.PrefixQuery) ([]*entity.IpRangeInfo, error) {
+	var after *database.IpRangeKey
+	if query.After != nil {
+		after = &database.IpRangeKey{
+			StartIp: query.After.Start.String(),
+			EndIp:   query.After.End.String(),
+			RirName: query.After.RirName,
+		}
+	}
+	addrs, err := p.Db.GetIpRangesOverlapping(query.Prefix.Addr().String(), utils.LastAddr(query.Prefix).String(), after, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+	ipRangeInfos := make([]*entity.IpRangeInfo, len(addrs))
+	for i, addr := range addrs {
+		ipRangeInfos[i] = newIpRangeInfo(addr)
+	}
+	return ipRangeInfos, nil
+}
+
+func newIpRangeInfo(addr *database.IpAddressInfoRow) *entity.IpRangeInfo {
+	return &entity.IpRangeInfo{
+		RirName:          addr.RirName,
+		IpAddressVersion: addr.IpAddressVersion,
+		CountryCode:      addr.CountryCode,
+		IpRangeStart:     addr.IpRangeStart,
+		IpRangeEnd:       addr.IpRangeEnd,
+		IpRangeQuantity:  addr.IpRangeQuantity,
+		IpRangePrefix:    addr.IpRangePrefix,
+		Status:           addr.Status,
+		StatusUpdatedAt:  addr.StatusUpdatedAt,
+		HolderId:         addr.HolderId,
+	}
+}
+
+func newIpAddressInfo(ipAddress string, addr *database.IpAddressInfoRow) *entity.IpAddressInfo {
 	return &entity.IpAddressInfo{
 		IpAddress:        ipAddress,
 		RirName:          addr.RirName,
@@ -29,9 +99,11 @@ func (p *IpAddress) GetIpAddress(ipAddress string) (*entity.IpAddressInfo, error
 		IpRangeStart:     addr.IpRangeStart,
 		IpRangeEnd:       addr.IpRangeEnd,
 		IpRangeQuantity:  addr.IpRangeQuantity,
+		IpRangePrefix:    addr.IpRangePrefix,
 		Status:           addr.Status,
 		StatusUpdatedAt:  addr.StatusUpdatedAt,
-	}, nil
+		HolderId:         addr.HolderId,
+	}
 }
 
 func NewIpAddressRepository(db database.Database) *IpAddress {
@@ -0,0 +1,121 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(subscription *entity.WebhookSubscription) (*entity.WebhookSubscription, error)
+	GetSubscription(id int64) (*entity.WebhookSubscription, error)
+	GetSubscriptions(ctx context.Context) ([]*entity.WebhookSubscription, error)
+	DeleteSubscription(id int64) (bool, error)
+	Enqueue(deliveries []*entity.WebhookDelivery, ctx context.Context) error
+	GetDeadLetters(subscriptionId int64) ([]*entity.WebhookDeadLetter, error)
+	RetryDeadLetter(subscriptionId, id int64) (bool, error)
+}
+
+type Webhook struct {
+	Db database.Database
+}
+
+func (p *Webhook) CreateSubscription(subscription *entity.WebhookSubscription) (*entity.WebhookSubscription, error) {
+	row, err := p.Db.CreateWebhookSubscription(&database.WebhookSubscriptionRow{
+		Url:          subscription.Url,
+		Secret:       subscription.Secret,
+		Events:       subscription.Events,
+		Rirs:         subscription.Rirs,
+		CountryCodes: subscription.CountryCodes,
+		Prefixes:     subscription.Prefixes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newWebhookSubscription(row), nil
+}
+
+func (p *Webhook) GetSubscription(id int64) (*entity.WebhookSubscription, error) {
+	row, err := p.Db.GetWebhookSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, nil
+	}
+	return newWebhookSubscription(row), nil
+}
+
+func (p *Webhook) GetSubscriptions(ctx context.Context) ([]*entity.WebhookSubscription, error) {
+	rows, err := p.Db.GetWebhookSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions := make([]*entity.WebhookSubscription, len(rows))
+	for i, row := range rows {
+		subscriptions[i] = newWebhookSubscription(row)
+	}
+	return subscriptions, nil
+}
+
+func (p *Webhook) DeleteSubscription(id int64) (bool, error) {
+	return p.Db.DeleteWebhookSubscription(id)
+}
+
+func (p *Webhook) Enqueue(deliveries []*entity.WebhookDelivery, ctx context.Context) error {
+	rows := make([]*database.WebhookDeliveryRow, len(deliveries))
+	for i, delivery := range deliveries {
+		rows[i] = &database.WebhookDeliveryRow{
+			SubscriptionId: delivery.SubscriptionId,
+			EventId:        delivery.EventId,
+			EventType:      delivery.EventType,
+			Payload:        delivery.Payload,
+		}
+	}
+	return p.Db.EnqueueWebhookDeliveries(rows, ctx)
+}
+
+func (p *Webhook) GetDeadLetters(subscriptionId int64) ([]*entity.WebhookDeadLetter, error) {
+	rows, err := p.Db.GetWebhookDeadLetters(subscriptionId)
+	if err != nil {
+		return nil, err
+	}
+	deadLetters := make([]*entity.WebhookDeadLetter, len(rows))
+	for i, row := range rows {
+		deadLetters[i] = &entity.WebhookDeadLetter{
+			Id:        row.Id,
+			EventId:   row.EventId,
+			EventType: row.EventType,
+			Payload:   row.Payload,
+			Attempts:  row.Attempts,
+			LastError: row.LastError,
+			CreatedAt: row.CreatedAt,
+			FailedAt:  row.FailedAt,
+		}
+	}
+	return deadLetters, nil
+}
+
+func (p *Webhook) RetryDeadLetter(subscriptionId, id int64) (bool, error) {
+	return p.Db.RetryWebhookDeadLetter(subscriptionId, id)
+}
+
+func newWebhookSubscription(row *database.WebhookSubscriptionRow) *entity.WebhookSubscription {
+	return &entity.WebhookSubscription{
+		Id:           row.Id,
+		Url:          row.Url,
+		Secret:       row.Secret,
+		Events:       row.Events,
+		Rirs:         row.Rirs,
+		CountryCodes: row.CountryCodes,
+		Prefixes:     row.Prefixes,
+		CreatedAt:    row.CreatedAt,
+	}
+}
+
+func NewWebhookRepository(db database.Database) *Webhook {
+	return &Webhook{
+		Db: db,
+	}
+}
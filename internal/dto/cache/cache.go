@@ -1,10 +1,11 @@
 package cache
 
 import (
-	"errors"
+	"context"
 	"fmt"
 
 	"github.com/KeilWin/ipinfo/internal/common"
+	"github.com/KeilWin/ipinfo/internal/entity"
 )
 
 type CacheType string
@@ -17,17 +18,16 @@ const (
 type Cache interface {
 	common.Storage
 
-	AddIpInfo()
-
-	GetIpInfo(ipAddress string)
+	AddIpInfo(ipInfo *entity.IpAddressInfo, ctx context.Context) error
+	GetIpInfo(ipAddress string, ctx context.Context) (*entity.IpAddressInfo, error)
+	FlushIpInfo(ctx context.Context) error
 }
 
 func NewCache(cacheConfig *CacheConfig) (Cache, error) {
 	switch cacheConfig.Type {
-	case ValkeyCacheType:
+	case ValkeyCacheType, RedisCacheType:
+		// Valkey speaks the same RESP protocol as Redis
 		return NewValkeyCache(cacheConfig), nil
-	case RedisCacheType:
-		return nil, errors.New("redis not implemented")
 	default:
 		return nil, fmt.Errorf("unknown cache type: %s", cacheConfig.Type)
 	}
@@ -4,8 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/KeilWin/ipinfo/internal/common"
+	"github.com/KeilWin/ipinfo/internal/utils"
 )
 
 const componentName = "CACHE"
@@ -16,6 +19,15 @@ type CacheConfig struct {
 	BasePrefix string
 
 	Type CacheType
+
+	Host     string
+	Port     int
+	Password string
+	Db       int
+	Tls      bool
+	PoolSize int
+	Timeout  time.Duration
+	Ttl      time.Duration
 }
 
 func (p *CacheConfig) NewVariableName(name string) string {
@@ -23,11 +35,40 @@ func (p *CacheConfig) NewVariableName(name string) string {
 }
 
 func (p *CacheConfig) Load() error {
+	var err error
 	var hasError bool
 
 	cacheTypeName := p.NewVariableName("TYPE")
 	p.Type = CacheType(os.Getenv(cacheTypeName))
 
+	hostName := p.NewVariableName("HOST")
+	p.Host = os.Getenv(hostName)
+	portName := p.NewVariableName("PORT")
+	p.Port, err = strconv.Atoi(os.Getenv(portName))
+	hasError = CheckLoadCacheConfigError(err, portName) || hasError
+	passwordName := p.NewVariableName("PASSWORD")
+	p.Password = os.Getenv(passwordName)
+	dbName := p.NewVariableName("DB")
+	p.Db, err = strconv.Atoi(os.Getenv(dbName))
+	hasError = CheckLoadCacheConfigError(err, dbName) || hasError
+	tlsName := p.NewVariableName("TLS")
+	p.Tls, err = strconv.ParseBool(os.Getenv(tlsName))
+	hasError = CheckLoadCacheConfigError(err, tlsName) || hasError
+
+	poolSizeName := p.NewVariableName("POOL_SIZE")
+	p.PoolSize, err = strconv.Atoi(os.Getenv(poolSizeName))
+	hasError = CheckLoadCacheConfigError(err, poolSizeName) || hasError
+
+	timeoutName := p.NewVariableName("TIMEOUT")
+	timeout, err := strconv.Atoi(os.Getenv(timeoutName))
+	hasError = CheckLoadCacheConfigError(err, timeoutName) || hasError
+	p.Timeout = time.Duration(timeout) * time.Second
+
+	ttlName := p.NewVariableName("TTL")
+	ttl, err := strconv.Atoi(os.Getenv(ttlName))
+	hasError = CheckLoadCacheConfigError(err, ttlName) || hasError
+	p.Ttl = time.Duration(ttl) * time.Second
+
 	if hasError {
 		return errors.New("loading cache config")
 	}
@@ -38,8 +79,16 @@ func (p *CacheConfig) Check() error {
 	return nil
 }
 
+func (p *CacheConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", p.Host, p.Port)
+}
+
 func NewCacheConfig(appPrefix string) *CacheConfig {
 	return &CacheConfig{
 		BasePrefix: common.NewBasePrefix(appPrefix, componentName),
 	}
 }
+
+func CheckLoadCacheConfigError(err error, name string) bool {
+	return utils.CheckLoadConfigError(err, name, componentName)
+}
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Minimal RESP2 client, enough for the commands used by ValkeyCache
+
+type RespError string
+
+func (e RespError) Error() string {
+	return string(e)
+}
+
+type RespConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+func (p *RespConn) Close() error {
+	return p.conn.Close()
+}
+
+func (p *RespConn) SetDeadline(ctx context.Context, timeout time.Duration) error {
+	deadline, ok := ctx.Deadline()
+	if !ok && timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	return p.conn.SetDeadline(deadline)
+}
+
+func (p *RespConn) Do(args ...string) (any, error) {
+	if err := p.writeCommand(args); err != nil {
+		return nil, fmt.Errorf("write command: %w", err)
+	}
+	return p.readReply()
+}
+
+func (p *RespConn) writeCommand(args []string) error {
+	p.writer.WriteString("*")
+	p.writer.WriteString(strconv.Itoa(len(args)))
+	p.writer.WriteString("\r\n")
+	for _, arg := range args {
+		p.writer.WriteString("$")
+		p.writer.WriteString(strconv.Itoa(len(arg)))
+		p.writer.WriteString("\r\n")
+		p.writer.WriteString(arg)
+		p.writer.WriteString("\r\n")
+	}
+	return p.writer.Flush()
+}
+
+func (p *RespConn) readLine() (string, error) {
+	line, err := p.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("malformed reply line: %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+func (p *RespConn) readReply() (any, error) {
+	line, err := p.readLine()
+	if err != nil {
+		return nil, fmt.Errorf("read reply: %w", err)
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RespError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("parse bulk size: %w", err)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(p.reader, buf); err != nil {
+			return nil, fmt.Errorf("read bulk: %w", err)
+		}
+		return buf[:size], nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("parse array size: %w", err)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		values := make([]any, size)
+		for i := range values {
+			if values[i], err = p.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unknown reply type: %q", line)
+	}
+}
+
+func DialResp(ctx context.Context, addr string, tlsConfig *tls.Config, timeout time.Duration) (*RespConn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("tls handshake: %w", err)
+		}
+		conn = tlsConn
+	}
+	return &RespConn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+	}, nil
+}
+
+type RespPool struct {
+	dial  func(ctx context.Context) (*RespConn, error)
+	slots chan struct{}
+	idle  chan *RespConn
+}
+
+func (p *RespPool) Get(ctx context.Context) (*RespConn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case conn := <-p.idle:
+		return conn, nil
+	default:
+	}
+	conn, err := p.dial(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Put returns connection to the pool, connections with io or protocol errors are dropped
+func (p *RespPool) Put(conn *RespConn, err error) {
+	var respErr RespError
+	if err != nil && !errors.As(err, &respErr) {
+		conn.Close()
+	} else {
+		select {
+		case p.idle <- conn:
+		default:
+			conn.Close()
+		}
+	}
+	<-p.slots
+}
+
+func (p *RespPool) Close() error {
+	for {
+		select {
+		case conn := <-p.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func NewRespPool(size int, dial func(ctx context.Context) (*RespConn, error)) *RespPool {
+	if size < 1 {
+		size = 1
+	}
+	return &RespPool{
+		dial:  dial,
+		slots: make(chan struct{}, size),
+		idle:  make(chan *RespConn, size),
+	}
+}
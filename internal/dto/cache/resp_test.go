@@ -0,0 +1,321 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+type respEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// In-process stand-in of valkey, speaks RESP2 for PING, AUTH, GET, SET with EX/PX, SCAN and UNLINK
+type respServer struct {
+	listener net.Listener
+	password string
+
+	mu    sync.Mutex
+	data  map[string]respEntry
+	conns []net.Conn
+	dials int
+}
+
+func newRespServer(t *testing.T, password string) *respServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &respServer{listener: listener, password: password, data: make(map[string]respEntry)}
+	go server.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		server.drop()
+	})
+	return server
+}
+
+func (p *respServer) config() *CacheConfig {
+	addr := p.listener.Addr().(*net.TCPAddr)
+	return &CacheConfig{
+		Host:     addr.IP.String(),
+		Port:     addr.Port,
+		Password: p.password,
+		PoolSize: 2,
+		Timeout:  time.Second,
+	}
+}
+
+func (p *respServer) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		p.conns = append(p.conns, conn)
+		p.dials++
+		p.mu.Unlock()
+		go p.handle(conn)
+	}
+}
+
+// Closes every open connection, as restarted server would
+func (p *respServer) drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+func (p *respServer) dialCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dials
+}
+
+func readRespCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func respBulk(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+func (p *respServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authorized := p.password == ""
+	for {
+		args, err := readRespCommand(reader)
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(args[0])
+		if !authorized && command != "AUTH" {
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		io.WriteString(conn, p.reply(command, args[1:], &authorized))
+	}
+}
+
+func (p *respServer) reply(command string, args []string, authorized *bool) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch command {
+	case "PING":
+		return "+PONG\r\n"
+	case "AUTH":
+		if len(args) != 1 || args[0] != p.password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		*authorized = true
+		return "+OK\r\n"
+	case "GET":
+		entry, ok := p.data[args[0]]
+		if !ok || !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+			return "$-1\r\n"
+		}
+		return respBulk(entry.value)
+	case "SET":
+		entry := respEntry{value: args[1]}
+		if len(args) == 4 {
+			ttl, err := strconv.Atoi(args[3])
+			if err != nil || ttl <= 0 {
+				return "-ERR invalid expire time in 'set' command\r\n"
+			}
+			switch strings.ToUpper(args[2]) {
+			case "EX":
+				entry.expiresAt = time.Now().Add(time.Duration(ttl) * time.Second)
+			case "PX":
+				entry.expiresAt = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+			default:
+				return "-ERR syntax error\r\n"
+			}
+		}
+		p.data[args[0]] = entry
+		return "+OK\r\n"
+	case "SCAN":
+		keys := make([]string, 0)
+		for key := range p.data {
+			if strings.HasPrefix(key, strings.TrimSuffix(args[2], "*")) {
+				keys = append(keys, respBulk(key))
+			}
+		}
+		return fmt.Sprintf("*2\r\n%s*%d\r\n%s", respBulk("0"), len(keys), strings.Join(keys, ""))
+	case "UNLINK":
+		for _, key := range args {
+			delete(p.data, key)
+		}
+		return fmt.Sprintf(":%d\r\n", len(args))
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", command)
+	}
+}
+
+func dialRespServer(t *testing.T, server *respServer) *RespConn {
+	t.Helper()
+	conn, err := DialResp(context.Background(), server.config().Addr(), nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestRespSetGet(t *testing.T) {
+	conn := dialRespServer(t, newRespServer(t, ""))
+
+	reply, err := conn.Do("SET", "key", "value")
+	if err != nil || reply != "OK" {
+		t.Fatalf("SET = %v, %v", reply, err)
+	}
+	reply, err = conn.Do("GET", "key")
+	if value, ok := reply.([]byte); err != nil || !ok || string(value) != "value" {
+		t.Fatalf("GET = %v, %v", reply, err)
+	}
+}
+
+func TestRespSetEx(t *testing.T) {
+	conn := dialRespServer(t, newRespServer(t, ""))
+
+	if _, err := conn.Do("SET", "key", "value", "EX", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := conn.Do("GET", "key"); err != nil || reply == nil {
+		t.Fatalf("GET before expiry = %v, %v", reply, err)
+	}
+	if _, err := conn.Do("SET", "short", "value", "PX", "10"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if reply, err := conn.Do("GET", "short"); err != nil || reply != nil {
+		t.Fatalf("GET after expiry = %v, %v, want nil", reply, err)
+	}
+}
+
+func TestRespNilBulk(t *testing.T) {
+	conn := dialRespServer(t, newRespServer(t, ""))
+
+	reply, err := conn.Do("GET", "missing")
+	if err != nil || reply != nil {
+		t.Fatalf("GET missing = %v, %v, want nil", reply, err)
+	}
+}
+
+func TestRespErrorReply(t *testing.T) {
+	conn := dialRespServer(t, newRespServer(t, ""))
+
+	_, err := conn.Do("NOSUCH")
+	var respErr RespError
+	if !errors.As(err, &respErr) || !strings.HasPrefix(string(respErr), "ERR unknown command") {
+		t.Fatalf("NOSUCH error = %v, want RespError", err)
+	}
+	// Connection stays usable after error reply
+	if reply, err := conn.Do("PING"); err != nil || reply != "PONG" {
+		t.Fatalf("PING after error = %v, %v", reply, err)
+	}
+}
+
+func TestValkeyCacheIpInfo(t *testing.T) {
+	server := newRespServer(t, "secret")
+	config := server.config()
+	config.Ttl = time.Hour
+	cache := NewValkeyCache(config)
+	if err := cache.StartUp(); err != nil {
+		t.Fatal(err)
+	}
+	defer cache.ShutDown()
+	ctx := context.Background()
+
+	ipInfo, err := cache.GetIpInfo("1.1.1.1", ctx)
+	if err != nil || ipInfo != nil {
+		t.Fatalf("GetIpInfo of missing = %v, %v, want nil", ipInfo, err)
+	}
+	if err = cache.AddIpInfo(&entity.IpAddressInfo{IpAddress: "2001:DB8::1", CountryCode: "NL"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	ipInfo, err = cache.GetIpInfo("2001:db8::1", ctx)
+	if err != nil || ipInfo == nil || ipInfo.CountryCode != "NL" {
+		t.Fatalf("GetIpInfo = %+v, %v", ipInfo, err)
+	}
+	if err = cache.FlushIpInfo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ipInfo, err = cache.GetIpInfo("2001:db8::1", ctx); err != nil || ipInfo != nil {
+		t.Fatalf("GetIpInfo after flush = %v, %v, want nil", ipInfo, err)
+	}
+}
+
+func TestValkeyCacheWrongPassword(t *testing.T) {
+	server := newRespServer(t, "secret")
+	config := server.config()
+	config.Password = "wrong"
+	if err := NewValkeyCache(config).StartUp(); err == nil {
+		t.Fatal("StartUp with wrong password succeeded")
+	}
+}
+
+func TestValkeyCacheReconnect(t *testing.T) {
+	server := newRespServer(t, "")
+	cache := NewValkeyCache(server.config())
+	if err := cache.StartUp(); err != nil {
+		t.Fatal(err)
+	}
+	defer cache.ShutDown()
+	ctx := context.Background()
+
+	server.drop()
+	// Pooled connection is broken, the failed call drops it
+	if _, err := cache.GetIpInfo("1.1.1.1", ctx); err == nil {
+		t.Fatal("GetIpInfo over dropped connection succeeded")
+	}
+	dials := server.dialCount()
+	if err := cache.AddIpInfo(&entity.IpAddressInfo{IpAddress: "1.1.1.1"}, ctx); err != nil {
+		t.Fatalf("AddIpInfo after reconnect: %v", err)
+	}
+	if server.dialCount() != dials+1 {
+		t.Fatalf("dials = %d, want %d", server.dialCount(), dials+1)
+	}
+	if ipInfo, err := cache.GetIpInfo("1.1.1.1", ctx); err != nil || ipInfo == nil {
+		t.Fatalf("GetIpInfo after reconnect = %v, %v", ipInfo, err)
+	}
+}
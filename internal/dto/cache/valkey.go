@@ -1,28 +1,159 @@
 package cache
 
-type ValkeyCacheConfig struct {
-}
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strconv"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+const ipInfoKeyPrefix = "ipinfo:ip:"
 
 type ValkeyCache struct {
 	Cache
 
 	Config *CacheConfig
+	pool   *RespPool
 }
 
 func (p *ValkeyCache) StartUp() error {
+	var tlsConfig *tls.Config
+	if p.Config.Tls {
+		tlsConfig = &tls.Config{
+			ServerName: p.Config.Host,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	p.pool = NewRespPool(p.Config.PoolSize, func(ctx context.Context) (*RespConn, error) {
+		conn, err := DialResp(ctx, p.Config.Addr(), tlsConfig, p.Config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("dial cache: %w", err)
+		}
+		if err = conn.SetDeadline(ctx, p.Config.Timeout); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if p.Config.Password != "" {
+			if _, err = conn.Do("AUTH", p.Config.Password); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("auth: %w", err)
+			}
+		}
+		if p.Config.Db != 0 {
+			if _, err = conn.Do("SELECT", strconv.Itoa(p.Config.Db)); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("select db: %w", err)
+			}
+		}
+		return conn, nil
+	})
+
+	if _, err := p.do(context.Background(), "PING"); err != nil {
+		return fmt.Errorf("ping cache: %w", err)
+	}
+	slog.Info("cache connected")
 	return nil
 }
 
 func (p *ValkeyCache) ShutDown() error {
-	return nil
+	if p.pool == nil {
+		return nil
+	}
+	return p.pool.Close()
+}
+
+func (p *ValkeyCache) do(ctx context.Context, args ...string) (any, error) {
+	conn, err := p.pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetDeadline(ctx, p.Config.Timeout); err != nil {
+		p.pool.Put(conn, err)
+		return nil, err
+	}
+	reply, err := conn.Do(args...)
+	p.pool.Put(conn, err)
+	return reply, err
 }
 
-func (p *ValkeyCache) AddIpInfo() {
+func newIpInfoKey(ipAddress string) string {
+	if addr, err := netip.ParseAddr(ipAddress); err == nil {
+		ipAddress = addr.String()
+	}
+	return ipInfoKeyPrefix + ipAddress
+}
 
+func (p *ValkeyCache) AddIpInfo(ipInfo *entity.IpAddressInfo, ctx context.Context) error {
+	value, err := json.Marshal(ipInfo)
+	if err != nil {
+		return fmt.Errorf("marshal ip info: %w", err)
+	}
+	args := []string{"SET", newIpInfoKey(ipInfo.IpAddress), string(value)}
+	if p.Config.Ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(p.Config.Ttl.Milliseconds(), 10))
+	}
+	_, err = p.do(ctx, args...)
+	return err
 }
 
-func (p *ValkeyCache) GetIpInfo(ipAddress string) {
+func (p *ValkeyCache) GetIpInfo(ipAddress string, ctx context.Context) (*entity.IpAddressInfo, error) {
+	reply, err := p.do(ctx, "GET", newIpInfoKey(ipAddress))
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply type: %T", reply)
+	}
+	ipInfo := entity.NewIpAddressInfo()
+	if err = json.Unmarshal(value, ipInfo); err != nil {
+		return nil, fmt.Errorf("unmarshal ip info: %w", err)
+	}
+	return ipInfo, nil
+}
 
+func (p *ValkeyCache) FlushIpInfo(ctx context.Context) error {
+	cursor := "0"
+	for {
+		reply, err := p.do(ctx, "SCAN", cursor, "MATCH", ipInfoKeyPrefix+"*", "COUNT", "1000")
+		if err != nil {
+			return fmt.Errorf("scan: %w", err)
+		}
+		values, ok := reply.([]any)
+		if !ok || len(values) != 2 {
+			return errors.New("unexpected scan reply")
+		}
+		next, ok := values[0].([]byte)
+		if !ok {
+			return errors.New("unexpected scan cursor")
+		}
+		keys, _ := values[1].([]any)
+		if len(keys) != 0 {
+			args := make([]string, 0, len(keys)+1)
+			args = append(args, "UNLINK")
+			for _, key := range keys {
+				if key, ok := key.([]byte); ok {
+					args = append(args, string(key))
+				}
+			}
+			if _, err = p.do(ctx, args...); err != nil {
+				return fmt.Errorf("unlink: %w", err)
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" {
+			return nil
+		}
+	}
 }
 
 func NewValkeyCache(config *CacheConfig) *ValkeyCache {
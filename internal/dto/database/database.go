@@ -1,8 +1,10 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/KeilWin/ipinfo/internal/common"
 )
@@ -11,8 +13,36 @@ type Database interface {
 	common.Storage
 
 	GetIpInfo(ipAddress string) (*IpAddressInfoRow, error)
+	GetIpInfoBatch(ipAddresses []string) ([]*IpAddressInfoRow, error)
+	GetIpRanges(ctx context.Context) ([]*IpAddressInfoRow, error)
+	GetIpRangesOverlapping(firstIpAddress, lastIpAddress string, after *IpRangeKey, limit int) ([]*IpAddressInfoRow, error)
+	GetAsnInfo(asn uint32) (*AsnInfoRow, error)
+	// Lookups in history, answered by version of data valid at given time
+	GetIpInfoAt(ipAddress string, at time.Time) (*IpAddressInfoRow, error)
+	GetAsnInfoAt(asn uint32, at time.Time) (*AsnInfoRow, error)
+	GetHolderIpRanges(opaqueId string) ([]*IpAddressInfoRow, error)
+	GetHolderAsnRanges(opaqueId string) ([]*AsnInfoRow, error)
+	// Count of ip and asn ranges currently stored for rir
+	GetRirRowCount(rirTableName string, ctx context.Context) (uint64, error)
+	GetRirSnapshotId(rirName, serial string) (int64, error)
+	GetRangeChanges(filter *RangeChangeFilter) ([]*RangeChangeRow, error)
+
+	CreateWebhookSubscription(subscription *WebhookSubscriptionRow) (*WebhookSubscriptionRow, error)
+	GetWebhookSubscription(id int64) (*WebhookSubscriptionRow, error)
+	GetWebhookSubscriptions(ctx context.Context) ([]*WebhookSubscriptionRow, error)
+	DeleteWebhookSubscription(id int64) (bool, error)
+	EnqueueWebhookDeliveries(deliveries []*WebhookDeliveryRow, ctx context.Context) error
+	ClaimWebhookDeliveries(limit int, lease time.Duration, ctx context.Context) ([]*WebhookDeliveryRow, error)
+	CompleteWebhookDelivery(id int64, ctx context.Context) error
+	RetryWebhookDelivery(id int64, nextAttemptAt time.Time, lastError string, ctx context.Context) error
+	DeadLetterWebhookDelivery(id int64, lastError string, ctx context.Context) error
+	GetWebhookDeadLetters(subscriptionId int64) ([]*WebhookDeliveryRow, error)
+	RetryWebhookDeadLetter(subscriptionId, id int64) (bool, error)
 }
 
+// Changed by updater after every successful upload of rir data
+const DataVersionOptionName = "dataVersion"
+
 func NewDatabase(databaseConfig *DatabaseConfig) (Database, error) {
 	switch databaseConfig.Type {
 	case PostgreSqlDatabaseType:
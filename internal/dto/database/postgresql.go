@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"iter"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/KeilWin/ipinfo/internal/common"
@@ -20,8 +22,54 @@ type IpAddressInfoRow struct {
 	IpRangeStart     string `json:"ipRangeStart"`
 	IpRangeEnd       string `json:"ipRangeEnd"`
 	IpRangeQuantity  string `json:"ipRangeQuantity"`
+	IpRangePrefix    string `json:"ipRangePrefix"`
 	Status           string `json:"status"`
 	StatusUpdatedAt  string `json:"statusUpdatedAt"`
+	HolderId         string `json:"holderId"`
+}
+
+type AsnInfoRow struct {
+	Id               string `json:"id"`
+	RirName          string `json:"rirName"`
+	CountryCode      string `json:"countryCode"`
+	AsnRangeStart    string `json:"asnRangeStart"`
+	AsnRangeEnd      string `json:"asnRangeEnd"`
+	AsnRangeQuantity string `json:"asnRangeQuantity"`
+	Status           string `json:"status"`
+	StatusUpdatedAt  string `json:"statusUpdatedAt"`
+	HolderId         string `json:"holderId"`
+}
+
+type RangeChangeRow struct {
+	Id             int64
+	RirName        string
+	Serial         string
+	PublishedAt    time.Time
+	Kind           string
+	RangeType      string
+	RangeStart     string
+	RangeEnd       string
+	OldCountryCode string
+	NewCountryCode string
+	OldStatus      string
+	NewStatus      string
+}
+
+// Changes with id after cursor, rir and since conditions are skipped when empty
+type RangeChangeFilter struct {
+	RirName         string
+	SinceSnapshotId int64
+	SinceTime       time.Time
+	SnapshotId      int64
+	After           int64
+	Limit           int
+}
+
+// Natural key of range in ip_ranges, cursor of overlapping ranges pages
+type IpRangeKey struct {
+	StartIp string
+	EndIp   string
+	RirName string
 }
 
 type PostgreSqlDatabase struct {
@@ -60,9 +108,20 @@ func (p *PostgreSqlDatabase) ShutDown() error {
 	return p.Db.Close()
 }
 
-func (p *PostgreSqlDatabase) GetIpInfo(ipAddress string) (*IpAddressInfoRow, error) {
+// Of ranges containing address the innermost starts last and of those ends first, same as in memory index
+const innermostIpRangeOrder = "start_ip DESC, end_ip, rir_name"
+
+const ipRangesColumns = "id, rir_name, country_code, ip_version_name, start_ip, end_ip, quantity, prefix_length, status_name, COALESCE(status_changed_at::text, ''), COALESCE(opaque_id, '')"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Extra destinations are scanned from columns before ipRangesColumns
+func scanIpAddressInfoRow(row rowScanner, extra ...any) (*IpAddressInfoRow, error) {
+	var prefixLength sql.NullInt32
 	ipInfoRow := &IpAddressInfoRow{}
-	err := p.Db.QueryRow("SELECT * FROM ip_ranges WHERE start_ip <= $1::inet AND end_ip > $2::inet LIMIT 1", ipAddress, ipAddress).Scan(
+	err := row.Scan(append(extra,
 		&ipInfoRow.Id,
 		&ipInfoRow.RirName,
 		&ipInfoRow.CountryCode,
@@ -70,9 +129,40 @@ func (p *PostgreSqlDatabase) GetIpInfo(ipAddress string) (*IpAddressInfoRow, err
 		&ipInfoRow.IpRangeStart,
 		&ipInfoRow.IpRangeEnd,
 		&ipInfoRow.IpRangeQuantity,
+		&prefixLength,
 		&ipInfoRow.Status,
 		&ipInfoRow.StatusUpdatedAt,
-	)
+		&ipInfoRow.HolderId,
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	if prefixLength.Valid {
+		ipInfoRow.IpRangePrefix = fmt.Sprintf("%s/%d", ipInfoRow.IpRangeStart, prefixLength.Int32)
+	}
+	return ipInfoRow, nil
+}
+
+// Innermost range of versions valid at given time, found by idx_ip_range_history_lookup
+func (p *PostgreSqlDatabase) GetIpInfoAt(ipAddress string, at time.Time) (*IpAddressInfoRow, error) {
+	row := p.Db.QueryRow("SELECT "+ipRangesColumns+` FROM ip_ranges_history
+	WHERE inetrange(start_ip, end_ip) @> $1::inet AND tstzrange(valid_from, valid_to) @> $2::timestamptz
+	ORDER BY `+innermostIpRangeOrder+` LIMIT 1`, ipAddress, at)
+	ipInfoRow, err := scanIpAddressInfoRow(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ipInfoRow, nil
+}
+
+// Innermost range, found by idx_ip_ranges_range
+func (p *PostgreSqlDatabase) GetIpInfo(ipAddress string) (*IpAddressInfoRow, error) {
+	row := p.Db.QueryRow("SELECT "+ipRangesColumns+` FROM ip_ranges WHERE inetrange(start_ip, end_ip) @> $1::inet
+	ORDER BY `+innermostIpRangeOrder+` LIMIT 1`, ipAddress)
+	ipInfoRow, err := scanIpAddressInfoRow(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -82,6 +172,183 @@ func (p *PostgreSqlDatabase) GetIpInfo(ipAddress string) (*IpAddressInfoRow, err
 	return ipInfoRow, nil
 }
 
+// Result is aligned with ipAddresses, nil for not found addresses
+func (p *PostgreSqlDatabase) GetIpInfoBatch(ipAddresses []string) ([]*IpAddressInfoRow, error) {
+	rows, err := p.Db.Query(`SELECT q.ord, r.* FROM unnest($1::inet[]) WITH ORDINALITY AS q(addr, ord)
+	CROSS JOIN LATERAL (
+		SELECT `+ipRangesColumns+` FROM ip_ranges WHERE inetrange(start_ip, end_ip) @> q.addr
+		ORDER BY `+innermostIpRangeOrder+` LIMIT 1
+	) r`, pq.Array(ipAddresses))
+	if err != nil {
+		return nil, fmt.Errorf("select ip ranges: %w", err)
+	}
+	defer rows.Close()
+
+	ipInfoRows := make([]*IpAddressInfoRow, len(ipAddresses))
+	for rows.Next() {
+		var ord int
+		ipInfoRow, err := scanIpAddressInfoRow(rows, &ord)
+		if err != nil {
+			return nil, fmt.Errorf("scan ip range: %w", err)
+		}
+		if ord < 1 || ord > len(ipInfoRows) {
+			return nil, fmt.Errorf("unexpected ordinality: %d", ord)
+		}
+		ipInfoRows[ord-1] = ipInfoRow
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate ip ranges: %w", err)
+	}
+	return ipInfoRows, nil
+}
+
+func (p *PostgreSqlDatabase) GetIpRanges(ctx context.Context) ([]*IpAddressInfoRow, error) {
+	rows, err := p.Db.QueryContext(ctx, "SELECT "+ipRangesColumns+" FROM ip_ranges")
+	if err != nil {
+		return nil, fmt.Errorf("select ip ranges: %w", err)
+	}
+	defer rows.Close()
+
+	ipRanges := make([]*IpAddressInfoRow, 0, 1<<16)
+	for rows.Next() {
+		ipInfoRow, err := scanIpAddressInfoRow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan ip range: %w", err)
+		}
+		ipRanges = append(ipRanges, ipInfoRow)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate ip ranges: %w", err)
+	}
+	return ipRanges, nil
+}
+
+// Page of ranges after key of last range of previous page, nil after for the first page.
+// Key doesn't have to exist anymore, so pages stay consistent across updates
+func (p *PostgreSqlDatabase) GetIpRangesOverlapping(firstIpAddress, lastIpAddress string, after *IpRangeKey, limit int) ([]*IpAddressInfoRow, error) {
+	args := []any{firstIpAddress, lastIpAddress, limit}
+	afterCondition := ""
+	if after != nil {
+		afterCondition = "AND (start_ip, end_ip, rir_name) > ($4::inet, $5::inet, $6)"
+		args = append(args, after.StartIp, after.EndIp, after.RirName)
+	}
+	rows, err := p.Db.Query("SELECT "+ipRangesColumns+` FROM ip_ranges
+	WHERE start_ip <= $2::inet AND end_ip > $1::inet AND family(start_ip) = family($1::inet) `+afterCondition+`
+	ORDER BY start_ip, end_ip, rir_name
+	LIMIT $3`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select ip ranges: %w", err)
+	}
+	defer rows.Close()
+
+	ipInfoRows := make([]*IpAddressInfoRow, 0)
+	for rows.Next() {
+		ipInfoRow, err := scanIpAddressInfoRow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan ip range: %w", err)
+		}
+		ipInfoRows = append(ipInfoRows, ipInfoRow)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate ip ranges: %w", err)
+	}
+	return ipInfoRows, nil
+}
+
+const innermostAsnRangeOrder = "start_asn DESC, end_asn, rir_name"
+
+const asnRangesColumns = "id, rir_name, country_code, start_asn, end_asn, quantity, status_name, COALESCE(status_changed_at::text, ''), COALESCE(opaque_id, '')"
+
+func scanAsnInfoRow(row rowScanner, extra ...any) (*AsnInfoRow, error) {
+	asnInfoRow := &AsnInfoRow{}
+	err := row.Scan(append(extra,
+		&asnInfoRow.Id,
+		&asnInfoRow.RirName,
+		&asnInfoRow.CountryCode,
+		&asnInfoRow.AsnRangeStart,
+		&asnInfoRow.AsnRangeEnd,
+		&asnInfoRow.AsnRangeQuantity,
+		&asnInfoRow.Status,
+		&asnInfoRow.StatusUpdatedAt,
+		&asnInfoRow.HolderId,
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	return asnInfoRow, nil
+}
+
+// Innermost range of versions valid at given time, found by idx_asn_range_history_lookup
+func (p *PostgreSqlDatabase) GetAsnInfoAt(asn uint32, at time.Time) (*AsnInfoRow, error) {
+	row := p.Db.QueryRow("SELECT "+asnRangesColumns+` FROM asn_ranges_history
+	WHERE int8range(start_asn, end_asn) @> $1::bigint AND tstzrange(valid_from, valid_to) @> $2::timestamptz
+	ORDER BY `+innermostAsnRangeOrder+` LIMIT 1`, int64(asn), at)
+	asnInfoRow, err := scanAsnInfoRow(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return asnInfoRow, nil
+}
+
+// Innermost range, found by idx_asn_ranges_range
+func (p *PostgreSqlDatabase) GetAsnInfo(asn uint32) (*AsnInfoRow, error) {
+	row := p.Db.QueryRow("SELECT "+asnRangesColumns+` FROM asn_ranges WHERE int8range(start_asn, end_asn) @> $1::bigint
+	ORDER BY `+innermostAsnRangeOrder+` LIMIT 1`, int64(asn))
+	asnInfoRow, err := scanAsnInfoRow(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return asnInfoRow, nil
+}
+
+func (p *PostgreSqlDatabase) GetHolderIpRanges(opaqueId string) ([]*IpAddressInfoRow, error) {
+	rows, err := p.Db.Query("SELECT "+ipRangesColumns+" FROM ip_ranges WHERE opaque_id = $1 ORDER BY ip_version_name, start_ip", opaqueId)
+	if err != nil {
+		return nil, fmt.Errorf("select ip ranges: %w", err)
+	}
+	defer rows.Close()
+
+	ipInfoRows := make([]*IpAddressInfoRow, 0)
+	for rows.Next() {
+		ipInfoRow, err := scanIpAddressInfoRow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan ip range: %w", err)
+		}
+		ipInfoRows = append(ipInfoRows, ipInfoRow)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate ip ranges: %w", err)
+	}
+	return ipInfoRows, nil
+}
+
+func (p *PostgreSqlDatabase) GetHolderAsnRanges(opaqueId string) ([]*AsnInfoRow, error) {
+	rows, err := p.Db.Query("SELECT "+asnRangesColumns+" FROM asn_ranges WHERE opaque_id = $1 ORDER BY start_asn", opaqueId)
+	if err != nil {
+		return nil, fmt.Errorf("select asn ranges: %w", err)
+	}
+	defer rows.Close()
+
+	asnInfoRows := make([]*AsnInfoRow, 0)
+	for rows.Next() {
+		asnInfoRow, err := scanAsnInfoRow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan asn range: %w", err)
+		}
+		asnInfoRows = append(asnInfoRows, asnInfoRow)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate asn ranges: %w", err)
+	}
+	return asnInfoRows, nil
+}
+
 func (p *PostgreSqlDatabase) UpdateOption(name, value string, ctx context.Context) error {
 	_, err := p.Db.ExecContext(ctx, `INSERT INTO options (name, value) VALUES ($1, $2) 
 	ON CONFLICT (name) DO 
@@ -101,47 +368,369 @@ func (p *PostgreSqlDatabase) GetOption(name string, ctx context.Context) (string
 	return result, nil
 }
 
-func (p *PostgreSqlDatabase) UpdateRirData(rirTableName string, ip_ranges []common.IpRange, ctx context.Context) error {
-	tx, err := p.Db.BeginTx(ctx, nil)
+// Only one COPY can be active in transaction, so statement is switched when type of records changes.
+// Delegated files are grouped by type, so it happens a couple of times per file.
+type copyIn struct {
+	tx    *sql.Tx
+	ctx   context.Context
+	table string
+	stmt  *sql.Stmt
+	rows  int
+}
+
+func (p *copyIn) Switch(table string, columns ...string) error {
+	if p.table == table {
+		return nil
+	}
+	if err := p.Close(); err != nil {
+		return err
+	}
+
+	stmt, err := p.tx.PrepareContext(p.ctx, pq.CopyIn(table, columns...))
 	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
+		return fmt.Errorf("stmt open %s: %w", table, err)
 	}
-	defer tx.Rollback()
+	p.table = table
+	p.stmt = stmt
+	return nil
+}
+
+func (p *copyIn) Exec(args ...any) error {
+	if _, err := p.stmt.ExecContext(p.ctx, args...); err != nil {
+		return fmt.Errorf("exec %s[%d] = '%v': %w", p.table, p.rows, args, err)
+	}
+	p.rows++
+	return nil
+}
+
+func (p *copyIn) Close() error {
+	if p.stmt == nil {
+		return nil
+	}
+	defer func() {
+		p.table = ""
+		p.stmt = nil
+	}()
+
+	if _, err := p.stmt.ExecContext(p.ctx); err != nil {
+		return fmt.Errorf("finish copy %s: %w", p.table, err)
+	}
+	if err := p.stmt.Close(); err != nil {
+		return fmt.Errorf("stmt close %s: %w", p.table, err)
+	}
+	return nil
+}
+
+func (p *PostgreSqlDatabase) GetRirRowCount(rirTableName string, ctx context.Context) (uint64, error) {
+	var count uint64
+	query := fmt.Sprintf("SELECT (SELECT COUNT(*) FROM %s) + (SELECT COUNT(*) FROM %s)", rirTableName, NewAsnTableName(rirTableName))
+	if err := p.Db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+const (
+	rirTableColumns = "country_code, ip_version_id, start_ip, end_ip, quantity, prefix_length, status_id, status_changed_at, opaque_id"
+	asnTableColumns = "country_code, start_asn, end_asn, quantity, status_id, status_changed_at, opaque_id"
+
+	// Serializes swaps of all rirs, concurrent refreshes of the same view would wait for each other anyway
+	rirDataLockKey = 1
+)
+
+type rirTable struct {
+	name    string
+	columns string
+	// Partitioned table read by views, rir tables are its partitions
+	parent string
+	// Versions of records with valid_from and valid_to
+	history string
+	// Columns identifying range, other columns are its attributes
+	key string
+	// Type of range in change feed and format of key column as text
+	rangeType string
+	keyText   string
+}
+
+func newRirTables(rirTableName string) []rirTable {
+	return []rirTable{
+		{name: rirTableName, columns: rirTableColumns, parent: "rir_ip_ranges", history: "ip_range_history", key: "start_ip, end_ip", rangeType: "ip", keyText: "host(%s)"},
+		{name: NewAsnTableName(rirTableName), columns: asnTableColumns, parent: "rir_asn_ranges", history: "asn_range_history", key: "start_asn, end_asn", rangeType: "asn", keyText: "%s::text"},
+	}
+}
+
+func prefixColumns(alias, columns string) string {
+	prefixed := strings.Split(columns, ", ")
+	for i, column := range prefixed {
+		prefixed[i] = alias + "." + column
+	}
+	return strings.Join(prefixed, ", ")
+}
+
+func joinColumns(left, right, columns string) string {
+	conditions := strings.Split(columns, ", ")
+	for i, column := range conditions {
+		conditions[i] = fmt.Sprintf("%s.%s = %s.%s", left, column, right, column)
+	}
+	return strings.Join(conditions, " AND ")
+}
 
-	_, err = tx.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY", rirTableName))
+// Current versions of records missing in staging are closed, new and changed records get new versions
+func (p *PostgreSqlDatabase) updateHistory(tx *sql.Tx, ctx context.Context, rirTableName string, table rirTable) error {
+	staging := NewStagingTableName(table.name)
+	same := fmt.Sprintf("%s AND ROW(%s) IS NOT DISTINCT FROM ROW(%s)", joinColumns("s", "h", table.key), prefixColumns("s", table.columns), prefixColumns("h", table.columns))
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`UPDATE %s h SET valid_to = NOW()
+	WHERE h.rir_id = (SELECT id FROM rirs WHERE name = $1) AND h.valid_to IS NULL
+		AND NOT EXISTS (SELECT 1 FROM %s s WHERE %s)`, table.history, staging, same), rirTableName)
 	if err != nil {
-		return fmt.Errorf("truncate: %w", err)
+		return fmt.Errorf("close history %s: %w", table.name, err)
 	}
 
-	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(rirTableName, "country_code", "ip_version_id", "start_ip", "end_ip", "quantity", "status_id", "status_changed_at"))
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (rir_id, %s, valid_from)
+	SELECT (SELECT id FROM rirs WHERE name = $1), %s, NOW() FROM %s s
+	WHERE NOT EXISTS (
+		SELECT 1 FROM %s h WHERE h.rir_id = (SELECT id FROM rirs WHERE name = $1) AND h.valid_to IS NULL AND %s
+	)`, table.history, table.columns, prefixColumns("s", table.columns), staging, table.history, same), rirTableName)
 	if err != nil {
-		return fmt.Errorf("stmt open: %w", err)
+		return fmt.Errorf("open history %s: %w", table.name, err)
+	}
+	return nil
+}
+
+// Ranges of live table missing in staging are removed, ranges missing in live table are added,
+// ranges with other country or status are changed
+func (p *PostgreSqlDatabase) diffRirTable(tx *sql.Tx, ctx context.Context, snapshotId int64, table rirTable) error {
+	key := strings.Split(table.key, ", ")
+	start, end := key[0], key[1]
+	keyText := func(column string) string {
+		return fmt.Sprintf(table.keyText, fmt.Sprintf("COALESCE(s.%s, o.%s)", column, column))
 	}
 
-	for i, ip_range := range ip_ranges {
-		_, err = stmt.ExecContext(ctx, ip_range.CountryCode, ip_range.IpVersionId, ip_range.StartIp, ip_range.EndIp, ip_range.Quantity, ip_range.StatusId, ip_range.StatusChangedAt)
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO range_changes (snapshot_id, kind, range_type, range_start, range_end, old_country_code, new_country_code, old_status_id, new_status_id)
+	SELECT $1, CASE WHEN o.%[1]s IS NULL THEN 'added' WHEN s.%[1]s IS NULL THEN 'removed' ELSE 'changed' END, $2,
+		%[3]s, %[4]s, o.country_code, s.country_code, o.status_id, s.status_id
+	FROM %[5]s o FULL JOIN %[6]s s ON %[7]s
+	WHERE o.%[1]s IS NULL OR s.%[1]s IS NULL OR o.country_code IS DISTINCT FROM s.country_code OR o.status_id <> s.status_id
+	ORDER BY COALESCE(s.%[1]s, o.%[1]s), COALESCE(s.%[2]s, o.%[2]s)`,
+		start, end, keyText(start), keyText(end), table.name, NewStagingTableName(table.name), joinColumns("o", "s", table.key)), snapshotId, table.rangeType)
+	if err != nil {
+		return fmt.Errorf("diff %s: %w", table.name, err)
+	}
+	return nil
+}
+
+// Must be called before live tables are replaced, changes are not recorded when rir has no data yet
+func (p *PostgreSqlDatabase) createSnapshot(tx *sql.Tx, ctx context.Context, rirTableName, serial string, tables []rirTable) (*common.RirDataChanges, error) {
+	changes := &common.RirDataChanges{}
+	err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT NOT EXISTS (SELECT 1 FROM %s) AND NOT EXISTS (SELECT 1 FROM %s)", tables[0].name, tables[1].name)).Scan(&changes.Baseline)
+	if err != nil {
+		return nil, fmt.Errorf("check baseline: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO rir_snapshots (rir_id, serial, published_at, baseline)
+	VALUES ((SELECT id FROM rirs WHERE name = $1), NULLIF($2, ''), NOW(), $3) RETURNING id`, rirTableName, serial, changes.Baseline).Scan(&changes.SnapshotId)
+	if err != nil {
+		return nil, fmt.Errorf("insert snapshot: %w", err)
+	}
+	if changes.Baseline {
+		return changes, nil
+	}
+
+	for _, table := range tables {
+		if err = p.diffRirTable(tx, ctx, changes.SnapshotId, table); err != nil {
+			return nil, err
+		}
+	}
+	err = tx.QueryRowContext(ctx, `UPDATE rir_snapshots s SET added = c.added, removed = c.removed, changed = c.changed
+	FROM (
+		SELECT COUNT(*) FILTER (WHERE kind = 'added') AS added, COUNT(*) FILTER (WHERE kind = 'removed') AS removed, COUNT(*) FILTER (WHERE kind = 'changed') AS changed
+		FROM range_changes WHERE snapshot_id = $1
+	) c
+	WHERE s.id = $1 RETURNING s.added, s.removed, s.changed`, changes.SnapshotId).Scan(&changes.Added, &changes.Removed, &changes.Changed)
+	if err != nil {
+		return nil, fmt.Errorf("count changes: %w", err)
+	}
+	return changes, nil
+}
+
+// Shadow table is filled from staging and attached in place of live partition. Ranges present in live
+// table keep their ids, new ranges take ids from the sequence, so concurrent refresh diff stays small
+func (p *PostgreSqlDatabase) swapRirTable(tx *sql.Tx, ctx context.Context, rirId int, table rirTable) error {
+	shadow := NewShadowTableName(table.name)
+	staging := NewStagingTableName(table.name)
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING ALL)", shadow, table.name))
+	if err != nil {
+		return fmt.Errorf("create shadow %s: %w", table.name, err)
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (id, %s) SELECT o.id, %s FROM %s s JOIN %s o ON %s",
+		shadow, table.columns, prefixColumns("s", table.columns), staging, table.name, joinColumns("s", "o", table.key)))
+	if err != nil {
+		return fmt.Errorf("insert kept %s: %w", table.name, err)
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s s WHERE NOT EXISTS (SELECT 1 FROM %s o WHERE %s) ORDER BY %s",
+		shadow, table.columns, prefixColumns("s", table.columns), staging, table.name, joinColumns("s", "o", table.key), prefixColumns("s", table.key)))
+	if err != nil {
+		return fmt.Errorf("insert added %s: %w", table.name, err)
+	}
+
+	for _, statement := range []string{
+		fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", table.parent, table.name),
+		fmt.Sprintf("DROP TABLE %s", table.name),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", shadow, table.name),
+		// Check of rir id is copied with the table, so attach doesn't scan it
+		fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION %s FOR VALUES IN (%d)", table.parent, table.name, rirId),
+	} {
+		if _, err = tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("swap %s: %w", table.name, err)
+		}
+	}
+	return nil
+}
+
+// Records are loaded into staging tables first, so a failed load never touches live data.
+// Live tables are swapped and views are refreshed concurrently in the same transaction:
+// swap locks only partitioned tables, readers of views are never blocked and see either old or new complete data.
+func (p *PostgreSqlDatabase) UpdateRirData(rirTableName, serial string, records iter.Seq2[common.RirRecord, error], ctx context.Context) (*common.RirDataChanges, error) {
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var rirId int
+	if err = tx.QueryRowContext(ctx, "SELECT id FROM rirs WHERE name = $1", rirTableName).Scan(&rirId); err != nil {
+		return nil, fmt.Errorf("get rir id: %w", err)
+	}
+
+	asnTableName := NewAsnTableName(rirTableName)
+	tables := newRirTables(rirTableName)
+	for _, table := range tables {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA", NewStagingTableName(table.name), table.columns, table.name))
 		if err != nil {
-			return fmt.Errorf("exec[%d] = '%v': %w", i, ip_range, err)
+			return nil, fmt.Errorf("create staging %s: %w", table.name, err)
 		}
 	}
 
-	if _, err = stmt.ExecContext(ctx); err != nil {
-		return fmt.Errorf("finish copy: %w", err)
+	copier := &copyIn{tx: tx, ctx: ctx}
+	for record, err := range records {
+		if err != nil {
+			return nil, fmt.Errorf("read records: %w", err)
+		}
+
+		if ip_range := record.IpRange; ip_range != nil {
+			if err = copier.Switch(NewStagingTableName(rirTableName), strings.Split(rirTableColumns, ", ")...); err != nil {
+				return nil, err
+			}
+			err = copier.Exec(ip_range.CountryCode, ip_range.IpVersionId, ip_range.StartIp, ip_range.EndIp, ip_range.Quantity.String(), ip_range.PrefixLength, ip_range.StatusId, ip_range.StatusChangedAt, ip_range.OpaqueId)
+		} else if asn_range := record.AsnRange; asn_range != nil {
+			if err = copier.Switch(NewStagingTableName(asnTableName), strings.Split(asnTableColumns, ", ")...); err != nil {
+				return nil, err
+			}
+			err = copier.Exec(asn_range.CountryCode, int64(asn_range.StartAsn), int64(asn_range.EndAsn), int64(asn_range.Quantity), asn_range.StatusId, asn_range.StatusChangedAt, asn_range.OpaqueId)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = copier.Close(); err != nil {
+		return nil, err
 	}
 
-	if err = stmt.Close(); err != nil {
-		return fmt.Errorf("stmt close: %w", err)
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", rirDataLockKey); err != nil {
+		return nil, fmt.Errorf("lock: %w", err)
+	}
+
+	changes, err := p.createSnapshot(tx, ctx, rirTableName, serial, tables)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range tables {
+		if err = p.updateHistory(tx, ctx, rirTableName, table); err != nil {
+			return nil, err
+		}
+		if err = p.swapRirTable(tx, ctx, rirId, table); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, view := range []string{"ip_ranges", "asn_ranges"} {
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf("REFRESH MATERIALIZED VIEW CONCURRENTLY %s", view)); err != nil {
+			return nil, fmt.Errorf("refresh %s: %w", view, err)
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
-		return err
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	// Swapped tables have no planner statistics until autovacuum gets to them.
+	// Data is already published, so failed analyze doesn't fail update
+	for _, table := range tables {
+		if _, err = p.Db.ExecContext(ctx, fmt.Sprintf("ANALYZE %s", table.name)); err != nil {
+			slog.Warn("analyze", "table", table.name, "error", err)
+		}
 	}
+	return changes, nil
+}
 
-	_, err = p.Db.ExecContext(ctx, "REFRESH MATERIALIZED VIEW ip_ranges;")
+// Latest snapshot of rir with serial, 0 when there is no such snapshot
+func (p *PostgreSqlDatabase) GetRirSnapshotId(rirName, serial string) (int64, error) {
+	var id int64
+	err := p.Db.QueryRow(`SELECT s.id FROM rir_snapshots s JOIN rirs ON rirs.id = s.rir_id
+	WHERE rirs.name = $1 AND s.serial = $2 ORDER BY s.id DESC LIMIT 1`, rirName, serial).Scan(&id)
 	if err != nil {
-		return err
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
 	}
-	return nil
+	return id, nil
+}
+
+func (p *PostgreSqlDatabase) GetRangeChanges(filter *RangeChangeFilter) ([]*RangeChangeRow, error) {
+	rows, err := p.Db.Query(`SELECT c.id, rirs.name, COALESCE(s.serial, ''), s.published_at, c.kind, c.range_type, c.range_start, c.range_end,
+		COALESCE(c.old_country_code, ''), COALESCE(c.new_country_code, ''), COALESCE(os.name, ''), COALESCE(ns.name, '')
+	FROM range_changes c
+		JOIN rir_snapshots s ON s.id = c.snapshot_id
+		JOIN rirs ON rirs.id = s.rir_id
+		LEFT JOIN ip_range_statuses os ON os.id = c.old_status_id
+		LEFT JOIN ip_range_statuses ns ON ns.id = c.new_status_id
+	WHERE c.id > $1 AND ($2 = '' OR rirs.name = $2) AND s.id > $3 AND s.published_at >= $4 AND ($6 = 0 OR s.id = $6)
+	ORDER BY c.id LIMIT $5`, filter.After, filter.RirName, filter.SinceSnapshotId, filter.SinceTime, filter.Limit, filter.SnapshotId)
+	if err != nil {
+		return nil, fmt.Errorf("select range changes: %w", err)
+	}
+	defer rows.Close()
+
+	changeRows := make([]*RangeChangeRow, 0)
+	for rows.Next() {
+		row := &RangeChangeRow{}
+		err = rows.Scan(&row.Id, &row.RirName, &row.Serial, &row.PublishedAt, &row.Kind, &row.RangeType, &row.RangeStart, &row.RangeEnd,
+			&row.OldCountryCode, &row.NewCountryCode, &row.OldStatus, &row.NewStatus)
+		if err != nil {
+			return nil, fmt.Errorf("scan range change: %w", err)
+		}
+		changeRows = append(changeRows, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate range changes: %w", err)
+	}
+	return changeRows, nil
+}
+
+func NewStagingTableName(tableName string) string {
+	return "staging_" + tableName
+}
+
+func NewShadowTableName(tableName string) string {
+	return "shadow_" + tableName
+}
+
+func NewAsnTableName(rirTableName string) string {
+	return rirTableName + "_asn"
 }
 
 func NewPostgreSqlDatabase(cfg *DatabaseConfig) *PostgreSqlDatabase {
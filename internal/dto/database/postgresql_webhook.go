@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type WebhookSubscriptionRow struct {
+	Id           int64
+	Url          string
+	Secret       string
+	Events       []string
+	Rirs         []string
+	CountryCodes []string
+	Prefixes     []string
+	CreatedAt    time.Time
+}
+
+type WebhookDeliveryRow struct {
+	Id             int64
+	SubscriptionId int64
+	EventId        string
+	EventType      string
+	Payload        string
+	Attempts       int
+	LastError      string
+	CreatedAt      time.Time
+	// Set by claim from subscription
+	Url    string
+	Secret string
+	// Set for dead letters
+	FailedAt time.Time
+}
+
+const webhookSubscriptionColumns = "id, url, secret, events, rirs, country_codes, prefixes::text[], created_at"
+
+func scanWebhookSubscriptionRow(row rowScanner) (*WebhookSubscriptionRow, error) {
+	subscriptionRow := &WebhookSubscriptionRow{}
+	err := row.Scan(
+		&subscriptionRow.Id,
+		&subscriptionRow.Url,
+		&subscriptionRow.Secret,
+		pq.Array(&subscriptionRow.Events),
+		pq.Array(&subscriptionRow.Rirs),
+		pq.Array(&subscriptionRow.CountryCodes),
+		pq.Array(&subscriptionRow.Prefixes),
+		&subscriptionRow.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return subscriptionRow, nil
+}
+
+func (p *PostgreSqlDatabase) CreateWebhookSubscription(subscription *WebhookSubscriptionRow) (*WebhookSubscriptionRow, error) {
+	row := p.Db.QueryRow(`INSERT INTO webhook_subscriptions (url, secret, events, rirs, country_codes, prefixes)
+	VALUES ($1, $2, COALESCE($3::text[], '{}'), COALESCE($4::text[], '{}'), COALESCE($5::text[], '{}'), COALESCE($6::cidr[], '{}')) RETURNING `+webhookSubscriptionColumns,
+		subscription.Url, subscription.Secret, pq.Array(subscription.Events), pq.Array(subscription.Rirs), pq.Array(subscription.CountryCodes), pq.Array(subscription.Prefixes))
+	subscriptionRow, err := scanWebhookSubscriptionRow(row)
+	if err != nil {
+		return nil, fmt.Errorf("insert webhook subscription: %w", err)
+	}
+	return subscriptionRow, nil
+}
+
+func (p *PostgreSqlDatabase) GetWebhookSubscription(id int64) (*WebhookSubscriptionRow, error) {
+	row := p.Db.QueryRow("SELECT "+webhookSubscriptionColumns+" FROM webhook_subscriptions WHERE id = $1", id)
+	subscriptionRow, err := scanWebhookSubscriptionRow(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return subscriptionRow, nil
+}
+
+func (p *PostgreSqlDatabase) GetWebhookSubscriptions(ctx context.Context) ([]*WebhookSubscriptionRow, error) {
+	rows, err := p.Db.QueryContext(ctx, "SELECT "+webhookSubscriptionColumns+" FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("select webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptionRows := make([]*WebhookSubscriptionRow, 0)
+	for rows.Next() {
+		subscriptionRow, err := scanWebhookSubscriptionRow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook subscription: %w", err)
+		}
+		subscriptionRows = append(subscriptionRows, subscriptionRow)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhook subscriptions: %w", err)
+	}
+	return subscriptionRows, nil
+}
+
+// Pending deliveries and dead letters of subscription are deleted too
+func (p *PostgreSqlDatabase) DeleteWebhookSubscription(id int64) (bool, error) {
+	result, err := p.Db.Exec("DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return false, fmt.Errorf("delete webhook subscription: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return deleted != 0, nil
+}
+
+func (p *PostgreSqlDatabase) EnqueueWebhookDeliveries(deliveries []*WebhookDeliveryRow, ctx context.Context) error {
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload) VALUES ($1, $2, $3, $4)")
+	if err != nil {
+		return fmt.Errorf("prepare webhook delivery: %w", err)
+	}
+	defer stmt.Close()
+	for _, delivery := range deliveries {
+		if _, err = stmt.ExecContext(ctx, delivery.SubscriptionId, delivery.EventId, delivery.EventType, delivery.Payload); err != nil {
+			return fmt.Errorf("insert webhook delivery: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Due deliveries are leased: they are not claimed again until lease expires, so a crashed sender
+// doesn't lose them and concurrent senders don't send them twice. Attempt is counted on claim.
+func (p *PostgreSqlDatabase) ClaimWebhookDeliveries(limit int, lease time.Duration, ctx context.Context) ([]*WebhookDeliveryRow, error) {
+	rows, err := p.Db.QueryContext(ctx, `UPDATE webhook_deliveries d SET attempts = d.attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2)
+	FROM webhook_subscriptions s
+	WHERE s.id = d.subscription_id AND d.id IN (
+		SELECT id FROM webhook_deliveries WHERE next_attempt_at <= NOW() ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+	)
+	RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.attempts, d.last_error, d.created_at, s.url, s.secret`, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveryRows := make([]*WebhookDeliveryRow, 0)
+	for rows.Next() {
+		row := &WebhookDeliveryRow{}
+		err = rows.Scan(&row.Id, &row.SubscriptionId, &row.EventId, &row.EventType, &row.Payload, &row.Attempts, &row.LastError, &row.CreatedAt, &row.Url, &row.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		deliveryRows = append(deliveryRows, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhook deliveries: %w", err)
+	}
+	return deliveryRows, nil
+}
+
+func (p *PostgreSqlDatabase) CompleteWebhookDelivery(id int64, ctx context.Context) error {
+	if _, err := p.Db.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE id = $1", id); err != nil {
+		return fmt.Errorf("delete webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgreSqlDatabase) RetryWebhookDelivery(id int64, nextAttemptAt time.Time, lastError string, ctx context.Context) error {
+	_, err := p.Db.ExecContext(ctx, "UPDATE webhook_deliveries SET next_attempt_at = $2, last_error = $3 WHERE id = $1", id, nextAttemptAt, lastError)
+	if err != nil {
+		return fmt.Errorf("update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgreSqlDatabase) DeadLetterWebhookDelivery(id int64, lastError string, ctx context.Context) error {
+	_, err := p.Db.ExecContext(ctx, `WITH failed AS (DELETE FROM webhook_deliveries WHERE id = $1 RETURNING *)
+	INSERT INTO webhook_dead_letters (subscription_id, event_id, event_type, payload, attempts, last_error, created_at)
+	SELECT subscription_id, event_id, event_type, payload, attempts, $2, created_at FROM failed`, id, lastError)
+	if err != nil {
+		return fmt.Errorf("dead letter webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgreSqlDatabase) GetWebhookDeadLetters(subscriptionId int64) ([]*WebhookDeliveryRow, error) {
+	rows, err := p.Db.Query(`SELECT id, subscription_id, event_id, event_type, payload, attempts, last_error, created_at, failed_at
+	FROM webhook_dead_letters WHERE subscription_id = $1 ORDER BY id`, subscriptionId)
+	if err != nil {
+		return nil, fmt.Errorf("select webhook dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	deadLetterRows := make([]*WebhookDeliveryRow, 0)
+	for rows.Next() {
+		row := &WebhookDeliveryRow{}
+		err = rows.Scan(&row.Id, &row.SubscriptionId, &row.EventId, &row.EventType, &row.Payload, &row.Attempts, &row.LastError, &row.CreatedAt, &row.FailedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook dead letter: %w", err)
+		}
+		deadLetterRows = append(deadLetterRows, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhook dead letters: %w", err)
+	}
+	return deadLetterRows, nil
+}
+
+// Dead letter is moved back to queue with reset attempts
+func (p *PostgreSqlDatabase) RetryWebhookDeadLetter(subscriptionId, id int64) (bool, error) {
+	result, err := p.Db.Exec(`WITH letter AS (DELETE FROM webhook_dead_letters WHERE id = $1 AND subscription_id = $2 RETURNING *)
+	INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, last_error, created_at)
+	SELECT subscription_id, event_id, event_type, payload, last_error, created_at FROM letter`, id, subscriptionId)
+	if err != nil {
+		return false, fmt.Errorf("retry webhook dead letter: %w", err)
+	}
+	retried, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return retried != 0, nil
+}
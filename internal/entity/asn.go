@@ -0,0 +1,13 @@
+package entity
+
+type AsnInfo struct {
+	Asn              uint32 `json:"asn,omitempty"`
+	RirName          string `json:"rirName"`
+	CountryCode      string `json:"countryCode"`
+	AsnRangeStart    string `json:"asnRangeStart"`
+	AsnRangeEnd      string `json:"asnRangeEnd"`
+	AsnRangeQuantity string `json:"asnRangeQuantity"`
+	Status           string `json:"status"`
+	StatusUpdatedAt  string `json:"statusUpdatedAt"`
+	HolderId         string `json:"holderId"`
+}
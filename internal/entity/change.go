@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+type RangeChange struct {
+	Id             int64     `json:"id"`
+	RirName        string    `json:"rirName"`
+	Serial         string    `json:"serial,omitempty"`
+	PublishedAt    time.Time `json:"publishedAt"`
+	Kind           string    `json:"kind"`
+	RangeType      string    `json:"rangeType"`
+	RangeStart     string    `json:"rangeStart"`
+	RangeEnd       string    `json:"rangeEnd"`
+	OldCountryCode string    `json:"oldCountryCode,omitempty"`
+	NewCountryCode string    `json:"newCountryCode,omitempty"`
+	OldStatus      string    `json:"oldStatus,omitempty"`
+	NewStatus      string    `json:"newStatus,omitempty"`
+}
+
+// Since serial is a serial of the rir, changes of later snapshots are returned
+type ChangeQuery struct {
+	RirName     string
+	SinceSerial string
+	SinceTime   time.Time
+	// Only changes of one snapshot, 0 for all
+	SnapshotId int64
+	After      int64
+	Limit      int
+}
@@ -0,0 +1,19 @@
+package entity
+
+type ExportQuery struct {
+	Format string
+	// Empty for ranges of all countries
+	CountryCodes []string
+	// ipv4 or ipv6, empty for both
+	Family string
+	// Name of ipset sets or nftables table and sets
+	Name string
+	// Empty for delegated ranges: allocated and assigned
+	Statuses []string
+}
+
+// Rendered list with data version it is built from
+type ExportList struct {
+	Version string
+	Body    []byte
+}
@@ -0,0 +1,7 @@
+package entity
+
+type HolderInfo struct {
+	HolderId  string         `json:"holderId"`
+	IpRanges  []*IpRangeInfo `json:"ipRanges"`
+	AsnRanges []*AsnInfo     `json:"asnRanges"`
+}
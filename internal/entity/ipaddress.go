@@ -8,8 +8,10 @@ type IpAddressInfo struct {
 	IpRangeStart     string `json:"ipRangeStart"`
 	IpRangeEnd       string `json:"ipRangeEnd"`
 	IpRangeQuantity  string `json:"ipRangeQuantity"`
+	IpRangePrefix    string `json:"ipRangePrefix"`
 	Status           string `json:"status"`
 	StatusUpdatedAt  string `json:"statusUpdatedAt"`
+	HolderId         string `json:"holderId"`
 }
 
 func NewIpAddressInfo() *IpAddressInfo {
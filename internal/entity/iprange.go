@@ -0,0 +1,39 @@
+package entity
+
+import "net/netip"
+
+type IpRangeRelation string
+
+const (
+	IpRangeCovers    IpRangeRelation = "covers"
+	IpRangeContained IpRangeRelation = "contained"
+	IpRangePartial   IpRangeRelation = "partial"
+)
+
+type IpRangeInfo struct {
+	RirName          string          `json:"rirName"`
+	IpAddressVersion string          `json:"ipAddressVersion"`
+	CountryCode      string          `json:"countryCode"`
+	IpRangeStart     string          `json:"ipRangeStart"`
+	IpRangeEnd       string          `json:"ipRangeEnd"`
+	IpRangeQuantity  string          `json:"ipRangeQuantity"`
+	IpRangePrefix    string          `json:"ipRangePrefix"`
+	Status           string          `json:"status"`
+	StatusUpdatedAt  string          `json:"statusUpdatedAt"`
+	HolderId         string          `json:"holderId"`
+	Relation         IpRangeRelation `json:"relation,omitempty"`
+}
+
+// Ranges overlapping prefix, ordered by start, end and rir
+type PrefixQuery struct {
+	Prefix netip.Prefix
+	// Key of last range of previous page, nil for the first page
+	After *PrefixCursor
+	Limit int
+}
+
+type PrefixCursor struct {
+	Start   netip.Addr
+	End     netip.Addr
+	RirName string
+}
@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+// Empty filter matches everything, secret is returned only on create
+type WebhookSubscription struct {
+	Id           int64     `json:"id"`
+	Url          string    `json:"url"`
+	Secret       string    `json:"secret,omitempty"`
+	Events       []string  `json:"events"`
+	Rirs         []string  `json:"rirs"`
+	CountryCodes []string  `json:"countryCodes"`
+	Prefixes     []string  `json:"prefixes"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type WebhookDeadLetter struct {
+	Id        int64     `json:"id"`
+	EventId   string    `json:"eventId"`
+	EventType string    `json:"eventType"`
+	Payload   string    `json:"payload"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError"`
+	CreatedAt time.Time `json:"createdAt"`
+	FailedAt  time.Time `json:"failedAt"`
+}
+
+// Event serialized for one subscription, same event has the same id for all subscriptions
+type WebhookDelivery struct {
+	SubscriptionId int64
+	EventId        string
+	EventType      string
+	Payload        string
+}
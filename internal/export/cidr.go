@@ -0,0 +1,33 @@
+package export
+
+import (
+	"net/netip"
+
+	"github.com/KeilWin/ipinfo/internal/utils"
+)
+
+// Smallest list of prefixes exactly covering range from first to last address inclusive
+func SplitRange(first, last netip.Addr) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, 1)
+	if !first.IsValid() || first.Is4() != last.Is4() || last.Less(first) {
+		return prefixes
+	}
+
+	for {
+		// Widest prefix which starts at first and doesn't go past last
+		var prefix netip.Prefix
+		for bits := 0; bits <= first.BitLen(); bits++ {
+			prefix = netip.PrefixFrom(first, bits)
+			if prefix.Masked().Addr() == first && !last.Less(utils.LastAddr(prefix)) {
+				break
+			}
+		}
+		prefixes = append(prefixes, prefix)
+
+		end := utils.LastAddr(prefix)
+		if end == last {
+			return prefixes
+		}
+		first = end.Next()
+	}
+}
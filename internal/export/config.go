@@ -0,0 +1,63 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+	"github.com/KeilWin/ipinfo/internal/utils"
+)
+
+const componentName = "EXPORT"
+
+// Every setting is optional, export is made only to configured paths
+type ExportConfig struct {
+	common.Config
+
+	BasePrefix string
+
+	// MaxMind DB rewritten when published data changed
+	MmdbPath string
+	// Pause between checks of data version, updates published meanwhile are exported once
+	Interval time.Duration
+}
+
+func (p *ExportConfig) NewVariableName(name string) string {
+	return fmt.Sprintf("%s_%s", p.BasePrefix, name)
+}
+
+func (p *ExportConfig) Load() error {
+	p.MmdbPath = os.Getenv(p.NewVariableName("MMDB_PATH"))
+
+	name := p.NewVariableName("INTERVAL")
+	seconds := 60
+	if value := os.Getenv(name); value != "" {
+		var err error
+		if seconds, err = strconv.Atoi(value); err != nil {
+			CheckLoadExportConfigError(err, name)
+			return errors.New("loading export config")
+		}
+	}
+	p.Interval = time.Duration(seconds) * time.Second
+	return nil
+}
+
+func (p *ExportConfig) Check() error {
+	if p.Interval <= 0 {
+		return errors.New("export interval must be positive")
+	}
+	return nil
+}
+
+func NewExportConfig(appPrefix string) *ExportConfig {
+	return &ExportConfig{
+		BasePrefix: common.NewBasePrefix(appPrefix, componentName),
+	}
+}
+
+func CheckLoadExportConfigError(err error, name string) bool {
+	return utils.CheckLoadConfigError(err, name, componentName)
+}
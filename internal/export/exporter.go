@@ -0,0 +1,330 @@
+package export
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+const (
+	MmdbDatabaseType = "IpInfo-Delegations"
+	mmdbDescription  = "IP ranges delegated by regional internet registries"
+)
+
+// Range of ip_ranges with inclusive last address
+type Range struct {
+	First netip.Addr
+	Last  netip.Addr
+	Row   *database.IpAddressInfoRow
+}
+
+func NewRanges(rows []*database.IpAddressInfoRow) ([]*Range, error) {
+	ranges := make([]*Range, 0, len(rows))
+	for _, row := range rows {
+		first, err := netip.ParseAddr(row.IpRangeStart)
+		if err != nil {
+			return nil, fmt.Errorf("parse range start of '%s': %w", row.Id, err)
+		}
+		end, err := netip.ParseAddr(row.IpRangeEnd)
+		if err != nil {
+			return nil, fmt.Errorf("parse range end of '%s': %w", row.Id, err)
+		}
+		// End is exclusive
+		last := end.Prev()
+		if first.Is4() != end.Is4() || !last.IsValid() || last.Less(first) {
+			return nil, fmt.Errorf("bad range '%s': %s - %s", row.Id, row.IpRangeStart, row.IpRangeEnd)
+		}
+		ranges = append(ranges, &Range{First: first, Last: last, Row: row})
+	}
+	return ranges, nil
+}
+
+// Schema of MaxMind DB record, see README
+type MmdbRecord struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Rir      string `maxminddb:"rir"`
+	Status   string `maxminddb:"status"`
+	Prefix   string `maxminddb:"prefix"`
+	Date     string `maxminddb:"date"`
+	HolderId string `maxminddb:"holder_id"`
+	Range    struct {
+		Start string `maxminddb:"start"`
+		End   string `maxminddb:"end"`
+	} `maxminddb:"range"`
+}
+
+// Empty values are omitted
+func newMmdbRecord(ipRange *Range) map[string]any {
+	record := map[string]any{
+		"rir":    ipRange.Row.RirName,
+		"status": ipRange.Row.Status,
+		"range": map[string]any{
+			"start": ipRange.First.String(),
+			"end":   ipRange.Last.String(),
+		},
+	}
+	if ipRange.Row.CountryCode != "" {
+		record["country"] = map[string]any{"iso_code": ipRange.Row.CountryCode}
+	}
+	if prefixes := SplitRange(ipRange.First, ipRange.Last); len(prefixes) == 1 {
+		record["prefix"] = prefixes[0].String()
+	}
+	if ipRange.Row.StatusUpdatedAt != "" {
+		record["date"] = ipRange.Row.StatusUpdatedAt
+	}
+	if ipRange.Row.HolderId != "" {
+		record["holder_id"] = ipRange.Row.HolderId
+	}
+	return record
+}
+
+type mmdbNetwork struct {
+	prefix netip.Prefix
+	record uint32
+}
+
+// Writes ip_ranges to files of configured formats
+type Exporter struct {
+	db     database.Database
+	config *ExportConfig
+	// Data version of last written MaxMind DB
+	version string
+	// Export and on demand writes don't race for the same file
+	mu sync.Mutex
+}
+
+func (p *Exporter) dataVersion(ctx context.Context) (string, error) {
+	version, err := p.db.GetOption(database.DataVersionOptionName, ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return version, err
+}
+
+// Current data is treated as exported, file of previous run is kept until data changes
+func (p *Exporter) Observe(ctx context.Context) error {
+	version, err := p.dataVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("get data version: %w", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.version = version
+	return nil
+}
+
+// MaxMind DB is rewritten when data version changed since last export, nothing is done without configured path
+func (p *Exporter) Export(ctx context.Context) error {
+	if p.config.MmdbPath == "" {
+		return nil
+	}
+	// Version is taken before ranges, update published meanwhile is exported next time
+	version, err := p.dataVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("get data version: %w", err)
+	}
+	p.mu.Lock()
+	exported := p.version
+	p.mu.Unlock()
+	if version == exported {
+		return nil
+	}
+	if err = p.WriteMmdb(ctx, p.config.MmdbPath); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.version = version
+	return nil
+}
+
+// Checks data version every interval, so updates of several rirs published at once are exported once
+func (p *Exporter) Run(ctx context.Context) {
+	if p.config.MmdbPath == "" {
+		return
+	}
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := p.Export(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("export", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tree of ranges, count of inserted networks is returned with it
+func newRangesMmdb(ranges []*Range, buildEpoch uint64) (*MmdbWriter, int, error) {
+	writer := NewMmdbWriter(MmdbDatabaseType, mmdbDescription, buildEpoch)
+	networks := make([]mmdbNetwork, 0, len(ranges))
+	for _, ipRange := range ranges {
+		record, err := writer.AddRecord(newMmdbRecord(ipRange))
+		if err != nil {
+			return nil, 0, fmt.Errorf("encode range '%s': %w", ipRange.Row.Id, err)
+		}
+		for _, prefix := range SplitRange(ipRange.First, ipRange.Last) {
+			networks = append(networks, mmdbNetwork{prefix: prefix, record: record})
+		}
+	}
+	// More specific prefixes of nested ranges are inserted last and win
+	slices.SortStableFunc(networks, func(a, b mmdbNetwork) int {
+		return cmp.Compare(a.prefix.Bits(), b.prefix.Bits())
+	})
+	for _, network := range networks {
+		if err := writer.Insert(network.prefix, network.record); err != nil {
+			return nil, 0, err
+		}
+	}
+	return writer, len(networks), nil
+}
+
+// File is replaced only after it is read back and every range is found in it
+func (p *Exporter) WriteMmdb(ctx context.Context, path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rows, err := p.db.GetIpRanges(ctx)
+	if err != nil {
+		return fmt.Errorf("get ip ranges: %w", err)
+	}
+	ranges, err := NewRanges(rows)
+	if err != nil {
+		return err
+	}
+
+	writer, networks, err := newRangesMmdb(ranges, uint64(time.Now().Unix()))
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	write := func(w io.Writer) (err error) {
+		size, err = writer.WriteTo(w)
+		return err
+	}
+	verify := func(path string) error {
+		return VerifyMmdb(path, ranges, writer.NodeCount())
+	}
+	if err = replaceFile(path, write, verify); err != nil {
+		return err
+	}
+	slog.Info("mmdb exported", "path", path, "ranges", len(ranges), "networks", networks, "nodes", writer.NodeCount(), "bytes", size)
+	return nil
+}
+
+// Ranges of query in text format are written to path, to output when path is empty
+func (p *Exporter) WriteList(ctx context.Context, path string, query *entity.ExportQuery, output io.Writer) error {
+	rows, err := p.db.GetIpRanges(ctx)
+	if err != nil {
+		return fmt.Errorf("get ip ranges: %w", err)
+	}
+	ranges, err := NewRanges(rows)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return WriteList(output, ranges, query)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	write := func(w io.Writer) error {
+		return WriteList(w, ranges, query)
+	}
+	if err = replaceFile(path, write, nil); err != nil {
+		return err
+	}
+	slog.Info("list exported", "path", path, "format", query.Format, "ranges", len(ranges))
+	return nil
+}
+
+// Readers see either old or new complete file, new one replaces old only after it is written and verified
+func replaceFile(path string, write func(w io.Writer) error, verify func(path string) error) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	err = write(file)
+	if err == nil {
+		err = file.Chmod(0o644)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+
+	if verify != nil {
+		if err = verify(file.Name()); err != nil {
+			return fmt.Errorf("verify %s: %w", path, err)
+		}
+	}
+	if err = os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// First and last address of every range are looked up by MaxMind DB reader
+func VerifyMmdb(path string, ranges []*Range, nodeCount int) error {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if reader.Metadata.DatabaseType != MmdbDatabaseType || int(reader.Metadata.NodeCount) != nodeCount {
+		return fmt.Errorf("unexpected metadata: type %s, %d nodes", reader.Metadata.DatabaseType, reader.Metadata.NodeCount)
+	}
+	if err = reader.Verify(); err != nil {
+		return err
+	}
+	for _, ipRange := range ranges {
+		for _, addr := range []netip.Addr{ipRange.First, ipRange.Last} {
+			record := &MmdbRecord{}
+			if err = reader.Lookup(net.IP(addr.AsSlice()), record); err != nil {
+				return fmt.Errorf("lookup %s: %w", addr, err)
+			}
+			// Nested ranges are answered by more specific one, it has to contain address anyway
+			start, startErr := netip.ParseAddr(record.Range.Start)
+			end, endErr := netip.ParseAddr(record.Range.End)
+			if startErr != nil || endErr != nil || addr.Less(start) || end.Less(addr) || record.Rir == "" {
+				return fmt.Errorf("%s of range '%s' is not found, got %+v", addr, ipRange.Row.Id, record)
+			}
+		}
+	}
+	return nil
+}
+
+func NewExporter(db database.Database, config *ExportConfig) *Exporter {
+	return &Exporter{
+		db:     db,
+		config: config,
+	}
+}
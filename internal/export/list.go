@@ -0,0 +1,243 @@
+package export
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+type Format string
+
+const (
+	MmdbFormat     Format = "mmdb"
+	NginxFormat    Format = "nginx"
+	HaproxyFormat  Format = "haproxy"
+	IpsetFormat    Format = "ipset"
+	NftablesFormat Format = "nftables"
+	PlainFormat    Format = "plain"
+)
+
+// Text formats of aggregated prefixes
+var ListFormats = []Format{NginxFormat, HaproxyFormat, IpsetFormat, NftablesFormat, PlainFormat}
+
+const (
+	Ipv4Family = "ipv4"
+	Ipv6Family = "ipv6"
+
+	DefaultListName = "ipinfo"
+	// Elements of one nftables add element command
+	nftablesChunkSize = 1000
+	// Default maxelem of ipset
+	ipsetMinMaxElem = 65536
+)
+
+// Statuses of ranges, delegated ones are listed by default
+var (
+	Statuses        = []string{"allocated", "assigned", "available", "reserved"}
+	DefaultStatuses = []string{"allocated", "assigned"}
+)
+
+// Fits ipset limit of 31 characters with family suffix
+var listNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,27}$`)
+
+var ErrQuery = errors.New("bad export query")
+
+// Country codes are upper cased, statuses lower cased, empty name and statuses are replaced by default
+func CheckQuery(query *entity.ExportQuery) error {
+	if !slices.Contains(ListFormats, Format(query.Format)) {
+		return fmt.Errorf("%w: unknown format '%s'", ErrQuery, query.Format)
+	}
+	for i, countryCode := range query.CountryCodes {
+		countryCode = strings.ToUpper(countryCode)
+		if len(countryCode) != 2 || countryCode[0] < 'A' || countryCode[0] > 'Z' || countryCode[1] < 'A' || countryCode[1] > 'Z' {
+			return fmt.Errorf("%w: invalid country code '%s'", ErrQuery, countryCode)
+		}
+		query.CountryCodes[i] = countryCode
+	}
+	if query.Family != "" && query.Family != Ipv4Family && query.Family != Ipv6Family {
+		return fmt.Errorf("%w: unknown family '%s', expected %s or %s", ErrQuery, query.Family, Ipv4Family, Ipv6Family)
+	}
+	for i, status := range query.Statuses {
+		status = strings.ToLower(status)
+		if !slices.Contains(Statuses, status) {
+			return fmt.Errorf("%w: unknown status '%s', expected %s", ErrQuery, status, strings.Join(Statuses, ", "))
+		}
+		query.Statuses[i] = status
+	}
+	if len(query.Statuses) == 0 {
+		query.Statuses = slices.Clone(DefaultStatuses)
+	}
+	if query.Name == "" {
+		query.Name = DefaultListName
+	}
+	if !listNamePattern.MatchString(query.Name) {
+		return fmt.Errorf("%w: invalid name '%s', expected up to 28 letters, digits or underscores", ErrQuery, query.Name)
+	}
+	return nil
+}
+
+// Ranges of query family, countries and statuses, ranges without country are kept only when countries are not given
+func FilterRanges(ranges []*Range, query *entity.ExportQuery) []*Range {
+	statuses := query.Statuses
+	if len(statuses) == 0 {
+		statuses = DefaultStatuses
+	}
+	filtered := make([]*Range, 0, len(ranges))
+	for _, ipRange := range ranges {
+		if !slices.Contains(statuses, ipRange.Row.Status) {
+			continue
+		}
+		if query.Family == Ipv4Family && !ipRange.First.Is4() || query.Family == Ipv6Family && ipRange.First.Is4() {
+			continue
+		}
+		if len(query.CountryCodes) != 0 && !slices.Contains(query.CountryCodes, ipRange.Row.CountryCode) {
+			continue
+		}
+		filtered = append(filtered, ipRange)
+	}
+	return filtered
+}
+
+// Overlapping and adjacent ranges are merged and split into minimal list of prefixes, ipv4 first
+func Aggregate(ranges []*Range) []netip.Prefix {
+	sorted := slices.SortedFunc(slices.Values(ranges), func(a, b *Range) int {
+		return a.First.Compare(b.First)
+	})
+
+	prefixes := make([]netip.Prefix, 0, len(sorted))
+	var first, last netip.Addr
+	for _, ipRange := range sorted {
+		if last.IsValid() && ipRange.First.Is4() == last.Is4() {
+			// Invalid next is past the last address of family, every next range is merged
+			next := last.Next()
+			if !next.IsValid() || !next.Less(ipRange.First) {
+				if last.Less(ipRange.Last) {
+					last = ipRange.Last
+				}
+				continue
+			}
+		}
+		if last.IsValid() {
+			prefixes = append(prefixes, SplitRange(first, last)...)
+		}
+		first, last = ipRange.First, ipRange.Last
+	}
+	if last.IsValid() {
+		prefixes = append(prefixes, SplitRange(first, last)...)
+	}
+	return prefixes
+}
+
+type countryPrefix struct {
+	prefix      netip.Prefix
+	countryCode string
+}
+
+// Prefixes aggregated per country, ordered by address
+func aggregateByCountry(ranges []*Range) []countryPrefix {
+	countries := make(map[string][]*Range)
+	for _, ipRange := range ranges {
+		if ipRange.Row.CountryCode != "" {
+			countries[ipRange.Row.CountryCode] = append(countries[ipRange.Row.CountryCode], ipRange)
+		}
+	}
+	entries := make([]countryPrefix, 0, len(ranges))
+	for countryCode, countryRanges := range countries {
+		for _, prefix := range Aggregate(countryRanges) {
+			entries = append(entries, countryPrefix{prefix: prefix, countryCode: countryCode})
+		}
+	}
+	slices.SortFunc(entries, func(a, b countryPrefix) int {
+		return cmp.Or(a.prefix.Addr().Compare(b.prefix.Addr()), cmp.Compare(a.prefix.Bits(), b.prefix.Bits()))
+	})
+	return entries
+}
+
+func splitFamilies(prefixes []netip.Prefix) (ipv4, ipv6 []netip.Prefix) {
+	i, _ := slices.BinarySearchFunc(prefixes, true, func(prefix netip.Prefix, _ bool) int {
+		if prefix.Addr().Is4() {
+			return -1
+		}
+		return 1
+	})
+	return prefixes[:i], prefixes[i:]
+}
+
+// Writes filtered ranges of query in text format:
+//   - nginx: "prefix CC;" lines for include in geo block
+//   - haproxy: "prefix CC" lines for map_ip
+//   - ipset: ipset restore script of hash:net sets <name>_v4 and <name>_v6
+//   - nftables: nft -f script of interval sets <name>_v4 and <name>_v6 in inet table <name>
+//   - plain: prefix per line
+func WriteList(w io.Writer, ranges []*Range, query *entity.ExportQuery) error {
+	output := bufio.NewWriterSize(w, 1<<16)
+	ranges = FilterRanges(ranges, query)
+
+	switch Format(query.Format) {
+	case NginxFormat, HaproxyFormat:
+		suffix := ""
+		if Format(query.Format) == NginxFormat {
+			suffix = ";"
+		}
+		for _, entry := range aggregateByCountry(ranges) {
+			fmt.Fprintf(output, "%s %s%s\n", entry.prefix, entry.countryCode, suffix)
+		}
+	case PlainFormat:
+		for _, prefix := range Aggregate(ranges) {
+			fmt.Fprintln(output, prefix)
+		}
+	case IpsetFormat:
+		ipv4, ipv6 := splitFamilies(Aggregate(ranges))
+		for _, set := range newSets(query, ipv4, ipv6) {
+			fmt.Fprintf(output, "create %s hash:net family %s maxelem %d -exist\n", set.name, set.ipsetFamily, max(ipsetMinMaxElem, len(set.prefixes)))
+			fmt.Fprintf(output, "flush %s\n", set.name)
+			for _, prefix := range set.prefixes {
+				fmt.Fprintf(output, "add %s %s\n", set.name, prefix)
+			}
+		}
+	case NftablesFormat:
+		ipv4, ipv6 := splitFamilies(Aggregate(ranges))
+		fmt.Fprintf(output, "add table inet %s\n", query.Name)
+		for _, set := range newSets(query, ipv4, ipv6) {
+			fmt.Fprintf(output, "add set inet %s %s { type %s; flags interval; }\n", query.Name, set.name, set.nftablesType)
+			fmt.Fprintf(output, "flush set inet %s %s\n", query.Name, set.name)
+			for chunk := range slices.Chunk(set.prefixes, nftablesChunkSize) {
+				elements := make([]string, len(chunk))
+				for i, prefix := range chunk {
+					elements[i] = prefix.String()
+				}
+				fmt.Fprintf(output, "add element inet %s %s { %s }\n", query.Name, set.name, strings.Join(elements, ", "))
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown format '%s'", ErrQuery, query.Format)
+	}
+	return output.Flush()
+}
+
+type prefixSet struct {
+	name         string
+	ipsetFamily  string
+	nftablesType string
+	prefixes     []netip.Prefix
+}
+
+// Set of every family of query, even empty one, so loaded set is flushed when all its ranges are gone
+func newSets(query *entity.ExportQuery, ipv4, ipv6 []netip.Prefix) []*prefixSet {
+	sets := make([]*prefixSet, 0, 2)
+	if query.Family != Ipv6Family {
+		sets = append(sets, &prefixSet{name: query.Name + "_v4", ipsetFamily: "inet", nftablesType: "ipv4_addr", prefixes: ipv4})
+	}
+	if query.Family != Ipv4Family {
+		sets = append(sets, &prefixSet{name: query.Name + "_v6", ipsetFamily: "inet6", nftablesType: "ipv6_addr", prefixes: ipv6})
+	}
+	return sets
+}
@@ -0,0 +1,219 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/utils"
+)
+
+// Range of first and exclusive end as stored
+func newTestRange(t *testing.T, start, end, countryCode, status string) *Range {
+	t.Helper()
+	ranges, err := NewRanges([]*database.IpAddressInfoRow{{Id: start, IpRangeStart: start, IpRangeEnd: end, CountryCode: countryCode, Status: status}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ranges[0]
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	values := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		values[i] = prefix.String()
+	}
+	return values
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges [][2]string
+		want   []string
+	}{
+		{"empty", nil, []string{}},
+		{"adjacent", [][2]string{{"10.0.1.0", "10.0.2.0"}, {"10.0.0.0", "10.0.1.0"}}, []string{"10.0.0.0/23"}},
+		{"overlapping", [][2]string{{"10.0.0.0", "10.0.3.0"}, {"10.0.2.0", "10.0.4.0"}}, []string{"10.0.0.0/22"}},
+		{"nested", [][2]string{{"10.0.0.0", "11.0.0.0"}, {"10.1.0.0", "10.2.0.0"}}, []string{"10.0.0.0/8"}},
+		{"gap", [][2]string{{"10.0.0.0", "10.0.1.0"}, {"10.0.2.0", "10.0.3.0"}}, []string{"10.0.0.0/24", "10.0.2.0/24"}},
+		{"not cidr", [][2]string{{"10.0.0.0", "10.0.3.0"}}, []string{"10.0.0.0/23", "10.0.2.0/24"}},
+		// Invalid next address of family end doesn't stop merging
+		{"end of ipv4", [][2]string{{"255.255.255.0", "::"}, {"255.255.254.0", "255.255.255.0"}}, []string{"255.255.254.0/23"}},
+		{"end of ipv6", [][2]string{{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00", "::"}, {"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff80", "::"}}, []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00/120"}},
+		// Families are not merged, ipv4 goes first
+		{"families", [][2]string{{"2001:db8::", "2001:db9::"}, {"10.0.0.0", "10.0.1.0"}}, []string{"10.0.0.0/24", "2001:db8::/32"}},
+	}
+	for _, test := range tests {
+		ranges := make([]*Range, 0, len(test.ranges))
+		for _, bounds := range test.ranges {
+			ranges = append(ranges, newTestEndRange(t, bounds[0], bounds[1]))
+		}
+		if got := prefixStrings(Aggregate(ranges)); !slices.Equal(got, test.want) {
+			t.Errorf("%s: Aggregate = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// Range up to the last address of family when end is "::", stored end is past it
+func newTestEndRange(t *testing.T, start, end string) *Range {
+	t.Helper()
+	if end != "::" {
+		return newTestRange(t, start, end, "", "allocated")
+	}
+	first := netip.MustParseAddr(start)
+	last := utils.LastAddr(netip.PrefixFrom(first, 0))
+	return &Range{First: first, Last: last, Row: &database.IpAddressInfoRow{Status: "allocated"}}
+}
+
+func TestSplitFamilies(t *testing.T) {
+	tests := []struct {
+		prefixes []string
+		ipv4     []string
+		ipv6     []string
+	}{
+		{[]string{}, []string{}, []string{}},
+		{[]string{"10.0.0.0/8", "192.168.0.0/16"}, []string{"10.0.0.0/8", "192.168.0.0/16"}, []string{}},
+		{[]string{"::/8", "2001:db8::/32"}, []string{}, []string{"::/8", "2001:db8::/32"}},
+		{[]string{"10.0.0.0/8", "192.168.0.0/16", "::/8", "2001:db8::/32"}, []string{"10.0.0.0/8", "192.168.0.0/16"}, []string{"::/8", "2001:db8::/32"}},
+	}
+	for _, test := range tests {
+		prefixes := make([]netip.Prefix, len(test.prefixes))
+		for i, value := range test.prefixes {
+			prefixes[i] = netip.MustParsePrefix(value)
+		}
+		ipv4, ipv6 := splitFamilies(prefixes)
+		if !slices.Equal(prefixStrings(ipv4), test.ipv4) || !slices.Equal(prefixStrings(ipv6), test.ipv6) {
+			t.Errorf("splitFamilies(%v) = %v, %v, want %v, %v", test.prefixes, ipv4, ipv6, test.ipv4, test.ipv6)
+		}
+	}
+}
+
+func newTestListRanges(t *testing.T) []*Range {
+	t.Helper()
+	return []*Range{
+		newTestRange(t, "10.0.0.0", "10.0.1.0", "RU", "allocated"),
+		newTestRange(t, "10.0.1.0", "10.0.2.0", "RU", "assigned"),
+		newTestRange(t, "10.0.2.0", "10.0.3.0", "CN", "allocated"),
+		newTestRange(t, "10.0.3.0", "10.0.4.0", "", "allocated"),
+		// Not delegated, left out by default
+		newTestRange(t, "10.0.4.0", "10.0.5.0", "", "available"),
+		newTestRange(t, "10.0.5.0", "10.0.6.0", "RU", "reserved"),
+		newTestRange(t, "2001:db8::", "2001:db9::", "CN", "allocated"),
+	}
+}
+
+func writeTestList(t *testing.T, query *entity.ExportQuery) string {
+	t.Helper()
+	if err := CheckQuery(query); err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	if err := WriteList(output, newTestListRanges(t), query); err != nil {
+		t.Fatal(err)
+	}
+	return output.String()
+}
+
+func TestWriteList(t *testing.T) {
+	tests := []struct {
+		name  string
+		query entity.ExportQuery
+		want  []string
+	}{
+		{"plain", entity.ExportQuery{Format: "plain"}, []string{
+			"10.0.0.0/22",
+			"2001:db8::/32",
+		}},
+		{"plain of countries", entity.ExportQuery{Format: "plain", CountryCodes: []string{"ru", "CN"}, Family: Ipv4Family}, []string{
+			"10.0.0.0/23",
+			"10.0.2.0/24",
+		}},
+		{"plain of statuses", entity.ExportQuery{Format: "plain", Statuses: []string{"Available", "reserved"}}, []string{
+			"10.0.4.0/23",
+		}},
+		{"nginx", entity.ExportQuery{Format: "nginx"}, []string{
+			"10.0.0.0/23 RU;",
+			"10.0.2.0/24 CN;",
+			"2001:db8::/32 CN;",
+		}},
+		{"haproxy", entity.ExportQuery{Format: "haproxy", Family: Ipv6Family}, []string{
+			"2001:db8::/32 CN",
+		}},
+		{"ipset", entity.ExportQuery{Format: "ipset", CountryCodes: []string{"RU"}}, []string{
+			"create ipinfo_v4 hash:net family inet maxelem 65536 -exist",
+			"flush ipinfo_v4",
+			"add ipinfo_v4 10.0.0.0/23",
+			// Empty set is flushed too
+			"create ipinfo_v6 hash:net family inet6 maxelem 65536 -exist",
+			"flush ipinfo_v6",
+		}},
+		{"nftables", entity.ExportQuery{Format: "nftables", Name: "geo"}, []string{
+			"add table inet geo",
+			"add set inet geo geo_v4 { type ipv4_addr; flags interval; }",
+			"flush set inet geo geo_v4",
+			"add element inet geo geo_v4 { 10.0.0.0/22 }",
+			"add set inet geo geo_v6 { type ipv6_addr; flags interval; }",
+			"flush set inet geo geo_v6",
+			"add element inet geo geo_v6 { 2001:db8::/32 }",
+		}},
+	}
+	for _, test := range tests {
+		got := strings.Split(strings.TrimSuffix(writeTestList(t, &test.query), "\n"), "\n")
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: WriteList =\n%s\nwant\n%s", test.name, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
+
+func TestWriteNftablesChunks(t *testing.T) {
+	ranges := make([]*Range, 0, nftablesChunkSize+1)
+	addr := netip.MustParseAddr("10.0.0.0")
+	for range nftablesChunkSize + 1 {
+		// Gaps keep ranges from being merged
+		ranges = append(ranges, &Range{First: addr, Last: addr, Row: &database.IpAddressInfoRow{Status: "allocated"}})
+		addr = addr.Next().Next()
+	}
+	query := &entity.ExportQuery{Format: "nftables", Family: Ipv4Family}
+	if err := CheckQuery(query); err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	if err := WriteList(output, ranges, query); err != nil {
+		t.Fatal(err)
+	}
+	if count := strings.Count(output.String(), "add element inet ipinfo ipinfo_v4 {"); count != 2 {
+		t.Fatalf("add element commands = %d, want 2", count)
+	}
+}
+
+func TestCheckQuery(t *testing.T) {
+	for _, query := range []entity.ExportQuery{
+		{Format: "mmdb"},
+		{Format: "csv"},
+		{Format: "plain", CountryCodes: []string{"RUS"}},
+		{Format: "plain", CountryCodes: []string{"R1"}},
+		{Format: "plain", Family: "ipv5"},
+		{Format: "plain", Name: "1st"},
+		{Format: "plain", Name: "set-name"},
+		{Format: "plain", Name: strings.Repeat("a", 29)},
+		{Format: "plain", Statuses: []string{"unknown"}},
+	} {
+		if err := CheckQuery(&query); !errors.Is(err, ErrQuery) {
+			t.Errorf("CheckQuery(%+v) = %v, want ErrQuery", query, err)
+		}
+	}
+
+	query := &entity.ExportQuery{Format: "plain", CountryCodes: []string{"ru"}}
+	if err := CheckQuery(query); err != nil {
+		t.Fatal(err)
+	}
+	if query.Name != DefaultListName || !slices.Equal(query.CountryCodes, []string{"RU"}) || !slices.Equal(query.Statuses, DefaultStatuses) {
+		t.Fatalf("checked query = %+v, want defaults", query)
+	}
+}
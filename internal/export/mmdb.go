@@ -0,0 +1,362 @@
+package export
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/netip"
+	"slices"
+)
+
+// MaxMind DB format: https://maxmind.github.io/MaxMind-DB/
+const (
+	mmdbDataSeparatorSize = 16
+	mmdbMetadataMarker    = "\xab\xcd\xefMaxMind.com"
+	// Ipv4 addresses are stored in ipv6 tree as ::a.b.c.d
+	mmdbIpv4Depth = 96
+)
+
+// Data section types
+const (
+	mmdbPointer = 1
+	mmdbString  = 2
+	mmdbUint16  = 5
+	mmdbUint32  = 6
+	mmdbMap     = 7
+	mmdbUint64  = 9
+	mmdbArray   = 11
+	mmdbBoolean = 14
+)
+
+var ErrMmdbPrefix = errors.New("prefix can't be inserted")
+
+// Child of node: 0 is empty, positive is index of node, negative is -(offset+1) of data
+type mmdbNode struct {
+	children [2]int32
+}
+
+// Writes ipv6 MaxMind DB with ipv4 subtree aliased from ::ffff:0:0/96. Prefixes are inserted
+// over previous ones, so more specific prefixes have to be inserted after less specific.
+type MmdbWriter struct {
+	DatabaseType string
+	Description  string
+	BuildEpoch   uint64
+
+	nodes []mmdbNode
+	data  *mmdbEncoder
+	// Smallest record size is chosen when it fits, larger one can be forced
+	minRecordSize int
+}
+
+func NewMmdbWriter(databaseType, description string, buildEpoch uint64) *MmdbWriter {
+	return &MmdbWriter{
+		DatabaseType: databaseType,
+		Description:  description,
+		BuildEpoch:   buildEpoch,
+		nodes:        make([]mmdbNode, 1, 1<<16),
+		data:         newMmdbEncoder(true),
+	}
+}
+
+// Offset of encoded record, it is inserted by offset for every prefix it describes
+func (p *MmdbWriter) AddRecord(record map[string]any) (uint32, error) {
+	offset := len(p.data.buf)
+	if err := p.data.encode(record); err != nil {
+		return 0, err
+	}
+	return uint32(offset), nil
+}
+
+func (p *MmdbWriter) Insert(prefix netip.Prefix, record uint32) error {
+	prefix = prefix.Masked()
+	if !prefix.IsValid() {
+		return fmt.Errorf("%w: invalid prefix", ErrMmdbPrefix)
+	}
+	addr, bits := prefix.Addr().As16(), prefix.Bits()
+	if prefix.Addr().Is4() {
+		addr = [16]byte{}
+		ipv4 := prefix.Addr().As4()
+		copy(addr[12:], ipv4[:])
+		bits += mmdbIpv4Depth
+	}
+	if bits == 0 {
+		return fmt.Errorf("%w: %s covers whole tree", ErrMmdbPrefix, prefix)
+	}
+	p.insert(addr, bits, -int32(record)-1)
+	return nil
+}
+
+func (p *MmdbWriter) insert(addr [16]byte, bits int, value int32) {
+	node := int32(0)
+	for depth := 0; depth < bits-1; depth++ {
+		bit := addr[depth/8] >> (7 - depth%8) & 1
+		child := p.nodes[node].children[bit]
+		if child <= 0 {
+			// Empty or data child is split into node with the same data on both sides
+			p.nodes = append(p.nodes, mmdbNode{children: [2]int32{child, child}})
+			child = int32(len(p.nodes) - 1)
+			p.nodes[node].children[bit] = child
+		}
+		node = child
+	}
+	depth := bits - 1
+	p.nodes[node].children[addr[depth/8]>>(7-depth%8)&1] = value
+}
+
+// Node of ipv4 subtree, 0 when there are no ipv4 prefixes
+func (p *MmdbWriter) ipv4Root() int32 {
+	node := int32(0)
+	for range mmdbIpv4Depth {
+		node = p.nodes[node].children[0]
+		if node <= 0 {
+			return 0
+		}
+	}
+	return node
+}
+
+func (p *MmdbWriter) recordSize(maxValue uint64) (int, error) {
+	for _, size := range []int{24, 28, 32} {
+		if size >= p.minRecordSize && maxValue < 1<<size {
+			return size, nil
+		}
+	}
+	return 0, fmt.Errorf("mmdb is too large: max record value %d", maxValue)
+}
+
+// Tree is finished by alias of ipv4 subtree, nothing can be inserted after
+func (p *MmdbWriter) WriteTo(w io.Writer) (int64, error) {
+	if root := p.ipv4Root(); root != 0 {
+		ipv4Mapped := netip.MustParseAddr("::ffff:0:0").As16()
+		p.insert(ipv4Mapped, mmdbIpv4Depth, root)
+	}
+
+	nodeCount := uint64(len(p.nodes))
+	recordSize, err := p.recordSize(nodeCount + mmdbDataSeparatorSize + uint64(len(p.data.buf)))
+	if err != nil {
+		return 0, err
+	}
+	value := func(child int32) uint64 {
+		switch {
+		case child == 0:
+			return nodeCount
+		case child > 0:
+			return uint64(child)
+		default:
+			return nodeCount + mmdbDataSeparatorSize + uint64(-child-1)
+		}
+	}
+
+	buffered := bufio.NewWriterSize(w, 1<<20)
+	output := &countingWriter{w: buffered}
+	node := make([]byte, recordSize/4)
+	for _, n := range p.nodes {
+		left, right := value(n.children[0]), value(n.children[1])
+		switch recordSize {
+		case 24:
+			node[0], node[1], node[2] = byte(left>>16), byte(left>>8), byte(left)
+			node[3], node[4], node[5] = byte(right>>16), byte(right>>8), byte(right)
+		case 28:
+			node[0], node[1], node[2] = byte(left>>16), byte(left>>8), byte(left)
+			node[3] = byte(left>>24)<<4 | byte(right>>24)&0x0f
+			node[4], node[5], node[6] = byte(right>>16), byte(right>>8), byte(right)
+		case 32:
+			node[0], node[1], node[2], node[3] = byte(left>>24), byte(left>>16), byte(left>>8), byte(left)
+			node[4], node[5], node[6], node[7] = byte(right>>24), byte(right>>16), byte(right>>8), byte(right)
+		}
+		output.Write(node)
+	}
+	output.Write(make([]byte, mmdbDataSeparatorSize))
+	output.Write(p.data.buf)
+
+	metadata := newMmdbEncoder(false)
+	err = metadata.encode(map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 p.BuildEpoch,
+		"database_type":               p.DatabaseType,
+		"description":                 map[string]any{"en": p.Description},
+		"ip_version":                  uint16(6),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+	})
+	if err != nil {
+		return output.n, err
+	}
+	output.Write([]byte(mmdbMetadataMarker))
+	output.Write(metadata.buf)
+	if output.err != nil {
+		return output.n, output.err
+	}
+	return output.n, buffered.Flush()
+}
+
+func (p *MmdbWriter) NodeCount() int {
+	return len(p.nodes)
+}
+
+// First error stops all next writes
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (p *countingWriter) Write(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	n, err := p.w.Write(b)
+	p.n += int64(n)
+	p.err = err
+	return n, err
+}
+
+// Data section encoder, repeated strings are replaced by pointers when it is shorter
+type mmdbEncoder struct {
+	buf     []byte
+	strings map[string]uint32
+}
+
+func newMmdbEncoder(pointers bool) *mmdbEncoder {
+	encoder := &mmdbEncoder{buf: make([]byte, 0, 1<<16)}
+	if pointers {
+		encoder.strings = make(map[string]uint32)
+	}
+	return encoder
+}
+
+func (p *mmdbEncoder) encode(value any) error {
+	switch v := value.(type) {
+	case string:
+		p.encodeString(v)
+	case uint16:
+		p.encodeUint(mmdbUint16, uint64(v))
+	case uint32:
+		p.encodeUint(mmdbUint32, uint64(v))
+	case uint64:
+		p.encodeUint(mmdbUint64, v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		p.encodeControl(mmdbBoolean, size)
+	case map[string]any:
+		p.encodeControl(mmdbMap, len(v))
+		// Sorted keys keep output reproducible
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			p.encodeString(key)
+			if err := p.encode(v[key]); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	case []any:
+		p.encodeControl(mmdbArray, len(v))
+		for _, item := range v {
+			if err := p.encode(item); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported mmdb value type %T", value)
+	}
+	return nil
+}
+
+func (p *mmdbEncoder) encodeString(value string) {
+	if p.strings != nil {
+		if offset, ok := p.strings[value]; ok && mmdbPointerSize(offset) < mmdbControlSize(len(value))+len(value) {
+			p.encodePointer(offset)
+			return
+		}
+		if _, ok := p.strings[value]; !ok {
+			p.strings[value] = uint32(len(p.buf))
+		}
+	}
+	p.encodeControl(mmdbString, len(value))
+	p.buf = append(p.buf, value...)
+}
+
+// Unsigned integers are stored big endian without leading zero bytes
+func (p *mmdbEncoder) encodeUint(typeNum byte, value uint64) {
+	size := 0
+	for v := value; v != 0; v >>= 8 {
+		size++
+	}
+	p.encodeControl(typeNum, size)
+	for i := size - 1; i >= 0; i-- {
+		p.buf = append(p.buf, byte(value>>(8*i)))
+	}
+}
+
+func (p *mmdbEncoder) encodeControl(typeNum byte, size int) {
+	control := typeNum << 5
+	if typeNum > 7 {
+		control = 0
+	}
+	var sizeBytes []byte
+	switch {
+	case size < 29:
+		control |= byte(size)
+	case size < 285:
+		control |= 29
+		sizeBytes = []byte{byte(size - 29)}
+	case size < 65821:
+		control |= 30
+		sizeBytes = []byte{byte((size - 285) >> 8), byte(size - 285)}
+	default:
+		control |= 31
+		sizeBytes = []byte{byte((size - 65821) >> 16), byte((size - 65821) >> 8), byte(size - 65821)}
+	}
+	p.buf = append(p.buf, control)
+	if typeNum > 7 {
+		p.buf = append(p.buf, typeNum-7)
+	}
+	p.buf = append(p.buf, sizeBytes...)
+}
+
+func (p *mmdbEncoder) encodePointer(offset uint32) {
+	control := byte(mmdbPointer << 5)
+	switch {
+	case offset < 1<<11:
+		p.buf = append(p.buf, control|byte(offset>>8), byte(offset))
+	case offset < 1<<19+2048:
+		v := offset - 2048
+		p.buf = append(p.buf, control|1<<3|byte(v>>16), byte(v>>8), byte(v))
+	case offset < 1<<27+526336:
+		v := offset - 526336
+		p.buf = append(p.buf, control|2<<3|byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		p.buf = append(p.buf, control|3<<3, byte(offset>>24), byte(offset>>16), byte(offset>>8), byte(offset))
+	}
+}
+
+func mmdbPointerSize(offset uint32) int {
+	switch {
+	case offset < 1<<11:
+		return 2
+	case offset < 1<<19+2048:
+		return 3
+	case offset < 1<<27+526336:
+		return 4
+	default:
+		return 5
+	}
+}
+
+func mmdbControlSize(size int) int {
+	switch {
+	case size < 29:
+		return 1
+	case size < 285:
+		return 2
+	case size < 65821:
+		return 3
+	default:
+		return 4
+	}
+}
@@ -0,0 +1,170 @@
+package export
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+func newTestRanges(t *testing.T) []*Range {
+	t.Helper()
+	rows := []*database.IpAddressInfoRow{
+		{Id: "1", RirName: "ripencc", CountryCode: "DE", IpRangeStart: "10.0.0.0", IpRangeEnd: "11.0.0.0", Status: "allocated", StatusUpdatedAt: "2010-07-12", HolderId: "holder-1"},
+		// Nested ranges
+		{Id: "2", RirName: "ripencc", CountryCode: "FR", IpRangeStart: "10.1.0.0", IpRangeEnd: "10.2.0.0", Status: "assigned"},
+		{Id: "3", RirName: "ripencc", CountryCode: "NL", IpRangeStart: "10.1.2.0", IpRangeEnd: "10.1.3.0", Status: "assigned"},
+		// Not a single CIDR, split into /23 and /24
+		{Id: "4", RirName: "arin", CountryCode: "US", IpRangeStart: "192.168.0.0", IpRangeEnd: "192.168.3.0", Status: "allocated"},
+		{Id: "5", RirName: "apnic", IpRangeStart: "2001:db8::", IpRangeEnd: "2001:db9::", Status: "reserved"},
+		{Id: "6", RirName: "apnic", CountryCode: "JP", IpRangeStart: "2001:db8:1::", IpRangeEnd: "2001:db8:2::", Status: "assigned"},
+	}
+	ranges, err := NewRanges(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ranges
+}
+
+func writeTestMmdb(t *testing.T, ranges []*Range, minRecordSize int) (string, *MmdbWriter) {
+	t.Helper()
+	writer, _, err := newRangesMmdb(ranges, 1700000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.minRecordSize = minRecordSize
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.WriteTo(file); err != nil {
+		t.Fatal(err)
+	}
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path, writer
+}
+
+func TestMmdbLookup(t *testing.T) {
+	ranges := newTestRanges(t)
+	for _, recordSize := range []int{24, 28, 32} {
+		path, writer := writeTestMmdb(t, ranges, recordSize)
+		if err := VerifyMmdb(path, ranges, writer.NodeCount()); err != nil {
+			t.Fatalf("record size %d: VerifyMmdb: %v", recordSize, err)
+		}
+
+		reader, err := maxminddb.Open(path)
+		if err != nil {
+			t.Fatalf("record size %d: %v", recordSize, err)
+		}
+		metadata := reader.Metadata
+		if metadata.RecordSize != uint(recordSize) || metadata.IPVersion != 6 || metadata.NodeCount != uint(writer.NodeCount()) ||
+			metadata.DatabaseType != MmdbDatabaseType || metadata.BuildEpoch != 1700000000 || metadata.Description["en"] != mmdbDescription {
+			t.Errorf("record size %d: metadata = %+v", recordSize, metadata)
+		}
+
+		tests := []struct {
+			addr    string
+			country string
+			prefix  string
+			start   string
+		}{
+			{"10.0.0.1", "DE", "10.0.0.0/8", "10.0.0.0"},
+			{"10.255.255.255", "DE", "10.0.0.0/8", "10.0.0.0"},
+			{"10.1.0.1", "FR", "10.1.0.0/16", "10.1.0.0"},
+			{"10.1.2.3", "NL", "10.1.2.0/24", "10.1.2.0"},
+			{"10.1.3.0", "FR", "10.1.0.0/16", "10.1.0.0"},
+			{"10.2.0.0", "DE", "10.0.0.0/8", "10.0.0.0"},
+			{"192.168.2.255", "US", "", "192.168.0.0"},
+			// Ipv4 subtree is aliased from ipv4 mapped addresses
+			{"::ffff:10.1.2.3", "NL", "10.1.2.0/24", "10.1.2.0"},
+			{"::10.1.2.3", "NL", "10.1.2.0/24", "10.1.2.0"},
+			{"2001:db8::1", "", "2001:db8::/32", "2001:db8::"},
+			{"2001:db8:1::1", "JP", "2001:db8:1::/48", "2001:db8:1::"},
+			{"2001:db8:2::", "", "2001:db8::/32", "2001:db8::"},
+		}
+		for _, test := range tests {
+			record := &MmdbRecord{}
+			network, ok, err := reader.LookupNetwork(net.ParseIP(test.addr), record)
+			if err != nil || !ok {
+				t.Errorf("record size %d: lookup %s = %v, %v", recordSize, test.addr, ok, err)
+				continue
+			}
+			if record.Country.IsoCode != test.country || record.Prefix != test.prefix || record.Range.Start != test.start {
+				t.Errorf("record size %d: lookup %s = %+v in %s, want %s %s from %s", recordSize, test.addr, record, network, test.country, test.prefix, test.start)
+			}
+		}
+
+		for _, addr := range []string{"9.255.255.255", "11.0.0.0", "192.168.3.0", "2001:db9::", "::1"} {
+			record := &MmdbRecord{}
+			if _, ok, err := reader.LookupNetwork(net.ParseIP(addr), record); err != nil || ok {
+				t.Errorf("record size %d: lookup %s = %v, %v, want not found", recordSize, addr, ok, err)
+			}
+		}
+		reader.Close()
+	}
+}
+
+func TestMmdbRecord(t *testing.T) {
+	path, _ := writeTestMmdb(t, newTestRanges(t), 0)
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	record := &MmdbRecord{}
+	if err = reader.Lookup(net.ParseIP("10.0.0.1"), record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Rir != "ripencc" || record.Status != "allocated" || record.Date != "2010-07-12" || record.HolderId != "holder-1" ||
+		record.Range.Start != "10.0.0.0" || record.Range.End != "10.255.255.255" {
+		t.Fatalf("record = %+v", record)
+	}
+
+	// Empty values are omitted
+	var values map[string]any
+	if err = reader.Lookup(net.ParseIP("2001:db8::1"), &values); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"country", "date", "holder_id"} {
+		if _, ok := values[key]; ok {
+			t.Errorf("record has %s: %v", key, values)
+		}
+	}
+}
+
+func TestMmdbRecordSize(t *testing.T) {
+	writer := NewMmdbWriter(MmdbDatabaseType, mmdbDescription, 0)
+	for maxValue, want := range map[uint64]int{
+		1<<24 - 1: 24,
+		1 << 24:   28,
+		1<<28 - 1: 28,
+		1 << 28:   32,
+		1<<32 - 1: 32,
+	} {
+		if size, err := writer.recordSize(maxValue); err != nil || size != want {
+			t.Errorf("recordSize(%d) = %d, %v, want %d", maxValue, size, err, want)
+		}
+	}
+	if _, err := writer.recordSize(1 << 32); err == nil {
+		t.Error("recordSize(1<<32) succeeded")
+	}
+}
+
+func TestMmdbInsertInvalid(t *testing.T) {
+	writer := NewMmdbWriter(MmdbDatabaseType, mmdbDescription, 0)
+	// Ipv4 /0 is the ::/96 subtree, it can be inserted
+	for _, prefix := range []netip.Prefix{netip.MustParsePrefix("::/0"), {}} {
+		if err := writer.Insert(prefix, 0); !errors.Is(err, ErrMmdbPrefix) {
+			t.Errorf("Insert(%s) = %v, want ErrMmdbPrefix", prefix, err)
+		}
+	}
+}
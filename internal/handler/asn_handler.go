@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/service"
+)
+
+// Accepts plain number and AS prefixed form: 3333, AS3333
+func ParseAsn(value string) (uint32, error) {
+	if len(value) > 2 && strings.EqualFold(value[:2], "as") {
+		value = value[2:]
+	}
+	asn, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(asn), nil
+}
+
+func NewAsnHandler(service service.AsnService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		numberFromPath := r.PathValue("number")
+		asn, err := ParseAsn(numberFromPath)
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidAsn, fmt.Sprintf("invalid as number '%s'", numberFromPath))
+			return
+		}
+		at, ok := parseAtQuery(w, r)
+		if !ok {
+			return
+		}
+
+		var asnInfo *entity.AsnInfo
+		if at != nil {
+			asnInfo, err = service.GetAsnAt(asn, *at)
+		} else {
+			asnInfo, err = service.GetAsn(asn)
+		}
+		if err != nil {
+			slog.Error("can't get asn info", "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't get asn info")
+			return
+		}
+		if asnInfo == nil {
+			WriteError(w, r, http.StatusNotFound, ErrorNotFound, fmt.Sprintf("as number '%d' not found", asn))
+			return
+		}
+		WriteOk(w, asnInfo)
+	}
+}
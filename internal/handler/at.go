@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const atQueryParameter = "at"
+
+// Date is the state of data at the end of that day in UTC, so updates of the day are included.
+// Microsecond is the precision of database timestamps.
+func ParseAt(value string) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date.AddDate(0, 0, 1).Add(-time.Microsecond), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// Nil without parameter, invalid parameter is answered with bad request
+func parseAtQuery(w http.ResponseWriter, r *http.Request) (*time.Time, bool) {
+	value := r.URL.Query().Get(atQueryParameter)
+	if value == "" {
+		return nil, true
+	}
+	at, err := ParseAt(value)
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrorInvalidAt, fmt.Sprintf("invalid at '%s', expected YYYY-MM-DD", value))
+		return nil, false
+	}
+	return &at, true
+}
+
+// Lookups without history answer from current data only, at is rejected instead of being ignored
+func rejectAtQuery(w http.ResponseWriter, r *http.Request) bool {
+	if !r.URL.Query().Has(atQueryParameter) {
+		return false
+	}
+	WriteError(w, r, http.StatusBadRequest, ErrorInvalidAt, "at is supported by ip and asn lookups only")
+	return true
+}
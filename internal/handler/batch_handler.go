@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/service"
+)
+
+const (
+	// Longest ipv6 text form (45 bytes, more with zone) with quotes, separator and indentation of pretty printed json
+	batchItemMaxBytes = 128
+	// Brackets, trailing newlines and whitespace around the list, so a full batch is never cut by the byte limit.
+	// Count of addresses is checked after parsing anyway
+	batchEnvelopeMaxBytes = 4096
+)
+
+// Body of batch of max size fits in, with room for formatting
+func newBatchMaxBytes(maxSize int) int64 {
+	return int64(maxSize)*batchItemMaxBytes + batchEnvelopeMaxBytes
+}
+
+// Accepts json array of addresses or newline delimited list, plain or json quoted per line
+func parseBatchBody(body []byte) ([]string, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var ipAddresses []string
+		if err := json.Unmarshal(body, &ipAddresses); err != nil {
+			return nil, fmt.Errorf("invalid json array: %w", err)
+		}
+		return ipAddresses, nil
+	}
+
+	ipAddresses := make([]string, 0, bytes.Count(body, []byte("\n"))+1)
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line[0] == '"' {
+			if err := json.Unmarshal([]byte(line), &line); err != nil {
+				return nil, fmt.Errorf("invalid json string %s: %w", line, err)
+			}
+		}
+		ipAddresses = append(ipAddresses, line)
+	}
+	return ipAddresses, nil
+}
+
+func newBatchItem(ipAddress string, ipAddressInfo *entity.IpAddressInfo) *BatchItem {
+	if ipAddressInfo == nil {
+		return NewBatchNotFoundItem(ipAddress, fmt.Sprintf("ip address '%s' not found", ipAddress))
+	}
+	return NewBatchOkItem(ipAddress, NewIpData(ipAddressInfo))
+}
+
+func NewBatchHandler(service service.IpAddressService, maxSize int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rejectAtQuery(w, r) {
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, newBatchMaxBytes(maxSize)))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				WriteError(w, r, http.StatusRequestEntityTooLarge, ErrorBatchTooLarge, fmt.Sprintf("batch body too large, max batch size is %d", maxSize))
+				return
+			}
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidBatch, "can't read batch")
+			return
+		}
+		ipAddresses, err := parseBatchBody(body)
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidBatch, err.Error())
+			return
+		}
+		if len(ipAddresses) > maxSize {
+			WriteError(w, r, http.StatusRequestEntityTooLarge, ErrorBatchTooLarge, fmt.Sprintf("batch size %d exceeds max batch size %d", len(ipAddresses), maxSize))
+			return
+		}
+
+		items := make([]*BatchItem, len(ipAddresses))
+		validAddresses := make([]string, 0, len(ipAddresses))
+		validPositions := make([]int, 0, len(ipAddresses))
+		for i, ipAddress := range ipAddresses {
+			addr, err := ParseIpAddress(ipAddress)
+			if err != nil {
+				items[i] = NewBatchBadRequestItem(ipAddress, "invalid ip address")
+				continue
+			}
+			validAddresses = append(validAddresses, addr.String())
+			validPositions = append(validPositions, i)
+		}
+
+		if len(validAddresses) != 0 {
+			ipAddressInfos, err := service.GetIpAddresses(validAddresses)
+			if err != nil {
+				slog.Error("can't get ip addresses info", "err", err)
+				WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't get ip addresses info")
+				return
+			}
+			for i, ipAddressInfo := range ipAddressInfos {
+				position := validPositions[i]
+				if ipAddressInfo != nil {
+					ipAddressInfo.IpAddress = ipAddresses[position]
+				}
+				items[position] = newBatchItem(ipAddresses[position], ipAddressInfo)
+			}
+		}
+
+		WriteOk(w, items)
+	}
+}
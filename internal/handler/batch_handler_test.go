@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+// Knows addresses of 192.0.2.0/24 only and records what batch asked for
+type fakeIpAddressService struct {
+	requested []string
+}
+
+func (p *fakeIpAddressService) GetIpAddress(ipAddress string) (*entity.IpAddressInfo, error) {
+	infos, err := p.GetIpAddresses([]string{ipAddress})
+	return infos[0], err
+}
+
+func (p *fakeIpAddressService) GetIpAddressAt(ipAddress string, at time.Time) (*entity.IpAddressInfo, error) {
+	return p.GetIpAddress(ipAddress)
+}
+
+func (p *fakeIpAddressService) GetIpAddresses(ipAddresses []string) ([]*entity.IpAddressInfo, error) {
+	p.requested = append(p.requested, ipAddresses...)
+	infos := make([]*entity.IpAddressInfo, len(ipAddresses))
+	for i, ipAddress := range ipAddresses {
+		if strings.HasPrefix(ipAddress, "192.0.2.") {
+			infos[i] = &entity.IpAddressInfo{IpAddress: ipAddress, CountryCode: "NL"}
+		}
+	}
+	return infos, nil
+}
+
+func (p *fakeIpAddressService) GetIpRanges(query *entity.PrefixQuery) ([]*entity.IpRangeInfo, error) {
+	return nil, nil
+}
+
+type batchResponse struct {
+	Code ResponseStatus `json:"code"`
+	Data []struct {
+		IpAddress string         `json:"ipAddress"`
+		Code      ResponseStatus `json:"code"`
+		Error     ErrorCode      `json:"error"`
+		Data      *IpData        `json:"data"`
+	} `json:"data"`
+}
+
+func serveBatch(t *testing.T, service *fakeIpAddressService, maxSize int, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(body))
+	NewBatchHandler(service, maxSize)(w, r)
+	return w
+}
+
+func TestBatchHandlerOrder(t *testing.T) {
+	service := &fakeIpAddressService{}
+	w := serveBatch(t, service, 10, `["192.0.2.1", "not an ip", "198.51.100.1", "::ffff:192.0.2.2", "192.0.2.1"]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var response batchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+
+	// Items follow the request, addresses are echoed as sent
+	want := []struct {
+		ipAddress string
+		code      ResponseStatus
+		error     ErrorCode
+	}{
+		{"192.0.2.1", ResponseOk, ""},
+		{"not an ip", ResponseBadRequest, ErrorInvalidIpAddress},
+		{"198.51.100.1", ResponseNotFound, ErrorNotFound},
+		{"::ffff:192.0.2.2", ResponseOk, ""},
+		{"192.0.2.1", ResponseOk, ""},
+	}
+	if len(response.Data) != len(want) {
+		t.Fatalf("items = %d, want %d: %s", len(response.Data), len(want), w.Body)
+	}
+	for i, item := range response.Data {
+		if item.IpAddress != want[i].ipAddress || item.Code != want[i].code || item.Error != want[i].error {
+			t.Errorf("item %d = %s %d %q, want %s %d %q", i, item.IpAddress, item.Code, item.Error, want[i].ipAddress, want[i].code, want[i].error)
+		}
+		if (item.Data != nil) != (want[i].code == ResponseOk) {
+			t.Errorf("item %d data = %v, want data only for found address", i, item.Data)
+		}
+	}
+	// Invalid item is not looked up, mapped address is looked up unmapped
+	wantRequested := []string{"192.0.2.1", "198.51.100.1", "192.0.2.2", "192.0.2.1"}
+	if strings.Join(service.requested, ",") != strings.Join(wantRequested, ",") {
+		t.Fatalf("requested = %v, want %v", service.requested, wantRequested)
+	}
+}
+
+func TestBatchHandlerNewlineDelimited(t *testing.T) {
+	w := serveBatch(t, &fakeIpAddressService{}, 10, "192.0.2.1\r\n\n\"2001:db8::1\"\n")
+	var response batchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if w.Code != http.StatusOK || len(response.Data) != 2 || response.Data[1].IpAddress != "2001:db8::1" {
+		t.Fatalf("response = %d %s, want 2 items", w.Code, w.Body)
+	}
+}
+
+func TestBatchHandlerEmpty(t *testing.T) {
+	for _, body := range []string{"", "[]", "\n\n"} {
+		service := &fakeIpAddressService{}
+		w := serveBatch(t, service, 10, body)
+		if w.Code != http.StatusOK || w.Body.String() != `{"code":0,"data":[]}` {
+			t.Errorf("response of %q = %d %s, want empty list", body, w.Code, w.Body)
+		}
+		if len(service.requested) != 0 {
+			t.Errorf("requested of %q = %v, want nothing", body, service.requested)
+		}
+	}
+}
+
+func TestBatchHandlerInvalid(t *testing.T) {
+	for _, body := range []string{`["192.0.2.1"`, `[1, 2]`, `"192.0.2.1`} {
+		w := serveBatch(t, &fakeIpAddressService{}, 10, body)
+		var problem BadResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+		if w.Code != http.StatusBadRequest || problem.Error != ErrorInvalidBatch {
+			t.Errorf("response of %q = %d %s, want %d %s", body, w.Code, w.Body, http.StatusBadRequest, ErrorInvalidBatch)
+		}
+	}
+}
+
+func TestBatchHandlerSizeLimit(t *testing.T) {
+	const maxSize = 100
+	longest := "ffff:ffff:ffff:ffff:ffff:ffff:255.255.255.255"
+	items := make([]string, maxSize)
+	for i := range items {
+		items[i] = `"` + longest + `"`
+	}
+	// Full batch of the longest addresses, pretty printed with indentation
+	pretty := "[\n\t\t" + strings.Join(items, ",\n\t\t") + "\n]\n"
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"full pretty batch", pretty, http.StatusOK},
+		{"full newline delimited batch", strings.Repeat(longest+"\r\n", maxSize), http.StatusOK},
+		{"one address too many", strings.Repeat("192.0.2.1\n", maxSize+1), http.StatusRequestEntityTooLarge},
+		{"body too large", strings.Repeat(" ", int(newBatchMaxBytes(maxSize))+1), http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serveBatch(t, &fakeIpAddressService{}, maxSize, test.body)
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			if test.status == http.StatusRequestEntityTooLarge && !strings.Contains(w.Body.String(), string(ErrorBatchTooLarge)) {
+				t.Fatalf("body = %s, want %s", w.Body, ErrorBatchTooLarge)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/service"
+)
+
+const (
+	changesDefaultLimit = 1000
+	changesMaxLimit     = 10000
+)
+
+var rirNames = common.RirNames()
+
+// Since is a date (from start of the day in UTC), RFC 3339 time or serial of the rir
+func parseChangeQuery(r *http.Request) (*entity.ChangeQuery, ErrorCode, error) {
+	values := r.URL.Query()
+	query := &entity.ChangeQuery{
+		RirName: values.Get("rir"),
+		Limit:   changesDefaultLimit,
+	}
+	if query.RirName != "" && !slices.Contains(rirNames, query.RirName) {
+		return nil, ErrorInvalidRir, fmt.Errorf("unknown rir '%s'", query.RirName)
+	}
+
+	if since := values.Get("since"); since != "" {
+		if date, err := time.Parse(time.DateOnly, since); err == nil {
+			query.SinceTime = date
+		} else if at, err := time.Parse(time.RFC3339, since); err == nil {
+			query.SinceTime = at
+		} else if query.RirName == "" {
+			return nil, ErrorInvalidSince, fmt.Errorf("since serial '%s' requires rir", since)
+		} else {
+			query.SinceSerial = since
+		}
+	}
+
+	if after := values.Get("after"); after != "" {
+		var err error
+		query.After, err = strconv.ParseInt(after, 10, 64)
+		if err != nil || query.After < 0 {
+			return nil, ErrorInvalidPage, fmt.Errorf("invalid after '%s'", after)
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > changesMaxLimit {
+			return nil, ErrorInvalidPage, fmt.Errorf("invalid limit '%s', expected 1..%d", limit, changesMaxLimit)
+		}
+	}
+	return query, "", nil
+}
+
+func NewChangesHandler(service service.ChangeService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, errorCode, err := parseChangeQuery(r)
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, errorCode, err.Error())
+			return
+		}
+		changes, err := service.GetChanges(query)
+		if err != nil {
+			slog.Error("can't get changes", "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't get changes")
+			return
+		}
+		if changes == nil {
+			WriteError(w, r, http.StatusNotFound, ErrorNotFound, fmt.Sprintf("serial '%s' of %s not found", query.SinceSerial, query.RirName))
+			return
+		}
+		WriteOk(w, NewChangesData(changes, query.Limit))
+	}
+}
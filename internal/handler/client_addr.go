@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+func isTrustedProxy(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses node of Forwarded or X-Forwarded-For: 192.0.2.1, 192.0.2.1:80, [2001:db8::1]:80, "[2001:db8::1]"
+func parseNodeAddr(node string) (netip.Addr, bool) {
+	node = strings.Trim(strings.TrimSpace(node), `"`)
+	if addr, err := netip.ParseAddr(node); err == nil {
+		return addr.WithZone("").Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(node); err == nil {
+		return addrPort.Addr().WithZone("").Unmap(), true
+	}
+	if strings.HasPrefix(node, "[") && strings.HasSuffix(node, "]") {
+		if addr, err := netip.ParseAddr(node[1 : len(node)-1]); err == nil {
+			return addr.WithZone("").Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// Walks hops from the nearest one and returns first address which is not a trusted proxy
+func rightmostUntrustedAddr(hops []string, trustedProxies []netip.Prefix) (netip.Addr, bool) {
+	var addr netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		hopAddr, ok := parseNodeAddr(hops[i])
+		if !ok {
+			// unknown or obfuscated node, chain can't be followed further
+			return netip.Addr{}, false
+		}
+		addr = hopAddr
+		if !isTrustedProxy(addr, trustedProxies) {
+			return addr, true
+		}
+	}
+	return addr, addr.IsValid()
+}
+
+func splitHeaderList(values []string) []string {
+	items := make([]string, 0, len(values))
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// Extracts for= parameters of RFC 7239 Forwarded header in order of hops
+func parseForwardedFor(values []string) []string {
+	hops := make([]string, 0, len(values))
+	for _, element := range splitHeaderList(values) {
+		for _, pair := range strings.Split(element, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(name, "for") {
+				hops = append(hops, value)
+			}
+		}
+	}
+	return hops
+}
+
+// Proxy headers are used only if direct peer is trusted proxy, Forwarded first, then X-Forwarded-For, then X-Real-IP.
+// The first present header decides, its chain which can't be followed leaves client unresolved.
+func ResolveClientAddr(r *http.Request, trustedProxies []netip.Prefix) (netip.Addr, bool) {
+	peer, ok := parseNodeAddr(r.RemoteAddr)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	if !isTrustedProxy(peer, trustedProxies) {
+		return peer, true
+	}
+
+	if values := r.Header.Values("Forwarded"); len(values) != 0 {
+		return rightmostUntrustedAddr(parseForwardedFor(values), trustedProxies)
+	}
+	if values := r.Header.Values("X-Forwarded-For"); len(values) != 0 {
+		return rightmostUntrustedAddr(splitHeaderList(values), trustedProxies)
+	}
+	if values := r.Header.Values("X-Real-IP"); len(values) != 0 {
+		return parseNodeAddr(values[0])
+	}
+	return peer, true
+}
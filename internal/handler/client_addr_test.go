@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+var testTrustedProxies = []netip.Prefix{
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("fd00::/8"),
+}
+
+func TestIsTrustedProxy(t *testing.T) {
+	tests := []struct {
+		addr    string
+		proxies []netip.Prefix
+		want    bool
+	}{
+		{"10.1.2.3", testTrustedProxies, true},
+		{"fd00::1", testTrustedProxies, true},
+		{"192.0.2.1", testTrustedProxies, false},
+		{"2001:db8::1", testTrustedProxies, false},
+		{"10.1.2.3", nil, false},
+	}
+	for _, test := range tests {
+		if got := isTrustedProxy(netip.MustParseAddr(test.addr), test.proxies); got != test.want {
+			t.Errorf("isTrustedProxy(%s, %v) = %v, want %v", test.addr, test.proxies, got, test.want)
+		}
+	}
+}
+
+func TestParseNodeAddr(t *testing.T) {
+	tests := []struct {
+		node string
+		want string
+	}{
+		{"192.0.2.1", "192.0.2.1"},
+		{" 192.0.2.1 ", "192.0.2.1"},
+		{"192.0.2.1:80", "192.0.2.1"},
+		{"2001:db8::1", "2001:db8::1"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{`"[2001:db8::1]:443"`, "2001:db8::1"},
+		{`"[2001:db8::1]"`, "2001:db8::1"},
+		{"fe80::1%eth0", "fe80::1"},
+		{"::ffff:192.0.2.1", "192.0.2.1"},
+		{"unknown", ""},
+		{"_hidden", ""},
+		{`"_hidden:80"`, ""},
+		{"192.0.2", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		addr, ok := parseNodeAddr(test.node)
+		if ok != (test.want != "") || (ok && addr.String() != test.want) {
+			t.Errorf("parseNodeAddr(%q) = %v, %v, want %q", test.node, addr, ok, test.want)
+		}
+	}
+}
+
+func TestRightmostUntrustedAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		hops []string
+		want string
+	}{
+		{"single client", []string{"192.0.2.1"}, "192.0.2.1"},
+		{"client behind proxies", []string{"192.0.2.1", "10.0.0.2", "10.0.0.1"}, "192.0.2.1"},
+		{"spoofed leftmost", []string{"198.51.100.7", "192.0.2.1", "10.0.0.1"}, "192.0.2.1"},
+		{"all hops trusted", []string{"10.0.0.3", "10.0.0.2", "10.0.0.1"}, "10.0.0.3"},
+		{"unknown nearest hop", []string{"192.0.2.1", "unknown"}, ""},
+		{"obfuscated behind proxy", []string{"192.0.2.1", "_hidden", "10.0.0.1"}, ""},
+		{"unknown before untrusted", []string{"unknown", "192.0.2.1", "10.0.0.1"}, "192.0.2.1"},
+		{"no hops", nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addr, ok := rightmostUntrustedAddr(test.hops, testTrustedProxies)
+			if ok != (test.want != "") || (ok && addr.String() != test.want) {
+				t.Fatalf("rightmostUntrustedAddr(%v) = %v, %v, want %q", test.hops, addr, ok, test.want)
+			}
+		})
+	}
+}
+
+func TestParseForwardedFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{"single", []string{"for=192.0.2.1"}, []string{"192.0.2.1"}},
+		{"with other pairs", []string{"proto=https;For=192.0.2.1;by=10.0.0.1"}, []string{"192.0.2.1"}},
+		{"quoted ipv6 with port", []string{`for="[2001:db8::1]:443"`}, []string{`"[2001:db8::1]:443"`}},
+		{"list", []string{"for=192.0.2.1, for=10.0.0.2"}, []string{"192.0.2.1", "10.0.0.2"}},
+		{"multiple lines", []string{"for=192.0.2.1", "for=10.0.0.2, for=10.0.0.1"}, []string{"192.0.2.1", "10.0.0.2", "10.0.0.1"}},
+		{"element without for", []string{"proto=https, for=10.0.0.1"}, []string{"10.0.0.1"}},
+		{"empty elements", []string{", ,for=unknown"}, []string{"unknown"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseForwardedFor(test.values); !slices.Equal(got, test.want) {
+				t.Fatalf("parseForwardedFor(%q) = %q, want %q", test.values, got, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveClientAddr(t *testing.T) {
+	tests := []struct {
+		name    string
+		peer    string
+		headers map[string][]string
+		proxies []netip.Prefix
+		want    string
+	}{
+		{"direct client", "192.0.2.1:1234", nil, testTrustedProxies, "192.0.2.1"},
+		{"direct ipv6 client", "[2001:db8::1]:1234", nil, testTrustedProxies, "2001:db8::1"},
+		{"untrusted peer headers ignored", "192.0.2.1:1234",
+			map[string][]string{"X-Forwarded-For": {"198.51.100.7"}, "X-Real-Ip": {"198.51.100.7"}}, testTrustedProxies, "192.0.2.1"},
+		{"no trusted proxies headers ignored", "10.0.0.1:1234",
+			map[string][]string{"Forwarded": {"for=198.51.100.7"}, "X-Forwarded-For": {"198.51.100.7"}}, nil, "10.0.0.1"},
+		{"trusted peer without headers", "10.0.0.1:1234", nil, testTrustedProxies, "10.0.0.1"},
+		{"forwarded quoted ipv6 with port", "10.0.0.1:1234",
+			map[string][]string{"Forwarded": {`for="[2001:db8::1]:443";proto=https`}}, testTrustedProxies, "2001:db8::1"},
+		{"forwarded before x-forwarded-for", "10.0.0.1:1234",
+			map[string][]string{"Forwarded": {"for=192.0.2.1"}, "X-Forwarded-For": {"198.51.100.7"}}, testTrustedProxies, "192.0.2.1"},
+		{"forwarded unknown", "10.0.0.1:1234",
+			map[string][]string{"Forwarded": {"for=unknown"}, "X-Forwarded-For": {"198.51.100.7"}}, testTrustedProxies, ""},
+		{"x-forwarded-for spoofed leftmost", "10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"198.51.100.7, 192.0.2.1, 10.0.0.2"}}, testTrustedProxies, "192.0.2.1"},
+		{"x-forwarded-for multiple lines", "10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"198.51.100.7", "192.0.2.1, 10.0.0.2"}}, testTrustedProxies, "192.0.2.1"},
+		{"x-forwarded-for all trusted", "10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, testTrustedProxies, "10.0.0.3"},
+		{"x-forwarded-for garbage", "10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"192.0.2.1, not an address"}}, testTrustedProxies, ""},
+		{"x-real-ip from trusted peer", "10.0.0.1:1234",
+			map[string][]string{"X-Real-Ip": {"192.0.2.1"}}, testTrustedProxies, "192.0.2.1"},
+		{"x-real-ip after x-forwarded-for", "10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"192.0.2.1"}, "X-Real-Ip": {"198.51.100.7"}}, testTrustedProxies, "192.0.2.1"},
+		{"unparseable peer", "@", nil, testTrustedProxies, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/me", nil)
+			r.RemoteAddr = test.peer
+			for name, values := range test.headers {
+				for _, value := range values {
+					r.Header.Add(name, value)
+				}
+			}
+			addr, ok := ResolveClientAddr(r, test.proxies)
+			if ok != (test.want != "") || (ok && addr.String() != test.want) {
+				t.Fatalf("ResolveClientAddr = %v, %v, want %q", addr, ok, test.want)
+			}
+		})
+	}
+}
@@ -3,9 +3,13 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/KeilWin/ipinfo/internal/common"
+	"github.com/KeilWin/ipinfo/internal/utils"
 )
 
 const componentName = "API"
@@ -15,7 +19,11 @@ type HandlerConfig struct {
 
 	BasePrefix string
 
-	ApiBasePath string
+	ApiBasePath    string
+	BatchMaxSize   int
+	TrustedProxies []netip.Prefix
+	// Bearer token of admin api, admin api is disabled when empty
+	AdminToken string
 }
 
 func (p *HandlerConfig) NewVariableName(name string) string {
@@ -23,11 +31,22 @@ func (p *HandlerConfig) NewVariableName(name string) string {
 }
 
 func (p *HandlerConfig) Load() error {
+	var err error
 	var hasError bool
 
 	apiBasePathName := p.NewVariableName("BASE_PATH")
 	p.ApiBasePath = os.Getenv(apiBasePathName)
 
+	batchMaxSizeName := p.NewVariableName("BATCH_MAX_SIZE")
+	p.BatchMaxSize, err = strconv.Atoi(os.Getenv(batchMaxSizeName))
+	hasError = CheckLoadHandlerConfigError(err, batchMaxSizeName) || hasError
+
+	trustedProxiesName := p.NewVariableName("TRUSTED_PROXIES")
+	p.TrustedProxies, err = parsePrefixList(os.Getenv(trustedProxiesName))
+	hasError = CheckLoadHandlerConfigError(err, trustedProxiesName) || hasError
+
+	p.AdminToken = os.Getenv(p.NewVariableName("ADMIN_TOKEN"))
+
 	if hasError {
 		return errors.New("loading handler config")
 	}
@@ -43,3 +62,32 @@ func NewHandlerConfig(appPrefix string) *HandlerConfig {
 		BasePrefix: common.NewBasePrefix(appPrefix, componentName),
 	}
 }
+
+// Comma separated list of CIDRs, single address is treated as host prefix
+func parsePrefixList(value string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func CheckLoadHandlerConfigError(err error, name string) bool {
+	return utils.CheckLoadConfigError(err, name, componentName)
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/export"
+	"github.com/KeilWin/ipinfo/internal/service"
+)
+
+// Lists change only with published data, clients revalidate them by ETag of data version
+const exportCacheControl = "public, max-age=300"
+
+// Country and status are comma separated lists
+func parseExportQuery(r *http.Request) *entity.ExportQuery {
+	values := r.URL.Query()
+	return &entity.ExportQuery{
+		Format:       r.PathValue("format"),
+		CountryCodes: parseCommaList(values.Get("country")),
+		Family:       values.Get("family"),
+		Name:         values.Get("name"),
+		Statuses:     parseCommaList(values.Get("status")),
+	}
+}
+
+func parseCommaList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Weak comparison, as in If-None-Match
+func matchesETag(ifNoneMatch, etag string) bool {
+	for _, value := range strings.Split(ifNoneMatch, ",") {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == "*" || value == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func NewExportHandler(service service.ExportService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := parseExportQuery(r)
+		if !slices.Contains(export.ListFormats, export.Format(query.Format)) {
+			WriteError(w, r, http.StatusNotFound, ErrorNotFound, fmt.Sprintf("export format '%s' not found", query.Format))
+			return
+		}
+		if err := export.CheckQuery(query); err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidExport, err.Error())
+			return
+		}
+
+		version, err := service.GetDataVersion(r.Context())
+		if err != nil {
+			slog.Error("can't get data version", "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't export")
+			return
+		}
+		if matchesETag(r.Header.Get("If-None-Match"), strconv.Quote(version)) {
+			w.Header().Set("ETag", strconv.Quote(version))
+			w.Header().Set("Cache-Control", exportCacheControl)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		// List is built before headers are sent, so failure is still reported by status
+		list, err := service.GetList(r.Context(), query)
+		if err != nil {
+			slog.Error("can't export", "format", query.Format, "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't export")
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("ETag", strconv.Quote(list.Version))
+		w.Header().Set("Cache-Control", exportCacheControl)
+		w.WriteHeader(http.StatusOK)
+		w.Write(list.Body)
+	}
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/KeilWin/ipinfo/internal/service"
+)
+
+func NewHolderHandler(service service.HolderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rejectAtQuery(w, r) {
+			return
+		}
+		opaqueIdFromPath := r.PathValue("opaqueId")
+		if opaqueIdFromPath == "" {
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidHolder, "empty holder id")
+			return
+		}
+		holderInfo, err := service.GetHolder(opaqueIdFromPath)
+		if err != nil {
+			slog.Error("can't get holder info", "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't get holder info")
+			return
+		}
+		if holderInfo == nil {
+			WriteError(w, r, http.StatusNotFound, ErrorNotFound, fmt.Sprintf("holder '%s' not found", opaqueIdFromPath))
+			return
+		}
+		WriteOk(w, holderInfo)
+	}
+}
@@ -5,25 +5,79 @@ import (
 	"log/slog"
 	"net/http"
 
-	"github.com/KeilWin/ipinfo/internal/dao"
+	"github.com/KeilWin/ipinfo/internal/service"
 )
 
-func initHandler(handler *http.ServeMux, handlerConfig *HandlerConfig, repository dao.IpAddressRepository) {
+func initHandler(handler *http.ServeMux, handlerConfig *HandlerConfig, service service.IpAddressService, asnService service.AsnService, holderService service.HolderService, changeService service.ChangeService, exportService service.ExportService, webhookService service.WebhookService) {
 	healthPath := fmt.Sprintf("GET %s/health", handlerConfig.ApiBasePath)
 	handler.Handle(healthPath, NewHealthHandler())
 	slog.Info("added health path", "path", healthPath)
 
+	ipPath := fmt.Sprintf("GET %s/ip/{ipAddress}", handlerConfig.ApiBasePath)
+	handler.Handle(ipPath, NewIpHandler(service))
+	slog.Info("added ip path", "path", ipPath)
+
 	ipv4Path := fmt.Sprintf("GET %s/ipv4/{ipAddress}", handlerConfig.ApiBasePath)
-	handler.Handle(ipv4Path, NewIpV4Handler(repository))
+	handler.Handle(ipv4Path, NewIpV4Handler(service))
 	slog.Info("added ipv4 path", "path", ipv4Path)
 
 	ipv6Path := fmt.Sprintf("GET %s/ipv6/{ipAddress}", handlerConfig.ApiBasePath)
-	handler.Handle(ipv6Path, NewIpV6Handler(repository))
+	handler.Handle(ipv6Path, NewIpV6Handler(service))
 	slog.Info("added ipv6 path", "path", ipv6Path)
+
+	mePath := fmt.Sprintf("GET %s/me", handlerConfig.ApiBasePath)
+	handler.Handle(mePath, NewMeHandler(service, handlerConfig.TrustedProxies))
+	slog.Info("added me path", "path", mePath)
+
+	prefixPath := fmt.Sprintf("GET %s/prefix/{cidr...}", handlerConfig.ApiBasePath)
+	handler.Handle(prefixPath, NewPrefixHandler(service))
+	slog.Info("added prefix path", "path", prefixPath)
+
+	asnPath := fmt.Sprintf("GET %s/asn/{number}", handlerConfig.ApiBasePath)
+	handler.Handle(asnPath, NewAsnHandler(asnService))
+	slog.Info("added asn path", "path", asnPath)
+
+	holderPath := fmt.Sprintf("GET %s/holder/{opaqueId}", handlerConfig.ApiBasePath)
+	handler.Handle(holderPath, NewHolderHandler(holderService))
+	slog.Info("added holder path", "path", holderPath)
+
+	changesPath := fmt.Sprintf("GET %s/changes", handlerConfig.ApiBasePath)
+	handler.Handle(changesPath, NewChangesHandler(changeService))
+	slog.Info("added changes path", "path", changesPath)
+
+	exportPath := fmt.Sprintf("GET %s/export/{format}", handlerConfig.ApiBasePath)
+	handler.Handle(exportPath, NewExportHandler(exportService))
+	slog.Info("added export path", "path", exportPath)
+
+	batchPath := fmt.Sprintf("POST %s/batch", handlerConfig.ApiBasePath)
+	handler.Handle(batchPath, NewBatchHandler(service, handlerConfig.BatchMaxSize))
+	slog.Info("added batch path", "path", batchPath)
+
+	if handlerConfig.AdminToken == "" {
+		slog.Info("admin api disabled")
+		return
+	}
+	adminRoutes := []struct {
+		pattern string
+		handler http.Handler
+	}{
+		{"POST %s/admin/webhooks", NewCreateWebhookHandler(webhookService)},
+		{"GET %s/admin/webhooks", NewWebhooksHandler(webhookService)},
+		{"GET %s/admin/webhooks/{id}", NewWebhookHandler(webhookService)},
+		{"DELETE %s/admin/webhooks/{id}", NewDeleteWebhookHandler(webhookService)},
+		{"POST %s/admin/webhooks/{id}/ping", NewPingWebhookHandler(webhookService)},
+		{"GET %s/admin/webhooks/{id}/dead-letters", NewWebhookDeadLettersHandler(webhookService)},
+		{"POST %s/admin/webhooks/{id}/dead-letters/{letterId}/retry", NewRetryWebhookDeadLetterHandler(webhookService)},
+	}
+	for _, route := range adminRoutes {
+		adminPath := fmt.Sprintf(route.pattern, handlerConfig.ApiBasePath)
+		handler.Handle(adminPath, NewAdminHandler(handlerConfig.AdminToken, route.handler))
+		slog.Info("added admin path", "path", adminPath)
+	}
 }
 
-func NewAppHandler(handlerConfig *HandlerConfig, repository dao.IpAddressRepository) *http.ServeMux {
+func NewAppHandler(handlerConfig *HandlerConfig, service service.IpAddressService, asnService service.AsnService, holderService service.HolderService, changeService service.ChangeService, exportService service.ExportService, webhookService service.WebhookService) *http.ServeMux {
 	handler := http.NewServeMux()
-	initHandler(handler, handlerConfig, repository)
+	initHandler(handler, handlerConfig, service, asnService, holderService, changeService, exportService, webhookService)
 	return handler
 }
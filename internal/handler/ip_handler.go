@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/service"
+)
+
+// Zone is dropped and ipv4-mapped ipv6 address is looked up as embedded ipv4
+func ParseIpAddress(ipAddress string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(ipAddress)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.WithZone("").Unmap(), nil
+}
+
+func parseIpV4Address(ipAddress string) (netip.Addr, error) {
+	addr, err := ParseIpAddress(ipAddress)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if !addr.Is4() {
+		return netip.Addr{}, errors.New("not ipv4 address")
+	}
+	return addr, nil
+}
+
+func writeIpAddressInfo(w http.ResponseWriter, r *http.Request, service service.IpAddressService, ipAddress string, addr netip.Addr) {
+	at, ok := parseAtQuery(w, r)
+	if !ok {
+		return
+	}
+
+	var ipAddressInfo *entity.IpAddressInfo
+	var err error
+	if at != nil {
+		ipAddressInfo, err = service.GetIpAddressAt(addr.String(), *at)
+	} else {
+		ipAddressInfo, err = service.GetIpAddress(addr.String())
+	}
+	if err != nil {
+		slog.Error("can't get ip address info", "err", err)
+		WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't get ip address info")
+		return
+	}
+	if ipAddressInfo == nil {
+		WriteError(w, r, http.StatusNotFound, ErrorNotFound, fmt.Sprintf("ip address '%s' not found", ipAddress))
+		return
+	}
+	ipAddressInfo.IpAddress = ipAddress
+	WriteOk(w, NewIpData(ipAddressInfo))
+}
+
+func newIpAddressHandler(service service.IpAddressService, parse func(string) (netip.Addr, error), invalidDescription string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ipAddressFromPath := r.PathValue("ipAddress")
+		addr, err := parse(ipAddressFromPath)
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidIpAddress, invalidDescription)
+			return
+		}
+		writeIpAddressInfo(w, r, service, ipAddressFromPath, addr)
+	}
+}
+
+func NewIpHandler(service service.IpAddressService) http.HandlerFunc {
+	return newIpAddressHandler(service, ParseIpAddress, "invalid ip address")
+}
+
+func NewIpV4Handler(service service.IpAddressService) http.HandlerFunc {
+	return newIpAddressHandler(service, parseIpV4Address, "invalid ipv4 address")
+}
+
+// Kept as alias of NewIpHandler, ipv6 path always accepted ipv4 addresses too
+func NewIpV6Handler(service service.IpAddressService) http.HandlerFunc {
+	return newIpAddressHandler(service, ParseIpAddress, "invalid ipv6 address")
+}
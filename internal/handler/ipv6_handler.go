@@ -1,57 +0,0 @@
-package handler
-
-import (
-	"encoding/json"
-	"fmt"
-	"log/slog"
-	"net"
-	"net/http"
-
-	"github.com/KeilWin/ipinfo/internal/service"
-)
-
-func NewIpV6Handler(service service.IpAddressService) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ipAddressFromPath := r.PathValue("ipAddress")
-		if parsedAddr := net.ParseIP(ipAddressFromPath).To16(); parsedAddr == nil {
-			badResponse := NewBadRequestResponse("invalid ipv6 address")
-			res, err := json.Marshal(badResponse)
-			if err != nil {
-				WriteInternalServerError(w)
-				return
-			}
-			w.Write(res)
-			return
-		}
-		ipAddressInfo, err := service.GetIpAddress(ipAddressFromPath)
-		if err != nil {
-			slog.Error("can't get ip address info", "err", err)
-			badResponse := NewInternalErrorResponse("can't get ip address info")
-			res, err := json.Marshal(badResponse)
-			if err != nil {
-				WriteInternalServerError(w)
-				return
-			}
-			w.Write(res)
-			return
-		}
-		if ipAddressInfo == nil {
-			notFoundResponse := NewNotFoundResponse(fmt.Sprintf("ip address '%s' not found", ipAddressFromPath))
-			res, err := json.Marshal(notFoundResponse)
-			if err != nil {
-				WriteInternalServerError(w)
-				return
-			}
-			w.Write(res)
-			return
-		}
-		ipV4Data := NewIpV4Data(ipAddressInfo)
-		okResponse := NewOkResponse(ipV4Data)
-		res, err := json.Marshal(okResponse)
-		if err != nil {
-			WriteInternalServerError(w)
-			return
-		}
-		w.Write(res)
-	}
-}
@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/KeilWin/ipinfo/internal/service"
+)
+
+func NewMeHandler(service service.IpAddressService, trustedProxies []netip.Prefix) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clientAddr, ok := ResolveClientAddr(r, trustedProxies)
+		if !ok {
+			WriteError(w, r, http.StatusBadRequest, ErrorUnresolvedAddress, "can't resolve client ip address")
+			return
+		}
+		writeIpAddressInfo(w, r, service, clientAddr.String(), clientAddr)
+	}
+}
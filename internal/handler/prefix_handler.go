@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/service"
+)
+
+const (
+	prefixDefaultLimit = 1000
+	prefixMaxLimit     = 10000
+)
+
+func parsePrefixQuery(r *http.Request) (*entity.PrefixQuery, ErrorCode, error) {
+	cidrFromPath := r.PathValue("cidr")
+	prefix, err := netip.ParsePrefix(cidrFromPath)
+	if err != nil {
+		return nil, ErrorInvalidPrefix, fmt.Errorf("invalid prefix '%s'", cidrFromPath)
+	}
+	values := r.URL.Query()
+	query := &entity.PrefixQuery{
+		Prefix: prefix.Masked(),
+		Limit:  prefixDefaultLimit,
+	}
+	if after := values.Get("after"); after != "" {
+		if query.After, err = parsePrefixCursor(after, query.Prefix); err != nil {
+			return nil, ErrorInvalidPage, fmt.Errorf("invalid after '%s'", after)
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > prefixMaxLimit {
+			return nil, ErrorInvalidPage, fmt.Errorf("invalid limit '%s', expected 1..%d", limit, prefixMaxLimit)
+		}
+	}
+	return query, "", nil
+}
+
+// Cursor is the key of last range of page: start, end and rir, so it stays valid when ids change on update
+func newPrefixCursor(ipRange *entity.IpRangeInfo) string {
+	key := strings.Join([]string{ipRange.IpRangeStart, ipRange.IpRangeEnd, ipRange.RirName}, ",")
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func parsePrefixCursor(value string, prefix netip.Prefix) (*entity.PrefixCursor, error) {
+	key, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(string(key), ",")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("expected start, end and rir, got %d parts", len(parts))
+	}
+	cursor := &entity.PrefixCursor{RirName: parts[2]}
+	if cursor.Start, err = netip.ParseAddr(parts[0]); err != nil {
+		return nil, err
+	}
+	if cursor.End, err = netip.ParseAddr(parts[1]); err != nil {
+		return nil, err
+	}
+	if cursor.Start.Is4() != prefix.Addr().Is4() || cursor.End.Is4() != prefix.Addr().Is4() || !cursor.Start.Less(cursor.End) {
+		return nil, fmt.Errorf("range %s - %s is not in family of %s", cursor.Start, cursor.End, prefix)
+	}
+	if !slices.Contains(rirNames, cursor.RirName) {
+		return nil, fmt.Errorf("unknown rir '%s'", cursor.RirName)
+	}
+	return cursor, nil
+}
+
+func NewPrefixHandler(service service.IpAddressService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rejectAtQuery(w, r) {
+			return
+		}
+		query, errorCode, err := parsePrefixQuery(r)
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, errorCode, err.Error())
+			return
+		}
+		ipRangeInfos, err := service.GetIpRanges(query)
+		if err != nil {
+			slog.Error("can't get ip ranges info", "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't get ip ranges info")
+			return
+		}
+		WriteOk(w, NewPrefixData(query.Prefix.String(), ipRangeInfos, query.Limit))
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+func TestPrefixCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		ipRange *entity.IpRangeInfo
+	}{
+		{"10.0.0.0/8", &entity.IpRangeInfo{IpRangeStart: "10.1.0.0", IpRangeEnd: "10.2.0.0", RirName: "ripencc"}},
+		{"2001:db8::/32", &entity.IpRangeInfo{IpRangeStart: "2001:db8::", IpRangeEnd: "2001:db8:1::", RirName: "apnic"}},
+	}
+	for _, test := range tests {
+		t.Run(test.prefix, func(t *testing.T) {
+			cursor, err := parsePrefixCursor(newPrefixCursor(test.ipRange), netip.MustParsePrefix(test.prefix))
+			if err != nil {
+				t.Fatalf("parsePrefixCursor: %v", err)
+			}
+			if cursor.Start.String() != test.ipRange.IpRangeStart || cursor.End.String() != test.ipRange.IpRangeEnd || cursor.RirName != test.ipRange.RirName {
+				t.Fatalf("parsePrefixCursor = %+v, want %s - %s %s", cursor, test.ipRange.IpRangeStart, test.ipRange.IpRangeEnd, test.ipRange.RirName)
+			}
+		})
+	}
+}
+
+func TestPrefixCursorInvalid(t *testing.T) {
+	encode := func(key string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(key))
+	}
+	prefix := netip.MustParsePrefix("10.0.0.0/8")
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"not base64", "10.1.0.0,10.2.0.0,ripencc"},
+		{"old id cursor", encode("12345")},
+		{"missing rir", encode("10.1.0.0,10.2.0.0")},
+		{"extra part", encode("10.1.0.0,10.2.0.0,ripencc,1")},
+		{"bad start", encode("10.1.0,10.2.0.0,ripencc")},
+		{"bad end", encode("10.1.0.0,,ripencc")},
+		{"other family", encode("2001:db8::,2001:db8:1::,ripencc")},
+		{"mixed family", encode("10.1.0.0,2001:db8:1::,ripencc")},
+		{"empty range", encode("10.1.0.0,10.1.0.0,ripencc")},
+		{"reversed range", encode("10.2.0.0,10.1.0.0,ripencc")},
+		{"unknown rir", encode("10.1.0.0,10.2.0.0,ripe")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if cursor, err := parsePrefixCursor(test.value, prefix); err == nil {
+				t.Fatalf("parsePrefixCursor = %+v, want error", cursor)
+			}
+		})
+	}
+}
+
+func TestNewPrefixDataNext(t *testing.T) {
+	ranges := []*entity.IpRangeInfo{
+		{IpRangeStart: "10.0.0.0", IpRangeEnd: "10.1.0.0", RirName: "arin"},
+		{IpRangeStart: "10.0.0.0", IpRangeEnd: "10.1.0.0", RirName: "ripencc"},
+	}
+
+	if data := NewPrefixData("10.0.0.0/8", ranges, 3); data.Next != "" {
+		t.Fatalf("Next of last page = %s, want empty", data.Next)
+	}
+
+	data := NewPrefixData("10.0.0.0/8", ranges, 2)
+	if data.Next == "" {
+		t.Fatalf("Next of full page is empty, want cursor")
+	}
+	// Ranges with equal start and end are told apart by rir, so the page ends after the last of them
+	cursor, err := parsePrefixCursor(data.Next, netip.MustParsePrefix("10.0.0.0/8"))
+	if err != nil {
+		t.Fatalf("parsePrefixCursor: %v", err)
+	}
+	want := &entity.PrefixCursor{Start: netip.MustParseAddr("10.0.0.0"), End: netip.MustParseAddr("10.1.0.0"), RirName: "ripencc"}
+	if *cursor != *want {
+		t.Fatalf("Next = %+v, want %+v", cursor, want)
+	}
+}
+
+func TestParsePrefixQuery(t *testing.T) {
+	after := newPrefixCursor(&entity.IpRangeInfo{IpRangeStart: "10.1.0.0", IpRangeEnd: "10.2.0.0", RirName: "arin"})
+	tests := []struct {
+		name    string
+		cidr    string
+		query   string
+		code    ErrorCode
+		prefix  string
+		limit   int
+		isAfter bool
+	}{
+		{"first page", "10.1.2.3/8", "", "", "10.0.0.0/8", prefixDefaultLimit, false},
+		{"next page", "10.0.0.0/8", "?after=" + after + "&limit=10", "", "10.0.0.0/8", 10, true},
+		{"invalid prefix", "10.0.0.0/33", "", ErrorInvalidPrefix, "", 0, false},
+		{"invalid after", "10.0.0.0/8", "?after=12345", ErrorInvalidPage, "", 0, false},
+		{"after of other family", "2001:db8::/32", "?after=" + after, ErrorInvalidPage, "", 0, false},
+		{"limit too big", "10.0.0.0/8", "?limit=10001", ErrorInvalidPage, "", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/prefix/x"+test.query, nil)
+			r.SetPathValue("cidr", test.cidr)
+			query, code, err := parsePrefixQuery(r)
+			if code != test.code {
+				t.Fatalf("parsePrefixQuery code = %q (%v), want %q", code, err, test.code)
+			}
+			if test.code != "" {
+				return
+			}
+			if query.Prefix.String() != test.prefix || query.Limit != test.limit || (query.After != nil) != test.isAfter {
+				t.Fatalf("parsePrefixQuery = %+v, want prefix %s, limit %d, after %v", query, test.prefix, test.limit, test.isAfter)
+			}
+		})
+	}
+}
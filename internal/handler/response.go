@@ -1,7 +1,7 @@
 package handler
 
 import (
-	"net/http"
+	"strconv"
 
 	"github.com/KeilWin/ipinfo/internal/entity"
 )
@@ -23,6 +23,7 @@ const (
 
 type BadResponse struct {
 	Code        ResponseStatus `json:"code"`
+	Error       ErrorCode      `json:"error"`
 	Description string         `json:"description"`
 }
 
@@ -31,30 +32,42 @@ type OkResponse struct {
 	Data any            `json:"data"`
 }
 
-type HealthData struct {
-	Health HealthStatus `json:"health"`
+type PrefixData struct {
+	Prefix string                `json:"prefix"`
+	Ranges []*entity.IpRangeInfo `json:"ranges"`
+	// Cursor for the next page, passed as after parameter
+	Next string `json:"next,omitempty"`
 }
 
-type IpV4Data struct {
-	IpAddress        string `json:"ipAddress"`
-	IpAddressVersion string `json:"ipAddressVersion"`
-	CountryCode      string `json:"countryCode"`
-	IpRangeStart     string `json:"ipRangeStart"`
-	IpRangeEnd       string `json:"ipRangeEnd"`
-	IpRangeQuantity  string `json:"ipRangeQuantity"`
-	Status           string `json:"status"`
-	StatusUpdatedAt  string `json:"statusUpdatedAt"`
+type ChangesData struct {
+	Changes []*entity.RangeChange `json:"changes"`
+	// Cursor for the next page, passed as after parameter
+	Next string `json:"next,omitempty"`
+}
+
+type BatchItem struct {
+	IpAddress   string         `json:"ipAddress"`
+	Code        ResponseStatus `json:"code"`
+	Error       ErrorCode      `json:"error,omitempty"`
+	Data        any            `json:"data,omitempty"`
+	Description string         `json:"description,omitempty"`
+}
+
+type HealthData struct {
+	Health HealthStatus `json:"health"`
 }
 
-type IpV6Data struct {
+type IpData struct {
 	IpAddress        string `json:"ipAddress"`
 	IpAddressVersion string `json:"ipAddressVersion"`
 	CountryCode      string `json:"countryCode"`
 	IpRangeStart     string `json:"ipRangeStart"`
 	IpRangeEnd       string `json:"ipRangeEnd"`
 	IpRangeQuantity  string `json:"ipRangeQuantity"`
+	IpRangePrefix    string `json:"ipRangePrefix,omitempty"`
 	Status           string `json:"status"`
 	StatusUpdatedAt  string `json:"statusUpdatedAt"`
+	HolderId         string `json:"holderId,omitempty"`
 }
 
 func NewOkResponse(data any) *OkResponse {
@@ -64,23 +77,57 @@ func NewOkResponse(data any) *OkResponse {
 	}
 }
 
-func NewNotFoundResponse(desription string) *BadResponse {
+func NewBadResponse(code ResponseStatus, errorCode ErrorCode, description string) *BadResponse {
 	return &BadResponse{
-		Code:        ResponseNotFound,
-		Description: desription,
+		Code:        code,
+		Error:       errorCode,
+		Description: description,
 	}
 }
 
-func NewBadRequestResponse(description string) *BadResponse {
-	return &BadResponse{
-		Code:        ResponseBadRequest,
+func NewPrefixData(prefix string, ranges []*entity.IpRangeInfo, limit int) *PrefixData {
+	data := &PrefixData{
+		Prefix: prefix,
+		Ranges: ranges,
+	}
+	if len(ranges) == limit {
+		data.Next = newPrefixCursor(ranges[len(ranges)-1])
+	}
+	return data
+}
+
+func NewChangesData(changes []*entity.RangeChange, limit int) *ChangesData {
+	data := &ChangesData{
+		Changes: changes,
+	}
+	if len(changes) == limit {
+		data.Next = strconv.FormatInt(changes[len(changes)-1].Id, 10)
+	}
+	return data
+}
+
+func NewBatchOkItem(ipAddress string, data any) *BatchItem {
+	return &BatchItem{
+		IpAddress: ipAddress,
+		Code:      ResponseOk,
+		Data:      data,
+	}
+}
+
+func NewBatchNotFoundItem(ipAddress string, description string) *BatchItem {
+	return &BatchItem{
+		IpAddress:   ipAddress,
+		Code:        ResponseNotFound,
+		Error:       ErrorNotFound,
 		Description: description,
 	}
 }
 
-func NewInternalErrorResponse(description string) *BadResponse {
-	return &BadResponse{
-		Code:        ResponseInternalError,
+func NewBatchBadRequestItem(ipAddress string, description string) *BatchItem {
+	return &BatchItem{
+		IpAddress:   ipAddress,
+		Code:        ResponseBadRequest,
+		Error:       ErrorInvalidIpAddress,
 		Description: description,
 	}
 }
@@ -91,33 +138,17 @@ func NewHealthData() *HealthData {
 	}
 }
 
-func NewIpV4Data(addr *entity.IpAddressInfo) *IpV4Data {
-	return &IpV4Data{
-		IpAddress:        addr.IpAddress,
-		IpAddressVersion: addr.IpAddressVersion,
-		CountryCode:      addr.CountryCode,
-		IpRangeStart:     addr.IpRangeStart,
-		IpRangeEnd:       addr.IpRangeEnd,
-		IpRangeQuantity:  addr.IpRangeQuantity,
-		Status:           addr.Status,
-		StatusUpdatedAt:  addr.StatusUpdatedAt,
-	}
-}
-
-func NewIpV6Data(addr *entity.IpAddressInfo) *IpV6Data {
-	return &IpV6Data{
+func NewIpData(addr *entity.IpAddressInfo) *IpData {
+	return &IpData{
 		IpAddress:        addr.IpAddress,
 		IpAddressVersion: addr.IpAddressVersion,
 		CountryCode:      addr.CountryCode,
 		IpRangeStart:     addr.IpRangeStart,
 		IpRangeEnd:       addr.IpRangeEnd,
 		IpRangeQuantity:  addr.IpRangeQuantity,
+		IpRangePrefix:    addr.IpRangePrefix,
 		Status:           addr.Status,
 		StatusUpdatedAt:  addr.StatusUpdatedAt,
+		HolderId:         addr.HolderId,
 	}
 }
-
-func WriteInternalServerError(w http.ResponseWriter) {
-	w.WriteHeader(http.StatusInternalServerError)
-	w.Write([]byte("Internal server error"))
-}
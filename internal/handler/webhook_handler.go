@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/service"
+	"github.com/KeilWin/ipinfo/internal/webhook"
+)
+
+const (
+	webhookBodyMaxBytes = 64 * 1024
+	webhookSecretMinLen = 16
+)
+
+// Admin api is guarded by static bearer token
+func NewAdminHandler(token string, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			WriteError(w, r, http.StatusUnauthorized, ErrorUnauthorized, "admin token required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+type webhookSubscriptionRequest struct {
+	Url          string   `json:"url"`
+	Secret       string   `json:"secret"`
+	Events       []string `json:"events"`
+	Rirs         []string `json:"rirs"`
+	CountryCodes []string `json:"countryCodes"`
+	Prefixes     []string `json:"prefixes"`
+}
+
+// Country codes are upper cased and prefixes are masked
+func parseWebhookSubscription(w http.ResponseWriter, r *http.Request) (*entity.WebhookSubscription, error) {
+	request := &webhookSubscriptionRequest{}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, webhookBodyMaxBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(request); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+
+	target, err := url.Parse(request.Url)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("invalid url '%s', expected absolute http or https url", request.Url)
+	}
+	if request.Secret != "" && len(request.Secret) < webhookSecretMinLen {
+		return nil, fmt.Errorf("secret must be at least %d characters", webhookSecretMinLen)
+	}
+
+	subscription := &entity.WebhookSubscription{
+		Url:          request.Url,
+		Secret:       request.Secret,
+		Events:       make([]string, 0, len(request.Events)),
+		Rirs:         make([]string, 0, len(request.Rirs)),
+		CountryCodes: make([]string, 0, len(request.CountryCodes)),
+		Prefixes:     make([]string, 0, len(request.Prefixes)),
+	}
+	for _, event := range request.Events {
+		if !slices.Contains(webhook.EventTypes, webhook.EventType(event)) {
+			return nil, fmt.Errorf("unknown event '%s'", event)
+		}
+		subscription.Events = append(subscription.Events, event)
+	}
+	for _, rir := range request.Rirs {
+		if !slices.Contains(rirNames, rir) {
+			return nil, fmt.Errorf("unknown rir '%s'", rir)
+		}
+		subscription.Rirs = append(subscription.Rirs, rir)
+	}
+	for _, countryCode := range request.CountryCodes {
+		countryCode = strings.ToUpper(countryCode)
+		if len(countryCode) != 2 || countryCode[0] < 'A' || countryCode[0] > 'Z' || countryCode[1] < 'A' || countryCode[1] > 'Z' {
+			return nil, fmt.Errorf("invalid country code '%s'", countryCode)
+		}
+		subscription.CountryCodes = append(subscription.CountryCodes, countryCode)
+	}
+	for _, value := range request.Prefixes {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix '%s'", value)
+		}
+		subscription.Prefixes = append(subscription.Prefixes, prefix.Masked().String())
+	}
+	return subscription, nil
+}
+
+func parseIdFromPath(r *http.Request, name string) (int64, error) {
+	value := r.PathValue(name)
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid %s '%s'", name, value)
+	}
+	return id, nil
+}
+
+func writeWebhookNotFound(w http.ResponseWriter, r *http.Request, id int64) {
+	WriteError(w, r, http.StatusNotFound, ErrorNotFound, fmt.Sprintf("webhook '%d' not found", id))
+}
+
+func NewCreateWebhookHandler(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subscription, err := parseWebhookSubscription(w, r)
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidWebhook, err.Error())
+			return
+		}
+		subscription, err = service.CreateSubscription(subscription)
+		if err != nil {
+			slog.Error("can't create webhook", "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't create webhook")
+			return
+		}
+		slog.Info("webhook created", "id", subscription.Id, "url", subscription.Url)
+		WriteOkStatus(w, http.StatusCreated, subscription)
+	}
+}
+
+func NewWebhooksHandler(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subscriptions, err := service.GetSubscriptions(r.Context())
+		if err != nil {
+			slog.Error("can't get webhooks", "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't get webhooks")
+			return
+		}
+		WriteOk(w, subscriptions)
+	}
+}
+
+func NewWebhookHandler(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseIdFromPath(r, "id")
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidWebhook, err.Error())
+			return
+		}
+		subscription, err := service.GetSubscription(id)
+		if err != nil {
+			slog.Error("can't get webhook", "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't get webhook")
+			return
+		}
+		if subscription == nil {
+			writeWebhookNotFound(w, r, id)
+			return
+		}
+		WriteOk(w, subscription)
+	}
+}
+
+func NewDeleteWebhookHandler(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseIdFromPath(r, "id")
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidWebhook, err.Error())
+			return
+		}
+		deleted, err := service.DeleteSubscription(id)
+		if err != nil {
+			slog.Error("can't delete webhook", "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't delete webhook")
+			return
+		}
+		if !deleted {
+			writeWebhookNotFound(w, r, id)
+			return
+		}
+		slog.Info("webhook deleted", "id", id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func NewPingWebhookHandler(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseIdFromPath(r, "id")
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidWebhook, err.Error())
+			return
+		}
+		found, err := service.Ping(id, r.Context())
+		if err != nil {
+			slog.Error("can't ping webhook", "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't ping webhook")
+			return
+		}
+		if !found {
+			writeWebhookNotFound(w, r, id)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func NewWebhookDeadLettersHandler(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseIdFromPath(r, "id")
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidWebhook, err.Error())
+			return
+		}
+		deadLetters, err := service.GetDeadLetters(id)
+		if err != nil {
+			slog.Error("can't get webhook dead letters", "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't get webhook dead letters")
+			return
+		}
+		if deadLetters == nil {
+			writeWebhookNotFound(w, r, id)
+			return
+		}
+		WriteOk(w, deadLetters)
+	}
+}
+
+func NewRetryWebhookDeadLetterHandler(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseIdFromPath(r, "id")
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidWebhook, err.Error())
+			return
+		}
+		letterId, err := parseIdFromPath(r, "letterId")
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidWebhook, err.Error())
+			return
+		}
+		retried, err := service.RetryDeadLetter(id, letterId)
+		if err != nil {
+			slog.Error("can't retry webhook dead letter", "err", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrorInternal, "can't retry webhook dead letter")
+			return
+		}
+		if !retried {
+			WriteError(w, r, http.StatusNotFound, ErrorNotFound, fmt.Sprintf("dead letter '%d' of webhook '%d' not found", letterId, id))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	jsonContentType    = "application/json"
+	problemContentType = "application/problem+json"
+)
+
+type ErrorCode string
+
+const (
+	ErrorNotFound          ErrorCode = "not_found"
+	ErrorInvalidIpAddress  ErrorCode = "invalid_ip_address"
+	ErrorInvalidPrefix     ErrorCode = "invalid_prefix"
+	ErrorInvalidAsn        ErrorCode = "invalid_asn"
+	ErrorInvalidHolder     ErrorCode = "invalid_holder"
+	ErrorInvalidBatch      ErrorCode = "invalid_batch"
+	ErrorBatchTooLarge     ErrorCode = "batch_too_large"
+	ErrorInvalidAt         ErrorCode = "invalid_at"
+	ErrorInvalidSince      ErrorCode = "invalid_since"
+	ErrorInvalidRir        ErrorCode = "invalid_rir"
+	ErrorInvalidPage       ErrorCode = "invalid_page"
+	ErrorInvalidWebhook    ErrorCode = "invalid_webhook"
+	ErrorInvalidExport     ErrorCode = "invalid_export"
+	ErrorUnauthorized      ErrorCode = "unauthorized"
+	ErrorUnresolvedAddress ErrorCode = "unresolved_client_address"
+	ErrorInternal          ErrorCode = "internal_error"
+)
+
+// RFC 9457 problem details, code is stable machine readable extension member
+type ProblemResponse struct {
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Status   int       `json:"status"`
+	Detail   string    `json:"detail,omitempty"`
+	Instance string    `json:"instance,omitempty"`
+	Code     ErrorCode `json:"code"`
+}
+
+func NewProblemResponse(status int, code ErrorCode, detail string, instance string) *ProblemResponse {
+	return &ProblemResponse{
+		Type:     "urn:ipinfo:problem:" + string(code),
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: instance,
+		Code:     code,
+	}
+}
+
+func newResponseStatus(status int) ResponseStatus {
+	switch {
+	case status == http.StatusNotFound:
+		return ResponseNotFound
+	case status >= 400 && status < 500:
+		return ResponseBadRequest
+	default:
+		return ResponseInternalError
+	}
+}
+
+func acceptsProblem(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(value, ",") {
+			mediaType, _, err := mime.ParseMediaType(mediaRange)
+			if err == nil && mediaType == problemContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeJson(w http.ResponseWriter, status int, contentType string, body any) {
+	res, err := json.Marshal(body)
+	if err != nil {
+		WriteInternalServerError(w)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(res)
+}
+
+func WriteOk(w http.ResponseWriter, data any) {
+	WriteOkStatus(w, http.StatusOK, data)
+}
+
+func WriteOkStatus(w http.ResponseWriter, status int, data any) {
+	writeJson(w, status, jsonContentType, NewOkResponse(data))
+}
+
+// Error body is problem details when client accepts application/problem+json, legacy BadResponse otherwise
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code ErrorCode, detail string) {
+	if acceptsProblem(r) {
+		writeJson(w, status, problemContentType, NewProblemResponse(status, code, detail, r.URL.Path))
+		return
+	}
+	writeJson(w, status, jsonContentType, NewBadResponse(newResponseStatus(status), code, detail))
+}
+
+func WriteInternalServerError(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("Internal server error"))
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      []string
+		contentType string
+	}{
+		{"problem", []string{problemContentType}, problemContentType},
+		{"problem with parameters", []string{"application/json;q=0.9, application/problem+json; charset=utf-8"}, problemContentType},
+		{"problem in second header", []string{jsonContentType, problemContentType}, problemContentType},
+		{"any", []string{"*/*"}, jsonContentType},
+		{"json", []string{jsonContentType}, jsonContentType},
+		{"application any", []string{"application/*"}, jsonContentType},
+		{"no accept", nil, jsonContentType},
+		{"malformed accept", []string{"application/problem+json;;"}, jsonContentType},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/ip/x", nil)
+			for _, accept := range test.accept {
+				r.Header.Add("Accept", accept)
+			}
+			WriteError(w, r, http.StatusBadRequest, ErrorInvalidIpAddress, "invalid ip address 'x'")
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != test.contentType {
+				t.Fatalf("Content-Type = %s, want %s", contentType, test.contentType)
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("unmarshal body: %v", err)
+			}
+			want := map[string]any{
+				"code":        float64(ResponseBadRequest),
+				"error":       string(ErrorInvalidIpAddress),
+				"description": "invalid ip address 'x'",
+			}
+			if test.contentType == problemContentType {
+				want = map[string]any{
+					"type":     "urn:ipinfo:problem:" + string(ErrorInvalidIpAddress),
+					"title":    "Bad Request",
+					"status":   float64(http.StatusBadRequest),
+					"detail":   "invalid ip address 'x'",
+					"instance": "/ip/x",
+					"code":     string(ErrorInvalidIpAddress),
+				}
+			}
+			if len(body) != len(want) {
+				t.Fatalf("body = %v, want %v", body, want)
+			}
+			for key, value := range want {
+				if body[key] != value {
+					t.Errorf("body %s = %v, want %v", key, body[key], value)
+				}
+			}
+		})
+	}
+}
+
+// Legacy body keeps its codes: not found apart from other client errors
+func TestWriteErrorLegacyCode(t *testing.T) {
+	tests := []struct {
+		status int
+		want   ResponseStatus
+	}{
+		{http.StatusNotFound, ResponseNotFound},
+		{http.StatusBadRequest, ResponseBadRequest},
+		{http.StatusRequestEntityTooLarge, ResponseBadRequest},
+		{http.StatusInternalServerError, ResponseInternalError},
+		{http.StatusServiceUnavailable, ResponseInternalError},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		WriteError(w, httptest.NewRequest(http.MethodGet, "/", nil), test.status, ErrorInternal, "")
+		var body BadResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unmarshal body: %v", err)
+		}
+		if w.Code != test.status || body.Code != test.want {
+			t.Errorf("WriteError(%d) = %d with code %d, want code %d", test.status, w.Code, body.Code, test.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+	"github.com/KeilWin/ipinfo/internal/utils"
+)
+
+const componentName = "INDEX"
+
+type IndexConfig struct {
+	common.Config
+
+	BasePrefix string
+
+	Enabled         bool
+	RefreshInterval time.Duration
+}
+
+func (p *IndexConfig) NewVariableName(name string) string {
+	return fmt.Sprintf("%s_%s", p.BasePrefix, name)
+}
+
+func (p *IndexConfig) Load() error {
+	var err error
+	var hasError bool
+
+	enabledName := p.NewVariableName("ENABLED")
+	p.Enabled, err = strconv.ParseBool(os.Getenv(enabledName))
+	hasError = CheckLoadIndexConfigError(err, enabledName) || hasError
+
+	refreshIntervalName := p.NewVariableName("REFRESH_INTERVAL")
+	refreshInterval, err := strconv.Atoi(os.Getenv(refreshIntervalName))
+	hasError = CheckLoadIndexConfigError(err, refreshIntervalName) || hasError
+	p.RefreshInterval = time.Duration(refreshInterval) * time.Second
+
+	if hasError {
+		return errors.New("loading index config")
+	}
+	return nil
+}
+
+func (p *IndexConfig) Check() error {
+	if p.Enabled && p.RefreshInterval <= 0 {
+		return errors.New("index refresh interval must be positive")
+	}
+	return nil
+}
+
+func NewIndexConfig(appPrefix string) *IndexConfig {
+	return &IndexConfig{
+		BasePrefix: common.NewBasePrefix(appPrefix, componentName),
+	}
+}
+
+func CheckLoadIndexConfigError(err error, name string) bool {
+	return utils.CheckLoadConfigError(err, name, componentName)
+}
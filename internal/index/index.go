@@ -0,0 +1,96 @@
+package index
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+)
+
+type ipRangeEntry struct {
+	start netip.Addr
+	end   netip.Addr
+	// Greatest end among this and all previous entries, lets lookup skip back over nested ranges
+	maxEnd netip.Addr
+	row    *database.IpAddressInfoRow
+}
+
+type IpRangeIndex struct {
+	Version string
+
+	v4 []ipRangeEntry
+	v6 []ipRangeEntry
+}
+
+func (p *IpRangeIndex) Len() int {
+	return len(p.v4) + len(p.v6)
+}
+
+func (p *IpRangeIndex) Lookup(addr netip.Addr) *database.IpAddressInfoRow {
+	addr = addr.WithZone("")
+	entries := p.v6
+	if addr.Is4() {
+		entries = p.v4
+	}
+
+	// Index of first entry which starts after addr
+	i, _ := slices.BinarySearchFunc(entries, addr, func(entry ipRangeEntry, addr netip.Addr) int {
+		if entry.start.Compare(addr) <= 0 {
+			return -1
+		}
+		return 1
+	})
+	for i--; i >= 0 && entries[i].maxEnd.Compare(addr) > 0; i-- {
+		if entries[i].end.Compare(addr) > 0 {
+			return entries[i].row
+		}
+	}
+	return nil
+}
+
+func newIpRangeEntries(entries []ipRangeEntry) []ipRangeEntry {
+	// Of ranges with equal start the inner one goes last, so lookup walking back meets it first
+	slices.SortFunc(entries, func(a, b ipRangeEntry) int {
+		if c := a.start.Compare(b.start); c != 0 {
+			return c
+		}
+		return b.end.Compare(a.end)
+	})
+	for i := range entries {
+		entries[i].maxEnd = entries[i].end
+		if i > 0 && entries[i-1].maxEnd.Compare(entries[i].end) > 0 {
+			entries[i].maxEnd = entries[i-1].maxEnd
+		}
+	}
+	return entries
+}
+
+func NewIpRangeIndex(version string, rows []*database.IpAddressInfoRow) (*IpRangeIndex, error) {
+	v4 := make([]ipRangeEntry, 0, len(rows))
+	v6 := make([]ipRangeEntry, 0, len(rows)/2)
+	for _, row := range rows {
+		start, err := netip.ParseAddr(row.IpRangeStart)
+		if err != nil {
+			return nil, fmt.Errorf("parse range start of '%s': %w", row.Id, err)
+		}
+		end, err := netip.ParseAddr(row.IpRangeEnd)
+		if err != nil {
+			return nil, fmt.Errorf("parse range end of '%s': %w", row.Id, err)
+		}
+		if start.Is4() != end.Is4() {
+			return nil, fmt.Errorf("range '%s' mixes address families", row.Id)
+		}
+		entry := ipRangeEntry{start: start, end: end, row: row}
+		if start.Is4() {
+			v4 = append(v4, entry)
+		} else {
+			v6 = append(v6, entry)
+		}
+	}
+	return &IpRangeIndex{
+		Version: version,
+		v4:      newIpRangeEntries(v4),
+		v6:      newIpRangeEntries(v6),
+	}, nil
+}
@@ -0,0 +1,89 @@
+package index
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+)
+
+func newTestIndex(t *testing.T, ranges ...[2]string) *IpRangeIndex {
+	t.Helper()
+	rows := make([]*database.IpAddressInfoRow, len(ranges))
+	for i, r := range ranges {
+		rows[i] = &database.IpAddressInfoRow{Id: r[0] + "-" + r[1], IpRangeStart: r[0], IpRangeEnd: r[1]}
+	}
+	index, err := NewIpRangeIndex("1", rows)
+	if err != nil {
+		t.Fatalf("NewIpRangeIndex: %v", err)
+	}
+	return index
+}
+
+func TestLookup(t *testing.T) {
+	index := newTestIndex(t,
+		[2]string{"10.0.0.0", "11.0.0.0"},
+		[2]string{"10.1.0.0", "10.2.0.0"},
+		[2]string{"10.1.1.0", "10.1.2.0"},
+		[2]string{"10.0.0.0", "10.0.1.0"},
+		[2]string{"20.0.0.0", "20.0.1.0"},
+		[2]string{"20.0.1.0", "20.0.2.0"},
+		[2]string{"2001:db8::", "2001:db9::"},
+		[2]string{"2001:db8:1::", "2001:db8:2::"},
+	)
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"10.0.0.1", "10.0.0.0-10.0.1.0"},
+		{"10.0.2.0", "10.0.0.0-11.0.0.0"},
+		{"10.1.0.5", "10.1.0.0-10.2.0.0"},
+		{"10.1.1.5", "10.1.1.0-10.1.2.0"},
+		{"10.1.2.0", "10.1.0.0-10.2.0.0"},
+		{"10.2.0.0", "10.0.0.0-11.0.0.0"},
+		{"10.255.255.255", "10.0.0.0-11.0.0.0"},
+		{"11.0.0.0", ""},
+		{"20.0.0.255", "20.0.0.0-20.0.1.0"},
+		{"20.0.1.0", "20.0.1.0-20.0.2.0"},
+		{"20.0.2.0", ""},
+		{"0.0.0.0", ""},
+		{"9.255.255.255", ""},
+		{"255.255.255.255", ""},
+		{"2001:db8::1", "2001:db8::-2001:db9::"},
+		{"2001:db8:1::1", "2001:db8:1::-2001:db8:2::"},
+		{"2001:db8:2::", "2001:db8::-2001:db9::"},
+		{"2001:db9::", ""},
+		{"::", ""},
+		{"::a00:1", ""},
+		{"fe80::1%eth0", ""},
+	}
+	for _, test := range tests {
+		row := index.Lookup(netip.MustParseAddr(test.addr))
+		got := ""
+		if row != nil {
+			got = row.Id
+		}
+		if got != test.want {
+			t.Errorf("Lookup(%s) = %q, want %q", test.addr, got, test.want)
+		}
+	}
+}
+
+func TestLookupEmpty(t *testing.T) {
+	index := newTestIndex(t)
+	if index.Len() != 0 {
+		t.Fatalf("Len = %d, want 0", index.Len())
+	}
+	for _, addr := range []string{"0.0.0.0", "10.0.0.1", "::", "2001:db8::1"} {
+		if row := index.Lookup(netip.MustParseAddr(addr)); row != nil {
+			t.Errorf("Lookup(%s) = %v, want nil", addr, row)
+		}
+	}
+}
+
+func TestNewIpRangeIndexMixedFamilies(t *testing.T) {
+	rows := []*database.IpAddressInfoRow{{Id: "1", IpRangeStart: "10.0.0.0", IpRangeEnd: "2001:db8::"}}
+	if _, err := NewIpRangeIndex("1", rows); err == nil {
+		t.Fatalf("NewIpRangeIndex of mixed range = nil, want error")
+	}
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -14,6 +15,7 @@ import (
 	"github.com/KeilWin/ipinfo/internal/dto/database"
 	"github.com/KeilWin/ipinfo/internal/handler"
 	"github.com/KeilWin/ipinfo/internal/logger"
+	"github.com/KeilWin/ipinfo/internal/service"
 	"github.com/KeilWin/ipinfo/internal/utils"
 )
 
@@ -24,6 +26,12 @@ type IpInfoApp struct {
 	server   *http.Server
 	database database.Database
 	cache    cache.Cache
+	index    *dao.IndexedIpAddress
+
+	// Lifecycle of background work, cancelled on shutdown before database is closed
+	ctx        context.Context
+	cancel     context.CancelFunc
+	background sync.WaitGroup
 }
 
 func (p *IpInfoApp) ShutDownHandler() {
@@ -33,6 +41,9 @@ func (p *IpInfoApp) ShutDownHandler() {
 	sig := <-shutDownSignal
 	slog.Info("received signal to term", "sig", sig)
 
+	p.cancel()
+	p.background.Wait()
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 	go func() {
@@ -59,6 +70,18 @@ func (p *IpInfoApp) Start() error {
 		return err
 	}
 
+	if p.index != nil {
+		// Lookups fall back to database until index is loaded
+		if err := p.index.Refresh(p.ctx); err != nil {
+			slog.Error("load index", "error", err)
+		}
+		p.background.Add(1)
+		go func() {
+			defer p.background.Done()
+			p.index.Watch(p.ctx, p.cfg.Index.RefreshInterval)
+		}()
+	}
+
 	go p.ShutDownHandler()
 
 	switch p.cfg.Protocol() {
@@ -79,9 +102,22 @@ func NewApp(appCfg *IpInfoAppConfig) *IpInfoApp {
 	utils.CheckAppFatalError(err)
 	cache, err := cache.NewCache(appCfg.Cache)
 	utils.CheckAppFatalError(err)
-	repository := dao.NewIpAddressRepository(database)
-	handler := handler.NewAppHandler(appCfg.Handler, repository)
+	// Cache is in front of database only, index answers faster than a round trip to cache
+	var repository dao.IpAddressRepository = dao.NewCachedIpAddressRepository(dao.NewIpAddressRepository(database), cache)
+	var index *dao.IndexedIpAddress
+	if appCfg.Index.Enabled {
+		index = dao.NewIndexedIpAddressRepository(database, repository)
+		repository = index
+	}
+	asnService := service.NewAsn(dao.NewAsnRepository(database))
+	holderService := service.NewHolder(dao.NewHolderRepository(database))
+	changeService := service.NewChange(dao.NewChangeRepository(database))
+	exportService := service.NewExport(dao.NewExportRepository(database))
+	webhookService := service.NewWebhook(dao.NewWebhookRepository(database))
+	service := service.NewIpAddress(repository)
+	handler := handler.NewAppHandler(appCfg.Handler, service, asnService, holderService, changeService, exportService, webhookService)
 	server := NewAppServer(handler, appCfg.Server)
+	ctx, cancel := context.WithCancel(context.Background())
 	return &IpInfoApp{
 		cfg:      appCfg,
 		logger:   logger,
@@ -89,6 +125,9 @@ func NewApp(appCfg *IpInfoAppConfig) *IpInfoApp {
 		server:   server,
 		database: database,
 		cache:    cache,
+		index:    index,
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
@@ -9,6 +9,7 @@ import (
 	"github.com/KeilWin/ipinfo/internal/dto/cache"
 	"github.com/KeilWin/ipinfo/internal/dto/database"
 	"github.com/KeilWin/ipinfo/internal/handler"
+	"github.com/KeilWin/ipinfo/internal/index"
 	"github.com/KeilWin/ipinfo/internal/logger"
 )
 
@@ -26,17 +27,18 @@ type IpInfoAppConfig struct {
 	Handler    *handler.HandlerConfig
 	Cache      *cache.CacheConfig
 	Database   *database.DatabaseConfig
+	Index      *index.IndexConfig
 }
 
 func (p *IpInfoAppConfig) Load() error {
-	if p.Server.Load() != nil || p.Handler.Load() != nil || p.Cache.Load() != nil || p.Database.Load() != nil || p.Logger.Load() != nil {
+	if p.Server.Load() != nil || p.Handler.Load() != nil || p.Cache.Load() != nil || p.Database.Load() != nil || p.Index.Load() != nil || p.Logger.Load() != nil {
 		return errors.New("loading app config")
 	}
 	return nil
 }
 
 func (p *IpInfoAppConfig) Check() error {
-	if p.Server.Check() != nil || p.Handler.Check() != nil || p.Cache.Check() != nil || p.Database.Check() != nil || p.Logger.Check() != nil {
+	if p.Server.Check() != nil || p.Handler.Check() != nil || p.Cache.Check() != nil || p.Database.Check() != nil || p.Index.Check() != nil || p.Logger.Check() != nil {
 		return errors.New("checking app config")
 	}
 	return nil
@@ -62,5 +64,6 @@ func NewIpInfoAppConfig() *IpInfoAppConfig {
 		Handler:    handler.NewHandlerConfig(AppName),
 		Cache:      cache.NewCacheConfig(AppName),
 		Database:   database.NewDatabaseConfig(AppName),
+		Index:      index.NewIndexConfig(AppName),
 	}
 }
@@ -10,10 +10,13 @@ import (
 	"time"
 
 	"github.com/KeilWin/ipinfo/internal/common"
+	"github.com/KeilWin/ipinfo/internal/dao"
 	"github.com/KeilWin/ipinfo/internal/dto/cache"
 	"github.com/KeilWin/ipinfo/internal/dto/database"
+	"github.com/KeilWin/ipinfo/internal/export"
 	"github.com/KeilWin/ipinfo/internal/logger"
 	"github.com/KeilWin/ipinfo/internal/utils"
+	"github.com/KeilWin/ipinfo/internal/webhook"
 )
 
 type IpInfoUpdaterApp struct {
@@ -23,6 +26,10 @@ type IpInfoUpdaterApp struct {
 	logger   *slog.Logger
 	database database.Database
 	cache    cache.Cache
+
+	notifier   *webhook.Notifier
+	dispatcher *webhook.Dispatcher
+	exporter   *export.Exporter
 }
 
 func (p *IpInfoUpdaterApp) ShutDownHandler() {
@@ -60,16 +67,37 @@ func (p *IpInfoUpdaterApp) Start() error {
 	}
 	go p.ShutDownHandler()
 
+	sources, err := p.newSources("")
+	if err != nil {
+		p.cache.ShutDown()
+		p.database.ShutDown()
+		return err
+	}
+	rirManagers := make([]*RirManager, 0, len(sources))
+	schedulers := make([]*Scheduler, 0, len(sources))
+	for i, source := range sources {
+		schedule, err := ParseSchedule(p.config.Sources[i].Schedule)
+		if err != nil {
+			p.cache.ShutDown()
+			p.database.ShutDown()
+			return err
+		}
+		rirManagers = append(rirManagers, NewRirManager(source, p.config.Guard, p.database, p.cache, p.notifier, ctx))
+		schedulers = append(schedulers, NewScheduler(source.Rir().DbName, schedule, p.config.Scheduler, NewRealClock()))
+	}
+
+	go p.dispatcher.Run(ctx)
+	go p.exporter.Run(ctx)
+
 	var wg sync.WaitGroup
-	wg.Add(len(Rirs))
-	for _, rir := range Rirs {
+	wg.Add(len(rirManagers))
+	for i, rirManager := range rirManagers {
 		go func() {
 			defer func() {
-				slog.Info("finish", "rir", rir.DbName)
+				slog.Info("finish", "rir", rirManager.Rir.DbName, "source", rirManager.Source.Name())
 				wg.Done()
 			}()
-			rirManager := NewRirManager(rir, p.database, ctx, time.Date(0, 0, 0, 4, 0, 0, 0, time.UTC))
-			workLoop := NewWorkLoop(rirManager, 30*time.Minute)
+			workLoop := NewWorkLoop(ctx, rirManager, schedulers[i])
 			workLoop()
 		}()
 	}
@@ -78,17 +106,16 @@ func (p *IpInfoUpdaterApp) Start() error {
 	return nil
 }
 
-func NewWorkLoop(rirManager *RirManager, retryPause time.Duration) func() {
+func NewWorkLoop(ctx context.Context, rirManager *RirManager, scheduler *Scheduler) func() {
 	return func() {
 		slog.Info("start workloop", "rir", rirManager.Rir.DbName)
-		for {
-			err := rirManager.Start()
-			if err != nil {
-				slog.Error("update rir", "rir", rirManager.Rir.DbName, "error", err, "retry_after(minutes)", retryPause.Minutes())
-				time.Sleep(retryPause)
-				continue
-			}
+		// Without last update time source is checked immediately
+		lastChecked, err := rirManager.GetLastChecked()
+		if err != nil {
+			slog.Error("get last checked", "rir", rirManager.Rir.DbName, "error", err)
+			lastChecked = &time.Time{}
 		}
+		scheduler.Run(ctx, *lastChecked, rirManager.Start)
 	}
 }
 
@@ -99,10 +126,13 @@ func NewApp(cfg *IpInfoUpdaterConfig) *IpInfoUpdaterApp {
 	cache, err := cache.NewCache(cfg.Cache)
 	utils.CheckAppFatalError(err)
 	return &IpInfoUpdaterApp{
-		config:   cfg,
-		logger:   logger,
-		database: database,
-		cache:    cache,
+		config:     cfg,
+		logger:     logger,
+		database:   database,
+		cache:      cache,
+		notifier:   webhook.NewNotifier(dao.NewWebhookRepository(database), dao.NewChangeRepository(database), cfg.Webhook),
+		dispatcher: webhook.NewDispatcher(database, cfg.Webhook),
+		exporter:   export.NewExporter(database, cfg.Export),
 	}
 }
 
@@ -113,8 +143,8 @@ func Start() {
 		}
 	}()
 
-	cfg, err := bootstrap()
+	cfg, args, err := bootstrap()
 	utils.CheckAppFatalError(err)
 	app := NewApp(cfg)
-	utils.CheckAppFatalError(app.Start())
+	os.Exit(int(app.RunCommand(args)))
 }
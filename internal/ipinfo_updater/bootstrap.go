@@ -17,22 +17,24 @@ func loadEnv() error {
 	var envFile string
 	flag.StringVar(&envFile, "env", dotEnvFilename(), "Environment filepath")
 	flag.StringVar(&envFile, "e", dotEnvFilename(), "Environment filepath")
+	flag.Usage = usage
 	flag.Parse()
 
 	return godotenv.Load(envFile)
 }
 
-func bootstrap() (*IpInfoUpdaterConfig, error) {
+// Returns command line arguments left after global flags: command and its flags
+func bootstrap() (*IpInfoUpdaterConfig, []string, error) {
 	setBootstrapLogger()
 	if err := loadEnv(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	appConfig := NewIpInfoUpdaterConfig()
 	if err := appConfig.Load(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if err := appConfig.Check(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return appConfig, nil
+	return appConfig, flag.Args(), nil
 }
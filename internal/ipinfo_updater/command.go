@@ -0,0 +1,387 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/export"
+	"github.com/KeilWin/ipinfo/internal/utils"
+)
+
+const (
+	// Endless update loop by schedule, used without command
+	DaemonCommand = "daemon"
+	// Update once and exit
+	RunOnceCommand = "run-once"
+	// Load local registry file
+	ImportCommand = "import"
+	// Print state of stored data
+	StatusCommand = "status"
+	// Fetch and parse without writes
+	VerifyCommand = "verify"
+	// Write stored ranges to MaxMind DB or aggregated prefix list
+	ExportCommand = "export"
+)
+
+func usage() {
+	output := flag.CommandLine.Output()
+	fmt.Fprintf(output, "Usage: %s [-env file] [command] [flags]\n\n", os.Args[0])
+	fmt.Fprintf(output, "Commands:\n")
+	fmt.Fprintf(output, "  %-10s update by schedule until terminated (default)\n", DaemonCommand)
+	fmt.Fprintf(output, "  %-10s update once and exit: [--rir=name] [--force]\n", RunOnceCommand)
+	fmt.Fprintf(output, "  %-10s load local registry file: --rir=name --file=path [--checksum] [--force]\n", ImportCommand)
+	fmt.Fprintf(output, "  %-10s print last update, serial and row counts per rir\n", StatusCommand)
+	fmt.Fprintf(output, "  %-10s fetch and parse without writes: [--rir=name] [--file=path]\n", VerifyCommand)
+	fmt.Fprintf(output, "  %-10s write stored ranges to MaxMind DB or prefix list: [--format=name] [--file=path]\n", ExportCommand)
+	fmt.Fprintf(output, "  %-10s [--country=RU,CN] [--family=ipv4] [--name=set] [--status=allocated,assigned]\n\n", "")
+	fmt.Fprintf(output, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+// Data rejected by checks is reported by own exit code, so jobs can alert on it separately
+func NewExitCode(err error) utils.ExitCodeType {
+	switch {
+	case err == nil:
+		return utils.ExitSuccess
+	case errors.Is(err, ErrVerification), errors.Is(err, ErrCountMismatch), errors.Is(err, ErrRegistryMismatch), errors.Is(err, ErrGuardrail):
+		return utils.ExitRejected
+	default:
+		return utils.ExitError
+	}
+}
+
+// Error wins over rejected data: failed rir needs attention even when another one is only rejected
+func NewExitCodeOfMany(errs []error) utils.ExitCodeType {
+	code := utils.ExitSuccess
+	for _, err := range errs {
+		switch NewExitCode(err) {
+		case utils.ExitError:
+			return utils.ExitError
+		case utils.ExitRejected:
+			code = utils.ExitRejected
+		}
+	}
+	return code
+}
+
+func (p *IpInfoUpdaterApp) RunCommand(args []string) utils.ExitCodeType {
+	command := DaemonCommand
+	if len(args) != 0 {
+		command, args = args[0], args[1:]
+	}
+	if command == DaemonCommand {
+		utils.CheckAppFatalError(p.Start())
+		return utils.ExitSuccess
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	rir := flags.String("rir", "", "rir name: arin, apnic, afrinic, lacnic, ripencc")
+	var run func(ctx context.Context) utils.ExitCodeType
+	// Arguments are checked before connecting to storages
+	validate := func() error { return nil }
+	switch command {
+	case RunOnceCommand:
+		force := flags.Bool("force", false, "update even if data is not modified")
+		run = func(ctx context.Context) utils.ExitCodeType {
+			return p.RunOnce(ctx, *rir, *force)
+		}
+	case ImportCommand:
+		file := flags.String("file", "", "registry file: plain, gzip or bzip2")
+		checksum := flags.Bool("checksum", false, "verify file with companion .md5 file")
+		force := flags.Bool("force", true, "import even if serial is not changed")
+		validate = func() error {
+			if *rir == "" || *file == "" {
+				return errors.New("--rir and --file are required")
+			}
+			return nil
+		}
+		run = func(ctx context.Context) utils.ExitCodeType {
+			return p.Import(ctx, *rir, *file, *checksum, *force)
+		}
+	case StatusCommand:
+		run = func(ctx context.Context) utils.ExitCodeType {
+			return p.Status(ctx, *rir, os.Stdout)
+		}
+	case VerifyCommand:
+		file := flags.String("file", "", "registry file to verify instead of configured source")
+		validate = func() error {
+			if *file != "" && *rir == "" {
+				return errors.New("--rir is required with --file")
+			}
+			return nil
+		}
+		run = func(ctx context.Context) utils.ExitCodeType {
+			return p.Verify(ctx, *rir, *file, os.Stdout)
+		}
+	case ExportCommand:
+		format := flags.String("format", string(export.MmdbFormat), "mmdb, nginx, haproxy, ipset, nftables or plain")
+		file := flags.String("file", "", "file replaced only when it is written and verified, mmdb defaults to configured path, lists to stdout")
+		countryCodes := flags.String("country", "", "comma separated country codes of list, all when empty")
+		family := flags.String("family", "", "ipv4 or ipv6 of list, both when empty")
+		name := flags.String("name", export.DefaultListName, "ipset sets or nftables table and sets of list")
+		statuses := flags.String("status", "", "comma separated statuses of list, allocated and assigned when empty")
+		query := &entity.ExportQuery{}
+		validate = func() error {
+			if export.Format(*format) == export.MmdbFormat {
+				// MaxMind DB has every range, filters of list would be silently ignored
+				listFlags := make([]string, 0)
+				flags.Visit(func(f *flag.Flag) {
+					if slices.Contains([]string{"country", "family", "name", "status"}, f.Name) {
+						listFlags = append(listFlags, "--"+f.Name)
+					}
+				})
+				if len(listFlags) != 0 {
+					return fmt.Errorf("%s can't be used with --format=%s", strings.Join(listFlags, ", "), export.MmdbFormat)
+				}
+				if *file == "" {
+					*file = p.config.Export.MmdbPath
+				}
+				if *file == "" {
+					return fmt.Errorf("--file or %s is required", p.config.Export.NewVariableName("MMDB_PATH"))
+				}
+				return nil
+			}
+			query.Format, query.CountryCodes, query.Family, query.Name = *format, parseList(*countryCodes), *family, *name
+			query.Statuses = parseList(*statuses)
+			return export.CheckQuery(query)
+		}
+		run = func(ctx context.Context) utils.ExitCodeType {
+			return p.Export(ctx, *file, query)
+		}
+	default:
+		fmt.Fprintf(flag.CommandLine.Output(), "unknown command: %s\n", command)
+		flag.Usage()
+		return utils.ExitUsage
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return utils.ExitUsage
+	}
+	if *rir != "" && common.FindRirByDbName(*rir) == -1 {
+		fmt.Fprintf(flags.Output(), "unknown rir: %s\n", *rir)
+		return utils.ExitUsage
+	}
+	if err := validate(); err != nil {
+		fmt.Fprintln(flags.Output(), err)
+		flags.Usage()
+		return utils.ExitUsage
+	}
+
+	if err := p.database.StartUp(); err != nil {
+		slog.Error("database start up", "error", err)
+		return utils.ExitError
+	}
+	defer p.database.ShutDown()
+	return run(ctx)
+}
+
+// Sources of configured rirs, of one rir when it is given
+func (p *IpInfoUpdaterApp) newSources(rir string) ([]Source, error) {
+	sources := make([]Source, 0, len(p.config.Sources))
+	for _, sourceConfig := range p.config.Sources {
+		if rir != "" && sourceConfig.Rir != rir {
+			continue
+		}
+		source, err := NewSource(sourceConfig, p.config.RegistryFilePath)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no source configured for rir %s", rir)
+	}
+	return sources, nil
+}
+
+func (p *IpInfoUpdaterApp) update(ctx context.Context, sources []Source, force bool) utils.ExitCodeType {
+	if err := p.cache.StartUp(); err != nil {
+		slog.Error("cache start up", "error", err)
+		return utils.ExitError
+	}
+	defer p.cache.ShutDown()
+
+	// File of previous run is rewritten only when something is published
+	if err := p.exporter.Observe(ctx); err != nil {
+		slog.Warn("observe export", "error", err)
+	}
+	errs := make([]error, 0, len(sources))
+	for _, source := range sources {
+		rirManager := NewRirManager(source, p.config.Guard, p.database, p.cache, p.notifier, ctx)
+		rirManager.Force = force
+		if err := rirManager.Start(); err != nil {
+			slog.Error("update rir", "rir", rirManager.Rir.DbName, "source", source.Name(), "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	// Data is already published, failed export doesn't fail update
+	if err := p.exporter.Export(ctx); err != nil {
+		slog.Error("export", "error", err)
+	}
+
+	// Failed deliveries are retried by daemon
+	if err := p.dispatcher.Deliver(ctx); err != nil {
+		slog.Error("deliver webhooks", "error", err)
+	}
+	return NewExitCodeOfMany(errs)
+}
+
+func (p *IpInfoUpdaterApp) RunOnce(ctx context.Context, rir string, force bool) utils.ExitCodeType {
+	sources, err := p.newSources(rir)
+	if err != nil {
+		slog.Error("create sources", "error", err)
+		return utils.ExitError
+	}
+	return p.update(ctx, sources, force)
+}
+
+func (p *IpInfoUpdaterApp) newFileSource(rir, file string, checksum bool) (Source, error) {
+	return NewSource(&SourceConfig{
+		Name:     "file",
+		Type:     FileSourceType,
+		Rir:      rir,
+		Path:     file,
+		Checksum: checksum,
+	}, "")
+}
+
+func (p *IpInfoUpdaterApp) Import(ctx context.Context, rir, file string, checksum, force bool) utils.ExitCodeType {
+	source, err := p.newFileSource(rir, file, checksum)
+	if err != nil {
+		slog.Error("create source", "error", err)
+		return utils.ExitError
+	}
+	return p.update(ctx, []Source{source}, force)
+}
+
+func (p *IpInfoUpdaterApp) Status(ctx context.Context, rir string, output io.Writer) utils.ExitCodeType {
+	sources, err := p.newSources(rir)
+	if err != nil {
+		slog.Error("create sources", "error", err)
+		return utils.ExitError
+	}
+
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "RIR\tSOURCE\tLAST CHECKED\tLAST PUBLISHED\tSERIAL\tROWS\tSTATE\tERROR")
+	errs := make([]error, 0)
+	for _, source := range sources {
+		rirManager := NewRirManager(source, p.config.Guard, p.database, p.cache, p.notifier, ctx)
+		lastChecked, err := rirManager.GetLastChecked()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		lastPublished, err := rirManager.GetLastPublished()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		serial, err := rirManager.GetSerial()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rows, err := p.database.GetRirRowCount(rirManager.Rir.DbName, ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		status, err := rirManager.GetStatus()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if status == nil {
+			status = &UpdateStatus{}
+		}
+
+		// Failed check doesn't refresh last checked time, it is kept in status
+		if status.CheckedAt.After(*lastChecked) {
+			lastChecked = &status.CheckedAt
+		}
+		published := ""
+		if !lastPublished.IsZero() {
+			published = lastPublished.Format(time.DateTime)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", rirManager.Rir.DbName, source.Name(), lastChecked.Format(time.DateTime), published, serial, rows, status.State, status.Error)
+	}
+	writer.Flush()
+
+	for _, err := range errs {
+		slog.Error("status", "error", err)
+	}
+	return NewExitCodeOfMany(errs)
+}
+
+func (p *IpInfoUpdaterApp) Verify(ctx context.Context, rir, file string, output io.Writer) utils.ExitCodeType {
+	var sources []Source
+	var err error
+	if file != "" {
+		var source Source
+		source, err = p.newFileSource(rir, file, false)
+		sources = []Source{source}
+	} else {
+		sources, err = p.newSources(rir)
+	}
+	if err != nil {
+		slog.Error("create sources", "error", err)
+		return utils.ExitError
+	}
+
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "RIR\tSOURCE\tSERIAL\tROWS\tPREVIOUS ROWS\tUNKNOWN STATUSES\tRESULT")
+	errs := make([]error, 0)
+	for _, source := range sources {
+		rirManager := NewRirManager(source, p.config.Guard, p.database, p.cache, p.notifier, ctx)
+		header, report, err := rirManager.Verify()
+		result := "ok"
+		if err != nil {
+			result = err.Error()
+			errs = append(errs, err)
+		}
+		serial := ""
+		if header != nil {
+			serial = header.Serial
+		}
+		if report == nil {
+			report = &GuardReport{}
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", rirManager.Rir.DbName, source.Name(), serial, report.Rows, report.PreviousRows, report.UnknownStatuses, result)
+	}
+	writer.Flush()
+	return NewExitCodeOfMany(errs)
+}
+
+// Query is empty for mmdb
+func (p *IpInfoUpdaterApp) Export(ctx context.Context, file string, query *entity.ExportQuery) utils.ExitCodeType {
+	var err error
+	format := query.Format
+	if format == "" {
+		format = string(export.MmdbFormat)
+		err = p.exporter.WriteMmdb(ctx, file)
+	} else {
+		err = p.exporter.WriteList(ctx, file, query, os.Stdout)
+	}
+	if err != nil {
+		slog.Error("export", "format", format, "file", file, "error", err)
+		return utils.ExitError
+	}
+	return utils.ExitSuccess
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/KeilWin/ipinfo/internal/export"
+	"github.com/KeilWin/ipinfo/internal/utils"
+)
+
+func TestNewExitCodeOfMany(t *testing.T) {
+	failed := errors.New("connection refused")
+	rejected := fmt.Errorf("update: %w: %w", ErrPermanent, ErrGuardrail)
+
+	tests := []struct {
+		errs []error
+		want utils.ExitCodeType
+	}{
+		{nil, utils.ExitSuccess},
+		{[]error{nil, nil}, utils.ExitSuccess},
+		{[]error{nil, rejected}, utils.ExitRejected},
+		{[]error{failed, nil}, utils.ExitError},
+		{[]error{rejected, failed}, utils.ExitError},
+		{[]error{failed, rejected}, utils.ExitError},
+	}
+	for _, test := range tests {
+		if got := NewExitCodeOfMany(test.errs); got != test.want {
+			t.Errorf("NewExitCodeOfMany(%v) = %d, want %d", test.errs, got, test.want)
+		}
+	}
+}
+
+// Arguments are rejected before storages are used, so app without them is enough
+func TestExportCommandArgs(t *testing.T) {
+	app := &IpInfoUpdaterApp{config: &IpInfoUpdaterConfig{Export: export.NewExportConfig(AppName)}}
+	for _, args := range [][]string{
+		{"--country=RU"},
+		{"--format=mmdb", "--family=ipv4", "--file=ipinfo.mmdb"},
+		{"--name=blocked", "--file=ipinfo.mmdb"},
+		{"--status=reserved", "--file=ipinfo.mmdb"},
+		// Mmdb without configured path needs file
+		{"--format=mmdb"},
+		{"--format=plain", "--status=unknown"},
+		{"--format=csv"},
+	} {
+		if code := app.RunCommand(append([]string{ExportCommand}, args...)); code != utils.ExitUsage {
+			t.Errorf("export %v = %d, want usage error %d", args, code, utils.ExitUsage)
+		}
+	}
+}
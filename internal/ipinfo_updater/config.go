@@ -11,8 +11,10 @@ import (
 	"github.com/KeilWin/ipinfo/internal/common"
 	"github.com/KeilWin/ipinfo/internal/dto/cache"
 	"github.com/KeilWin/ipinfo/internal/dto/database"
+	"github.com/KeilWin/ipinfo/internal/export"
 	"github.com/KeilWin/ipinfo/internal/logger"
 	"github.com/KeilWin/ipinfo/internal/utils"
+	"github.com/KeilWin/ipinfo/internal/webhook"
 )
 
 const AppName = "IPINFO_UPDATER"
@@ -39,19 +41,40 @@ type IpInfoUpdaterConfig struct {
 	Cache    *cache.CacheConfig
 
 	RegistryFilePath string
+	Sources          []*SourceConfig
+	Guard            *GuardConfig
+	Webhook          *webhook.WebhookConfig
+	Export           *export.ExportConfig
 	DurationType     DurationType
 	UpdateFrequency  time.Duration
+	// Default schedule of sources, every UpdateFrequency when empty
+	Schedule  string
+	Scheduler SchedulerOptions
 }
 
 func (p *IpInfoUpdaterConfig) Load() error {
 	var err error
 	var hasError bool
 
-	hasError = p.Logger.Load() != nil || p.Database.Load() != nil || p.Cache.Load() != nil
+	hasError = p.Logger.Load() != nil || p.Database.Load() != nil || p.Cache.Load() != nil || p.Guard.Load() != nil || p.Webhook.Load() != nil || p.Export.Load() != nil
 
 	registryFilepathName := p.NewVariableName("REGISTRY_FILEPATH")
 	p.RegistryFilePath = os.Getenv(registryFilepathName)
 
+	sourcesName := p.NewVariableName("SOURCES")
+	sourceNames := parseList(os.Getenv(sourcesName))
+	if len(sourceNames) == 0 {
+		for _, rir := range common.Rirs {
+			sourceNames = append(sourceNames, rir.DbName)
+		}
+	}
+	p.Sources = make([]*SourceConfig, 0, len(sourceNames))
+	for _, name := range sourceNames {
+		source := NewSourceConfig(p.NewVariableName("SOURCE"), name)
+		hasError = source.Load() != nil || hasError
+		p.Sources = append(p.Sources, source)
+	}
+
 	durationTypeName := p.NewVariableName("DURATION_TYPE")
 	p.DurationType = DurationType(os.Getenv(durationTypeName))
 
@@ -70,9 +93,39 @@ func (p *IpInfoUpdaterConfig) Load() error {
 
 	updateFrequencyName := p.NewVariableName("UPDATE_FREQUENCY")
 	updateFrequency, err := strconv.Atoi(os.Getenv(updateFrequencyName))
-	hasError = CheckLoadConfigError(err, updateFrequencyName)
+	hasError = CheckLoadConfigError(err, updateFrequencyName) || hasError
 	p.UpdateFrequency = time.Duration(updateFrequency) * duration
 
+	p.Schedule = os.Getenv(p.NewVariableName("SCHEDULE"))
+	if p.Schedule == "" {
+		p.Schedule = fmt.Sprintf("@every %s", p.UpdateFrequency)
+	}
+	for _, source := range p.Sources {
+		if source.Schedule == "" {
+			source.Schedule = p.Schedule
+		}
+	}
+
+	durations := []struct {
+		name         string
+		value        *time.Duration
+		defaultValue time.Duration
+	}{
+		{"SCHEDULE_JITTER", &p.Scheduler.Jitter, 0},
+		{"RETRY_INITIAL", &p.Scheduler.RetryInitial, time.Minute},
+		{"RETRY_MAX", &p.Scheduler.RetryMax, 30 * time.Minute},
+	}
+	for _, duration := range durations {
+		name := p.NewVariableName(duration.name)
+		seconds, err := parseOptionalInt(os.Getenv(name), int(duration.defaultValue/time.Second))
+		hasError = CheckLoadConfigError(err, name) || hasError
+		*duration.value = time.Duration(seconds) * time.Second
+	}
+
+	retryLimitName := p.NewVariableName("RETRY_LIMIT")
+	p.Scheduler.RetryLimit, err = parseOptionalInt(os.Getenv(retryLimitName), 5)
+	hasError = CheckLoadConfigError(err, retryLimitName) || hasError
+
 	if hasError {
 		return errors.New("loading app config")
 	}
@@ -84,9 +137,135 @@ func (p *IpInfoUpdaterConfig) NewVariableName(name string) string {
 }
 
 func (p *IpInfoUpdaterConfig) Check() error {
+	if err := p.Guard.Check(); err != nil {
+		return err
+	}
+	if err := p.Webhook.Check(); err != nil {
+		return err
+	}
+	if err := p.Export.Check(); err != nil {
+		return err
+	}
+	if p.Scheduler.Jitter < 0 || p.Scheduler.RetryInitial <= 0 || p.Scheduler.RetryMax < p.Scheduler.RetryInitial || p.Scheduler.RetryLimit < 0 {
+		return fmt.Errorf("bad retry or jitter settings: %+v", p.Scheduler)
+	}
+
+	rirs := make(map[string]string, len(p.Sources))
+	for _, source := range p.Sources {
+		if err := source.Check(); err != nil {
+			return err
+		}
+		if other, ok := rirs[source.Rir]; ok {
+			return fmt.Errorf("sources %s and %s update the same rir: %s", other, source.Name, source.Rir)
+		}
+		rirs[source.Rir] = source.Name
+		if source.Type == FileSourceType && source.Path == "" && p.RegistryFilePath == "" {
+			return fmt.Errorf("%s or %s is required for file source %s", source.NewVariableName("PATH"), p.NewVariableName("REGISTRY_FILEPATH"), source.Name)
+		}
+	}
+	return nil
+}
+
+type SourceConfig struct {
+	BasePrefix string
+
+	Name string
+	Type SourceType
+	Rir  string
+	// Base url of mirror for rir type, url template for url type
+	Url string
+	// Directory with registry files for file type
+	Path string
+	// Feed of rir type and registry file names, {domain}, {path} and {file} placeholders, defaults of rir when empty
+	Domain    string
+	Directory string
+	File      string
+	// Verify md5 from companion .md5 file, on by default for rir type only
+	Checksum bool
+	// Local keyring to verify companion .asc signature with gpgv, empty to skip
+	Keyring string
+	// Cron expression or @every interval
+	Schedule string
+}
+
+// Empty type defaults to rir, empty rir defaults to source name
+func (p *SourceConfig) Load() error {
+	p.Type = SourceType(os.Getenv(p.NewVariableName("TYPE")))
+	if p.Type == "" {
+		p.Type = RirSourceType
+	}
+	p.Rir = os.Getenv(p.NewVariableName("RIR"))
+	if p.Rir == "" {
+		p.Rir = p.Name
+	}
+	p.Url = os.Getenv(p.NewVariableName("URL"))
+	p.Path = os.Getenv(p.NewVariableName("PATH"))
+	p.Domain = os.Getenv(p.NewVariableName("DOMAIN"))
+	p.Directory = os.Getenv(p.NewVariableName("DIRECTORY"))
+	p.File = os.Getenv(p.NewVariableName("FILE"))
+	p.Keyring = os.Getenv(p.NewVariableName("KEYRING"))
+	p.Schedule = os.Getenv(p.NewVariableName("SCHEDULE"))
+
+	checksumName := p.NewVariableName("CHECKSUM")
+	if checksum := os.Getenv(checksumName); checksum != "" {
+		var err error
+		p.Checksum, err = strconv.ParseBool(checksum)
+		if CheckLoadConfigError(err, checksumName) {
+			return err
+		}
+	} else {
+		p.Checksum = p.Type == RirSourceType
+	}
+	return nil
+}
+
+func (p *SourceConfig) NewVariableName(name string) string {
+	return fmt.Sprintf("%s_%s_%s", p.BasePrefix, strings.ToUpper(p.Name), name)
+}
+
+func (p *SourceConfig) Check() error {
+	switch p.Type {
+	case RirSourceType, FileSourceType:
+	case UrlSourceType:
+		if p.Url == "" {
+			return fmt.Errorf("%s is required for url source", p.NewVariableName("URL"))
+		}
+	default:
+		return fmt.Errorf("unknown source type in %s: %s", p.NewVariableName("TYPE"), p.Type)
+	}
+	if common.FindRirByDbName(p.Rir) == -1 {
+		return fmt.Errorf("unknown rir in %s: %s", p.NewVariableName("RIR"), p.Rir)
+	}
+	if _, err := ParseSchedule(p.Schedule); err != nil {
+		return fmt.Errorf("bad schedule of source %s: %w", p.Name, err)
+	}
 	return nil
 }
 
+func NewSourceConfig(basePrefix, name string) *SourceConfig {
+	return &SourceConfig{
+		BasePrefix: basePrefix,
+		Name:       name,
+	}
+}
+
+func parseOptionalInt(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func parseList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func CheckLoadConfigError(err error, name string) bool {
 	return utils.CheckLoadConfigError(err, name, AppName)
 }
@@ -98,5 +277,8 @@ func NewIpInfoUpdaterConfig() *IpInfoUpdaterConfig {
 		Logger:   logger.NewLoggerConfig(),
 		Cache:    cache.NewCacheConfig(AppName),
 		Database: database.NewDatabaseConfig(AppName),
+		Guard:    NewGuardConfig(AppName + "_GUARD"),
+		Webhook:  webhook.NewWebhookConfig(AppName),
+		Export:   export.NewExportConfig(AppName),
 	}
 }
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+type registryFile struct {
+	io.Reader
+
+	closers []io.Closer
+}
+
+func (p *registryFile) Close() error {
+	var errs []error
+	for i := len(p.closers) - 1; i >= 0; i-- {
+		errs = append(errs, p.closers[i].Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Extended file is preferred, plain delegated file has no opaque ids but is parsed the same way
+func newRegistryFileNames(rir *common.Rir) []string {
+	fileNames := make([]string, 0, 6)
+	for _, name := range []string{rir.FileName, rir.DbName} {
+		fileName := fmt.Sprintf("delegated-%s-latest", name)
+		fileNames = append(fileNames, fileName, fileName+".gz", fileName+".bz2")
+	}
+	return fileNames
+}
+
+func FindRegistryFile(dir string, rir *common.Rir) (string, error) {
+	for _, fileName := range newRegistryFileNames(rir) {
+		path := filepath.Join(dir, fileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no registry file for %s in %s", rir.DbName, dir)
+}
+
+// Compression is detected by magic bytes, so file extension doesn't matter
+func OpenRegistryFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(file)
+	magic, err := reader.Peek(3)
+	if err != nil && !errors.Is(err, io.EOF) {
+		file.Close()
+		return nil, fmt.Errorf("read magic: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("open gzip: %w", err)
+		}
+		return &registryFile{Reader: gzipReader, closers: []io.Closer{file, gzipReader}}, nil
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return &registryFile{Reader: bzip2.NewReader(reader), closers: []io.Closer{file}}, nil
+	default:
+		return &registryFile{Reader: reader, closers: []io.Closer{file}}, nil
+	}
+}
@@ -0,0 +1,195 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+)
+
+var ErrGuardrail = errors.New("guardrail check failed")
+
+// Zero value of every limit disables its check
+type GuardConfig struct {
+	BasePrefix string
+
+	// Max change of row count in percent versus current data
+	MaxChangePercent float64
+	MinRows          uint64
+	// Max share of records with unknown status in percent
+	MaxUnknownStatusPercent float64
+	// Rejected country codes in addition to malformed ones
+	InvalidCountryCodes []string
+	// Directory for snapshots failed checks, empty to only reject them
+	QuarantinePath string
+}
+
+func (p *GuardConfig) Load() error {
+	var err error
+	var hasError bool
+
+	maxChangePercentName := p.NewVariableName("MAX_CHANGE_PERCENT")
+	p.MaxChangePercent, err = parseOptionalFloat(os.Getenv(maxChangePercentName))
+	hasError = CheckLoadConfigError(err, maxChangePercentName) || hasError
+
+	minRowsName := p.NewVariableName("MIN_ROWS")
+	if minRows := os.Getenv(minRowsName); minRows != "" {
+		p.MinRows, err = strconv.ParseUint(minRows, 10, 64)
+		hasError = CheckLoadConfigError(err, minRowsName) || hasError
+	}
+
+	maxUnknownStatusPercentName := p.NewVariableName("MAX_UNKNOWN_STATUS_PERCENT")
+	p.MaxUnknownStatusPercent, err = parseOptionalFloat(os.Getenv(maxUnknownStatusPercentName))
+	hasError = CheckLoadConfigError(err, maxUnknownStatusPercentName) || hasError
+
+	p.InvalidCountryCodes = parseList(os.Getenv(p.NewVariableName("INVALID_COUNTRY_CODES")))
+	p.QuarantinePath = os.Getenv(p.NewVariableName("QUARANTINE_PATH"))
+
+	if hasError {
+		return errors.New("loading guard config")
+	}
+	return nil
+}
+
+func (p *GuardConfig) NewVariableName(name string) string {
+	return fmt.Sprintf("%s_%s", p.BasePrefix, name)
+}
+
+func (p *GuardConfig) Check() error {
+	if p.MaxChangePercent < 0 || p.MaxUnknownStatusPercent < 0 || p.MaxUnknownStatusPercent > 100 {
+		return fmt.Errorf("%s and %s must be percents", p.NewVariableName("MAX_CHANGE_PERCENT"), p.NewVariableName("MAX_UNKNOWN_STATUS_PERCENT"))
+	}
+	if p.QuarantinePath != "" {
+		if info, err := os.Stat(p.QuarantinePath); err != nil || !info.IsDir() {
+			return fmt.Errorf("%s is not a directory: %s", p.NewVariableName("QUARANTINE_PATH"), p.QuarantinePath)
+		}
+	}
+	return nil
+}
+
+func parseOptionalFloat(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func NewGuardConfig(basePrefix string) *GuardConfig {
+	return &GuardConfig{
+		BasePrefix: basePrefix,
+	}
+}
+
+type GuardReport struct {
+	Rows                 uint64            `json:"rows"`
+	PreviousRows         uint64            `json:"previousRows"`
+	ChangePercent        float64           `json:"changePercent"`
+	UnknownStatuses      uint64            `json:"unknownStatuses"`
+	UnknownStatusPercent float64           `json:"unknownStatusPercent"`
+	InvalidCountryCodes  map[string]uint64 `json:"invalidCountryCodes,omitempty"`
+	Failures             []string          `json:"failures,omitempty"`
+}
+
+// Collects statistics of snapshot while it is streamed to storage
+type Guard struct {
+	config *GuardConfig
+	report *GuardReport
+}
+
+// Empty country code is used by some rirs for available and reserved records
+func (p *Guard) isValidCountryCode(code string) bool {
+	if code == "" {
+		return true
+	}
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return false
+	}
+	return !slices.Contains(p.config.InvalidCountryCodes, code)
+}
+
+func (p *Guard) collect(record common.RirRecord) {
+	var countryCode string
+	var statusId int
+	if record.IpRange != nil {
+		countryCode, statusId = record.IpRange.CountryCode, record.IpRange.StatusId
+	} else if record.AsnRange != nil {
+		countryCode, statusId = record.AsnRange.CountryCode, record.AsnRange.StatusId
+	} else {
+		return
+	}
+
+	p.report.Rows++
+	if statusId == UnknownStatus {
+		p.report.UnknownStatuses++
+	}
+	if !p.isValidCountryCode(countryCode) {
+		p.report.InvalidCountryCodes[countryCode]++
+	}
+}
+
+func (p *Guard) check() error {
+	report := p.report
+	if report.PreviousRows != 0 {
+		report.ChangePercent = math.Abs(float64(report.Rows)-float64(report.PreviousRows)) / float64(report.PreviousRows) * 100
+	}
+	if report.Rows != 0 {
+		report.UnknownStatusPercent = float64(report.UnknownStatuses) / float64(report.Rows) * 100
+	}
+
+	if p.config.MinRows != 0 && report.Rows < p.config.MinRows {
+		report.Failures = append(report.Failures, fmt.Sprintf("rows %d less than min %d", report.Rows, p.config.MinRows))
+	}
+	if p.config.MaxChangePercent != 0 && report.PreviousRows != 0 && report.ChangePercent > p.config.MaxChangePercent {
+		report.Failures = append(report.Failures, fmt.Sprintf("row count changed by %.2f%% from %d to %d, max %.2f%%", report.ChangePercent, report.PreviousRows, report.Rows, p.config.MaxChangePercent))
+	}
+	if p.config.MaxUnknownStatusPercent != 0 && report.UnknownStatusPercent > p.config.MaxUnknownStatusPercent {
+		report.Failures = append(report.Failures, fmt.Sprintf("unknown statuses %.2f%%, max %.2f%%", report.UnknownStatusPercent, p.config.MaxUnknownStatusPercent))
+	}
+	if len(report.InvalidCountryCodes) != 0 {
+		report.Failures = append(report.Failures, fmt.Sprintf("%d invalid country codes", len(report.InvalidCountryCodes)))
+	}
+
+	if len(report.Failures) != 0 {
+		return fmt.Errorf("%w: %s", ErrGuardrail, strings.Join(report.Failures, "; "))
+	}
+	return nil
+}
+
+// Checks are run after the last record, so failed snapshot is never published
+func (p *Guard) Records(records iter.Seq2[common.RirRecord, error]) iter.Seq2[common.RirRecord, error] {
+	return func(yield func(common.RirRecord, error) bool) {
+		for record, err := range records {
+			if err != nil {
+				yield(record, err)
+				return
+			}
+			p.collect(record)
+			if !yield(record, nil) {
+				return
+			}
+		}
+		if err := p.check(); err != nil {
+			yield(common.RirRecord{}, err)
+		}
+	}
+}
+
+func (p *Guard) Report() *GuardReport {
+	return p.report
+}
+
+func NewGuard(config *GuardConfig, previousRows uint64) *Guard {
+	return &Guard{
+		config: config,
+		report: &GuardReport{
+			PreviousRows:        previousRows,
+			InvalidCountryCodes: make(map[string]uint64),
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	ErrCountMismatch    = errors.New("count mismatch")
+	ErrRegistryMismatch = errors.New("registry mismatch")
+)
+
+// Version line and summary lines of delegated file
+type DelegatedHeader struct {
+	Version   string
+	Registry  string
+	Serial    string
+	Records   uint64
+	StartDate sql.NullTime
+	EndDate   sql.NullTime
+	UtcOffset string
+	// Count of records by type: ipv4, ipv6, asn
+	Summaries map[string]uint64
+}
+
+// version|registry|serial|records|startdate|enddate|UTCoffset
+func parseVersionLine(valArray []string, line string) (*DelegatedHeader, error) {
+	if len(valArray) < 7 {
+		return nil, fmt.Errorf("not enough fields in version line: %s", line)
+	}
+
+	records, err := strconv.ParseUint(valArray[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse records = '%s' from version line: %s", valArray[3], line)
+	}
+	startDate, err := parseStatusChangedAt(valArray[4])
+	if err != nil {
+		return nil, fmt.Errorf("can't parse start date = '%s' from version line: %s", valArray[4], line)
+	}
+	endDate, err := parseStatusChangedAt(valArray[5])
+	if err != nil {
+		return nil, fmt.Errorf("can't parse end date = '%s' from version line: %s", valArray[5], line)
+	}
+
+	return &DelegatedHeader{
+		Version:   valArray[0],
+		Registry:  valArray[1],
+		Serial:    valArray[2],
+		Records:   records,
+		StartDate: startDate,
+		EndDate:   endDate,
+		UtcOffset: valArray[6],
+		Summaries: make(map[string]uint64, 3),
+	}, nil
+}
+
+// registry|*|type|*|count|summary
+func isSummaryLine(valArray []string) bool {
+	return len(valArray) == 6 && valArray[5] == "summary"
+}
+
+func (p *DelegatedHeader) parseSummaryLine(valArray []string, line string) error {
+	count, err := strconv.ParseUint(valArray[4], 10, 64)
+	if err != nil {
+		return fmt.Errorf("can't parse count = '%s' from summary line: %s", valArray[4], line)
+	}
+	p.Summaries[valArray[2]] = count
+	return nil
+}
+
+// Truncated file has less records than announced by header
+func (p *DelegatedHeader) Validate(counts map[string]uint64) error {
+	var errs []error
+	var total uint64
+	for _, recordType := range []string{IpVersions[0], IpVersions[1], "asn"} {
+		expected, ok := p.Summaries[recordType]
+		if !ok {
+			continue
+		}
+		if counts[recordType] != expected {
+			errs = append(errs, fmt.Errorf("%w: %s summary %d, parsed %d", ErrCountMismatch, recordType, expected, counts[recordType]))
+		}
+	}
+	for _, count := range counts {
+		total += count
+	}
+	if total != p.Records {
+		errs = append(errs, fmt.Errorf("%w: version line records %d, parsed %d", ErrCountMismatch, p.Records, total))
+	}
+	return errors.Join(errs...)
+}
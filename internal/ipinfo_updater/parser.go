@@ -0,0 +1,281 @@
+package app
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+)
+
+// Parser of RIR delegated statistics exchange format, plain and extended
+type DelegatedParser struct {
+	// Registry expected in version line, empty accepts any
+	Registry string
+}
+
+// Serial is the 3rd field of version line: version|registry|serial|records|startdate|enddate|UTCoffset.
+// Returned reader yields data from the start, so it can be parsed as usual.
+func (p *DelegatedParser) Serial(data io.Reader) (string, io.Reader, error) {
+	reader := bufio.NewReader(data)
+	var consumed strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		consumed.WriteString(line)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", nil, err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			if errors.Is(err, io.EOF) {
+				return "", nil, fmt.Errorf("no version line")
+			}
+			continue
+		}
+
+		header, err := parseVersionLine(strings.Split(line, "|"), line)
+		if err != nil {
+			return "", nil, err
+		}
+		return header.Serial, io.MultiReader(strings.NewReader(consumed.String()), reader), nil
+	}
+}
+
+func parseStatusChangedAt(value string) (sql.NullTime, error) {
+	// Extended files use zero date for available and reserved records
+	if value == "" || value == "00000000" {
+		return sql.NullTime{Valid: false}, nil
+	}
+	date, err := time.Parse("20060102", value)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: date, Valid: true}, nil
+}
+
+func parseStatusId(value string, line string) int {
+	statusId := slices.Index(Statuses[:], value)
+	if statusId == -1 {
+		slog.Info("unknown status", "status", value, "line", line)
+		return UnknownStatus
+	}
+	return statusId + 1
+}
+
+// Opaque id is the 8th field of extended format, it links all records of one holder
+func parseOpaqueId(valArray []string) sql.NullString {
+	if len(valArray) < 8 || valArray[7] == "" {
+		return sql.NullString{Valid: false}
+	}
+	return sql.NullString{String: valArray[7], Valid: true}
+}
+
+func parseIpRange(valArray []string, line string) (*common.IpRange, error) {
+	versionIpId := slices.Index(IpVersions[:], valArray[2])
+	if versionIpId == -1 {
+		return nil, fmt.Errorf("can't parse versionIpId = '%s' from line: %s", valArray[2], line)
+	}
+
+	addrStart, err := netip.ParseAddr(valArray[3])
+	if err != nil {
+		return nil, fmt.Errorf("can't parse ip: %w", err)
+	}
+	var addrEnd *netip.Addr
+	var quantity *big.Int
+	var prefixLength sql.NullInt32
+	if addrStart.Is4() {
+		// For ipv4 the value field is a count of addresses, not always a power of two
+		count, err := strconv.ParseUint(valArray[4], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse quantity: %w", err)
+		}
+		addrEnd = NewEndRangeIpAddressV4(addrStart, uint32(count))
+		quantity = new(big.Int).SetUint64(count)
+	} else if addrStart.Is6() {
+		// For ipv6 the value field is a CIDR prefix length
+		bits, err := strconv.Atoi(valArray[4])
+		if err != nil {
+			return nil, fmt.Errorf("can't parse prefix length: %w", err)
+		}
+		prefix := netip.PrefixFrom(addrStart, bits)
+		if !prefix.IsValid() {
+			return nil, fmt.Errorf("invalid prefix length = '%s' from line: %s", valArray[4], line)
+		}
+		if prefix.Masked() != prefix {
+			return nil, fmt.Errorf("prefix %s has host bits set from line: %s", prefix, line)
+		}
+		addrEnd, err = NewEndRangeIpAddressV6(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("can't compute addrEnd: %w", err)
+		}
+		quantity = NewIpV6PrefixQuantity(bits)
+		prefixLength = sql.NullInt32{Int32: int32(bits), Valid: true}
+	} else {
+		return nil, fmt.Errorf("unknown ip format = '%s' from line: %s", addrStart.String(), line)
+	}
+
+	statusChangedAt, err := parseStatusChangedAt(valArray[5])
+	if err != nil {
+		return nil, fmt.Errorf("can't parse date = '%s' from line: %s", valArray[5], line)
+	}
+
+	return &common.IpRange{
+		CountryCode:     valArray[1],
+		IpVersionId:     versionIpId + 1,
+		StartIp:         valArray[3],
+		EndIp:           addrEnd.String(),
+		Quantity:        quantity,
+		PrefixLength:    prefixLength,
+		StatusId:        parseStatusId(valArray[6], line),
+		StatusChangedAt: statusChangedAt,
+		OpaqueId:        parseOpaqueId(valArray),
+	}, nil
+}
+
+func parseAsnRange(valArray []string, line string) (*common.AsnRange, error) {
+	startAsn, err := strconv.ParseUint(valArray[3], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse asn = '%s' from line: %s", valArray[3], line)
+	}
+	quantity, err := strconv.ParseUint(valArray[4], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse asn quantity = '%s' from line: %s", valArray[4], line)
+	}
+
+	statusChangedAt, err := parseStatusChangedAt(valArray[5])
+	if err != nil {
+		return nil, fmt.Errorf("can't parse date = '%s' from line: %s", valArray[5], line)
+	}
+
+	return &common.AsnRange{
+		CountryCode:     valArray[1],
+		StartAsn:        uint32(startAsn),
+		EndAsn:          startAsn + quantity,
+		Quantity:        quantity,
+		StatusId:        parseStatusId(valArray[6], line),
+		StatusChangedAt: statusChangedAt,
+		OpaqueId:        parseOpaqueId(valArray),
+	}, nil
+}
+
+// Records are parsed lazily, so whole file is never kept in memory
+type DelegatedStream struct {
+	registry string
+	reader   *bufio.Reader
+	header   *DelegatedHeader
+	counts   map[string]uint64
+}
+
+func (p *DelegatedParser) Parse(data io.Reader) *DelegatedStream {
+	return &DelegatedStream{
+		registry: p.Registry,
+		reader:   bufio.NewReaderSize(data, 1<<20),
+		counts:   make(map[string]uint64, 3),
+	}
+}
+
+// Header is validated against parsed records after the last one, so consumer
+// must not publish data before iteration ends without error
+func (p *DelegatedStream) Records() iter.Seq2[common.RirRecord, error] {
+	return func(yield func(common.RirRecord, error) bool) {
+		var err error
+		var line string
+
+		for {
+			line, err = p.reader.ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				yield(common.RirRecord{}, err)
+				return
+			}
+			eof := errors.Is(err, io.EOF)
+			line = strings.TrimSpace(line)
+			if line == "" || line[0] == '#' {
+				if eof {
+					break
+				}
+				continue
+			}
+
+			valArray := strings.Split(line, "|")
+			if p.header == nil {
+				if p.header, err = parseVersionLine(valArray, line); err != nil {
+					yield(common.RirRecord{}, fmt.Errorf("parse header: %w", err))
+					return
+				}
+				// Data of another registry would replace data of this one
+				if p.registry != "" && p.header.Registry != p.registry {
+					yield(common.RirRecord{}, fmt.Errorf("%w: version line of %s, expected %s", ErrRegistryMismatch, p.header.Registry, p.registry))
+					return
+				}
+			} else if isSummaryLine(valArray) {
+				if err = p.header.parseSummaryLine(valArray, line); err != nil {
+					yield(common.RirRecord{}, fmt.Errorf("parse header: %w", err))
+					return
+				}
+			} else {
+				record, recordType, err := parseRecord(valArray, line)
+				if err != nil {
+					yield(common.RirRecord{}, err)
+					return
+				}
+				p.counts[recordType]++
+				if !yield(record, nil) {
+					return
+				}
+			}
+
+			if eof {
+				break
+			}
+		}
+
+		if p.header == nil {
+			yield(common.RirRecord{}, fmt.Errorf("parse header: no version line"))
+			return
+		}
+		if err = p.header.Validate(p.counts); err != nil {
+			yield(common.RirRecord{}, err)
+		}
+	}
+}
+
+// Valid only after records are read without error
+func (p *DelegatedStream) Header() *DelegatedHeader {
+	return p.header
+}
+
+// Returns type of record: ipv4, ipv6 or asn
+func parseRecord(valArray []string, line string) (common.RirRecord, string, error) {
+	if len(valArray) < 7 {
+		return common.RirRecord{}, "", fmt.Errorf("not enough fields in line: %s", line)
+	}
+
+	if common.FindRirByDbName(valArray[0]) == -1 {
+		return common.RirRecord{}, "", fmt.Errorf("can't parse rirId from line: %s", line)
+	}
+
+	if valArray[2] == "asn" {
+		asnRange, err := parseAsnRange(valArray, line)
+		if err != nil {
+			return common.RirRecord{}, "", err
+		}
+		return common.RirRecord{AsnRange: asnRange}, valArray[2], nil
+	}
+
+	ipRange, err := parseIpRange(valArray, line)
+	if err != nil {
+		return common.RirRecord{}, "", err
+	}
+	return common.RirRecord{IpRange: ipRange}, valArray[2], nil
+}
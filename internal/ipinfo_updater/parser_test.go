@@ -0,0 +1,137 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+)
+
+const benchRecords = 100000
+
+func TestParseRegistry(t *testing.T) {
+	data := "2|ripencc|20240101|1|19830705|20240101|+0100\n" +
+		"ripencc|*|ipv4|*|1|summary\n" +
+		"ripencc|FR|ipv4|1.0.0.0|256|20100712|allocated\n"
+	tests := []struct {
+		registry string
+		err      error
+	}{
+		{"ripencc", nil},
+		{"", nil},
+		{"arin", ErrRegistryMismatch},
+	}
+	for _, test := range tests {
+		parser := DelegatedParser{Registry: test.registry}
+		var err error
+		records := 0
+		for _, err = range parser.Parse(strings.NewReader(data)).Records() {
+			if err != nil {
+				break
+			}
+			records++
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("Parse for %q error = %v, want %v", test.registry, err, test.err)
+		}
+		// Records of other registry are rejected before the first one is given to consumer
+		if test.err != nil && records != 0 {
+			t.Errorf("Parse for %q gave %d records, want 0", test.registry, records)
+		}
+	}
+}
+
+// Same layout as RIR files: version line, summaries, then asn, ipv4 and ipv6 records
+func newDelegatedFile(b *testing.B, records int) []byte {
+	b.Helper()
+	asnCount := records / 10
+	ipv6Count := records / 4
+	ipv4Count := records - asnCount - ipv6Count
+
+	var buf bytes.Buffer
+	writer := bufio.NewWriter(&buf)
+	fmt.Fprintf(writer, "2|ripencc|%d|%d|19830705|20240101|+0100\n", time.Now().Unix(), records)
+	fmt.Fprintf(writer, "ripencc|*|asn|*|%d|summary\n", asnCount)
+	fmt.Fprintf(writer, "ripencc|*|ipv4|*|%d|summary\n", ipv4Count)
+	fmt.Fprintf(writer, "ripencc|*|ipv6|*|%d|summary\n", ipv6Count)
+
+	for i := range asnCount {
+		fmt.Fprintf(writer, "ripencc|DE|asn|%d|1|20100712|allocated|holder-%d\n", i+1, i%5000)
+	}
+	ipv4 := netip.MustParseAddr("1.0.0.0")
+	for i := range ipv4Count {
+		fmt.Fprintf(writer, "ripencc|FR|ipv4|%s|256|20100712|allocated|holder-%d\n", ipv4, i%5000)
+		ipv4 = NewEndRangeIpAddressV4(ipv4, 256).Unmap()
+	}
+	ipv6 := netip.PrefixFrom(netip.MustParseAddr("2001::"), 48)
+	for i := range ipv6Count {
+		fmt.Fprintf(writer, "ripencc|NL|ipv6|%s|48|20100712|allocated|holder-%d\n", ipv6.Addr(), i%5000)
+		end, err := NewEndRangeIpAddressV6(ipv6)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ipv6 = netip.PrefixFrom(*end, 48)
+	}
+	if err := writer.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkParse(b *testing.B) {
+	data := newDelegatedFile(b, benchRecords)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	var parser DelegatedParser
+	for b.Loop() {
+		count := 0
+		for _, err := range parser.Parse(bytes.NewReader(data)).Records() {
+			if err != nil {
+				b.Fatal(err)
+			}
+			count++
+		}
+		if count != benchRecords {
+			b.Fatalf("records = %d, want %d", count, benchRecords)
+		}
+	}
+}
+
+// Publishes generated file as ripencc data, so it needs a scratch database with migrations applied,
+// configured by IPINFO_BENCH_DATABASE_* variables the same way as IPINFO_UPDATER_DATABASE_*
+func BenchmarkCopyIn(b *testing.B) {
+	config := database.NewDatabaseConfig("IPINFO_BENCH")
+	if os.Getenv(config.NewVariableName("HOST")) == "" {
+		b.Skip("scratch database is not configured by IPINFO_BENCH_DATABASE_*")
+	}
+	if err := config.Load(); err != nil {
+		b.Fatal(err)
+	}
+	db := database.NewPostgreSqlDatabase(config)
+	if err := db.StartUp(); err != nil {
+		b.Fatal(err)
+	}
+	defer db.ShutDown()
+
+	data := newDelegatedFile(b, benchRecords)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	var parser DelegatedParser
+	ctx := context.Background()
+	for i := 0; b.Loop(); i++ {
+		records := parser.Parse(bytes.NewReader(data)).Records()
+		if _, err := db.UpdateRirData("ripencc", fmt.Sprintf("bench-%d", i), records, ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
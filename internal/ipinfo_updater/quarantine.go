@@ -0,0 +1,80 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+)
+
+// Keeps copy of snapshot while it is parsed, so snapshot failed checks can be inspected later
+type Quarantine struct {
+	dir  string
+	name string
+	file *os.File
+}
+
+func (p *Quarantine) Wrap(reader io.Reader) io.Reader {
+	if p.file == nil {
+		return reader
+	}
+	return io.TeeReader(reader, p.file)
+}
+
+// Returns path of quarantined snapshot, report is written next to it
+func (p *Quarantine) Keep(report *GuardReport) (string, error) {
+	if p.file == nil {
+		return "", nil
+	}
+	tempPath := p.file.Name()
+	p.file.Close()
+	p.file = nil
+
+	path := filepath.Join(p.dir, p.name)
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return "", fmt.Errorf("rename: %w", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return path, err
+	}
+	if err = os.WriteFile(path+".json", data, 0o644); err != nil {
+		return path, fmt.Errorf("write report: %w", err)
+	}
+	return path, nil
+}
+
+func (p *Quarantine) Discard() error {
+	if p.file == nil {
+		return nil
+	}
+	tempPath := p.file.Name()
+	err := p.file.Close()
+	p.file = nil
+	return errors.Join(err, os.Remove(tempPath))
+}
+
+// Without dir snapshot is not kept
+func NewQuarantine(dir string, rir *common.Rir) (*Quarantine, error) {
+	quarantine := &Quarantine{
+		dir:  dir,
+		name: fmt.Sprintf("delegated-%s-%s", rir.FileName, time.Now().UTC().Format("20060102T150405Z")),
+	}
+	if dir == "" {
+		return quarantine, nil
+	}
+
+	file, err := os.CreateTemp(dir, "."+quarantine.name+"-*")
+	if err != nil {
+		return nil, err
+	}
+	quarantine.file = file
+	return quarantine, nil
+}
@@ -1,59 +1,22 @@
 package app
 
 import (
-	"bufio"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"io"
+	"iter"
 	"log/slog"
 	"math/big"
-	"net/http"
 	"net/netip"
-	"slices"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/KeilWin/ipinfo/internal/common"
+	"github.com/KeilWin/ipinfo/internal/dto/cache"
 	"github.com/KeilWin/ipinfo/internal/dto/database"
+	"github.com/KeilWin/ipinfo/internal/webhook"
 )
 
-type Rir struct {
-	Domain   string
-	PathName string
-	FileName string
-	DbName   string
-}
-
-func NewRir(domain, pathName, fileName, dbName string) *Rir {
-	return &Rir{
-		Domain:   domain,
-		PathName: pathName,
-		FileName: fileName,
-		DbName:   dbName,
-	}
-}
-
-var Rirs = [5]*Rir{
-	NewRir("arin", "arin", "arin-extended", "arin"),
-	NewRir("apnic", "apnic", "apnic", "apnic"),
-	NewRir("afrinic", "afrinic", "afrinic", "afrinic"),
-	NewRir("lacnic", "lacnic", "lacnic", "lacnic"),
-	NewRir("ripe", "ripencc", "ripencc", "ripencc"),
-}
-
-func FindRirByDbName(name string) int {
-	for i, rir := range Rirs {
-		if rir.DbName == name {
-			return i
-		}
-	}
-
-	return -1
-}
-
 var IpVersions = [2]string{
 	"ipv4",
 	"ipv6",
@@ -68,10 +31,6 @@ var Statuses = [4]string{
 
 const UnknownStatus = 5
 
-func newDownloadUrl(rir *Rir) string {
-	return fmt.Sprintf("https://ftp.%s.net/pub/stats/%s/delegated-%s-latest", rir.Domain, rir.PathName, rir.FileName)
-}
-
 func NewEndRangeIpAddressV4(addrStart netip.Addr, quantity uint32) *netip.Addr {
 	buf := addrStart.As4()
 	addrNumber := uint32(0)
@@ -87,11 +46,19 @@ func NewEndRangeIpAddressV4(addrStart netip.Addr, quantity uint32) *netip.Addr {
 	return &endAddr
 }
 
-func NewEndRangeIpAddressV6(addrStart netip.Addr, quantity uint64) (*netip.Addr, error) {
+func NewIpV6PrefixQuantity(prefixLength int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(128-prefixLength))
+}
+
+// End address is exclusive, same as NewEndRangeIpAddressV4
+func NewEndRangeIpAddressV6(prefix netip.Prefix) (*netip.Addr, error) {
 	addrNumber := new(big.Int)
-	addrNumber.SetBytes(addrStart.AsSlice())
-	addrNumber.Add(addrNumber, big.NewInt(int64(quantity)))
+	addrNumber.SetBytes(prefix.Addr().AsSlice())
+	addrNumber.Add(addrNumber, NewIpV6PrefixQuantity(prefix.Bits()))
 	bs := addrNumber.Bytes()
+	if len(bs) > 16 {
+		return nil, fmt.Errorf("end of prefix %s overflows ipv6 address space", prefix)
+	}
 	buf := make([]byte, 16)
 	copy(buf[len(buf)-len(bs):], bs)
 	addrEnd, ok := netip.AddrFromSlice(buf)
@@ -103,221 +70,359 @@ func NewEndRangeIpAddressV6(addrStart netip.Addr, quantity uint64) (*netip.Addr,
 }
 
 type RirManager struct {
-	Rir          *Rir
-	db           database.Database
-	ctx          context.Context
-	timeToUpdate time.Time
+	Rir    *common.Rir
+	Source Source
+	// Update even if source data is not modified since previous update
+	Force       bool
+	guardConfig *GuardConfig
+	db          database.Database
+	cache       cache.Cache
+	notifier    *webhook.Notifier
+	ctx         context.Context
 }
 
-func (p *RirManager) GetLastUpdate() (*time.Time, error) {
-	var lastUpdateDateTime time.Time
-	lastUpdateOption, err := p.db.GetOption(fmt.Sprintf("lastUpdate%s", p.Rir.DbName), p.ctx)
+func (p *RirManager) NewOptionName(name string) string {
+	return fmt.Sprintf("%s%s", name, p.Rir.DbName)
+}
+
+// Missing option is the same as empty one
+func (p *RirManager) getOption(name string) (string, error) {
+	value, err := p.db.GetOption(p.NewOptionName(name), p.ctx)
 	if errors.Is(err, sql.ErrNoRows) {
-		lastUpdateDateTime = time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+		return "", nil
 	} else if err != nil {
-		return nil, fmt.Errorf("get option 'lastUpdate': %w", err)
-	} else {
-		lastUpdateDateTime, err = time.ParseInLocation("2006-01-02 15:04:05", lastUpdateOption, time.UTC)
-		if err != nil {
-			return nil, fmt.Errorf("parse option 'lastUpdate': %w", err)
-		}
+		return "", fmt.Errorf("get option '%s': %w", name, err)
 	}
-	return &lastUpdateDateTime, nil
+	return value, nil
 }
 
-func (p *RirManager) RefreshLastUpdate() (time.Time, error) {
+// Time of last finished check of source, published or not. Option keeps its old name lastUpdate
+func (p *RirManager) GetLastChecked() (*time.Time, error) {
+	lastChecked, err := p.getTimeOption("lastUpdate")
+	if err != nil {
+		return nil, err
+	} else if lastChecked.IsZero() {
+		lastChecked = time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return &lastChecked, nil
+}
+
+func (p *RirManager) RefreshLastChecked() (time.Time, error) {
 	now := time.Now().UTC()
-	return now, p.db.UpdateOption(fmt.Sprintf("lastUpdate%s", p.Rir.DbName), now.Format("2006-01-02 15:04:05"), p.ctx)
+	return now, p.db.UpdateOption(p.NewOptionName("lastUpdate"), now.Format(time.DateTime), p.ctx)
+}
 
+// Time of last update which replaced data, zero when data was never published
+func (p *RirManager) GetLastPublished() (time.Time, error) {
+	return p.getTimeOption("lastPublished")
 }
 
-func (p *RirManager) Start() error {
-	slog.Info("rir manager started", "rir", p.Rir.DbName)
-	lastUpdate, err := p.GetLastUpdate()
+func (p *RirManager) RefreshLastPublished() error {
+	return p.db.UpdateOption(p.NewOptionName("lastPublished"), time.Now().UTC().Format(time.DateTime), p.ctx)
+}
+
+// Missing option is zero time
+func (p *RirManager) getTimeOption(name string) (time.Time, error) {
+	value, err := p.getOption(name)
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+	at, err := time.ParseInLocation(time.DateTime, value, time.UTC)
 	if err != nil {
-		return fmt.Errorf("lastUpdate: %w", err)
+		return time.Time{}, fmt.Errorf("parse option '%s': %w", name, err)
 	}
-	slog.Info("get lastUpdate ok", "lastUpdate", lastUpdate.Format("2006-01-02 15:04:05"))
+	return at, nil
+}
 
-	now := time.Now().UTC()
-	if now.Sub(*lastUpdate).Hours() >= 24 {
-		slog.Info("updating", "rir", p.Rir.DbName)
-		data, err := p.Download()
-		if err != nil {
-			return fmt.Errorf("download: %w", err)
-		}
+func (p *RirManager) RefreshDataVersion() error {
+	return p.db.UpdateOption(database.DataVersionOptionName, time.Now().UTC().Format(time.RFC3339Nano), p.ctx)
+}
 
-		rows, err := p.ParseData(data)
-		if err != nil {
-			return fmt.Errorf("parse data: %w", err)
-		}
+// Names of options with validators of source. Modification time of local file is not Last-Modified of server,
+// so file sources keep it apart and import of a file doesn't make remote source skip or refetch data
+func (p *RirManager) fetchStateOptions() (etag string, lastModified string) {
+	if _, ok := p.Source.(*FileSource); ok {
+		return "", "fileModified"
+	}
+	return "etag", "lastModified"
+}
 
-		err = p.Upload(rows)
-		if err != nil {
-			return fmt.Errorf("update: %w", err)
+func (p *RirManager) GetFetchState() (*FetchState, error) {
+	var err error
+	state := &FetchState{}
+	etag, lastModified := p.fetchStateOptions()
+	if etag != "" {
+		if state.ETag, err = p.getOption(etag); err != nil {
+			return nil, err
 		}
+	}
+	if state.LastModified, err = p.getOption(lastModified); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
 
-		nowDt, err := p.RefreshLastUpdate()
-		if err != nil {
-			return fmt.Errorf("refresh lastUpdate: %w", err)
-		}
+// Saved only after data is stored, otherwise failed update would never be retried
+func (p *RirManager) RefreshFetchState(state *FetchState) error {
+	etag, lastModified := p.fetchStateOptions()
+	options := [][2]string{{lastModified, state.LastModified}}
+	if etag != "" {
+		options = append(options, [2]string{etag, state.ETag})
+	}
+	return p.updateOptions(options)
+}
 
-		slog.Info("successful update", "rir", p.Rir.DbName)
-		time.Sleep(time.Until(time.Date(nowDt.Year(), nowDt.Month(), nowDt.Day(), p.timeToUpdate.Hour(), p.timeToUpdate.Minute(), p.timeToUpdate.Second()+5, p.timeToUpdate.Nanosecond(), time.UTC).Add(24 * time.Hour)))
-	} else {
-		slog.Info("wake up too early", "rir", p.Rir.DbName)
-		time.Sleep(now.Sub(*lastUpdate) + 5*time.Second)
+func (p *RirManager) RefreshHeader(header *DelegatedHeader) error {
+	formatDate := func(date sql.NullTime) string {
+		if !date.Valid {
+			return ""
+		}
+		return date.Time.Format(time.DateOnly)
 	}
+	return p.updateOptions([][2]string{
+		{"serial", header.Serial},
+		{"startDate", formatDate(header.StartDate)},
+		{"endDate", formatDate(header.EndDate)},
+	})
+}
 
+func (p *RirManager) updateOptions(options [][2]string) error {
+	for _, option := range options {
+		if err := p.db.UpdateOption(p.NewOptionName(option[0]), option[1], p.ctx); err != nil {
+			return fmt.Errorf("update option '%s': %w", option[0], err)
+		}
+	}
 	return nil
 }
 
-func (p *RirManager) ParseHeader(reader *bufio.Reader) error {
-	var err error
-	var line string
-
-	for {
-		line, err = reader.ReadString('\n')
-		if errors.Is(err, io.EOF) {
-			break
-		} else if err != nil {
-			return err
-		}
-		line = strings.TrimSpace(line)
-		if line == "" || line[0] == '#' {
-			continue
-		}
-		break
+// One scheduled check of source
+func (p *RirManager) Start() error {
+	slog.Info("rir manager started", "rir", p.Rir.DbName)
+	updated, err := p.Update()
+	if err != nil {
+		return err
 	}
 
-	for i := 0; i < 3; i++ {
-		line, err = reader.ReadString('\n')
-		if errors.Is(err, io.EOF) {
-			break
-		} else if err != nil {
-			return err
-		}
+	if _, err = p.RefreshLastChecked(); err != nil {
+		return fmt.Errorf("refresh last checked: %w", err)
+	}
+
+	if updated {
+		slog.Info("successful update", "rir", p.Rir.DbName)
+	} else {
+		slog.Info("data not changed", "rir", p.Rir.DbName)
 	}
 	return nil
 }
 
-func (p *RirManager) ParseData(data io.ReadCloser) ([]common.IpRange, error) {
-	var err error
-	var line string
+func (p *RirManager) RefreshStatus(status *UpdateStatus) error {
+	return p.db.UpdateOption(p.NewOptionName("status"), status.String(), p.ctx)
+}
 
-	reader := bufio.NewReaderSize(data, 1<<20)
-	if err = p.ParseHeader(reader); err != nil {
-		return nil, fmt.Errorf("parse header: %w", err)
+// Nil when rir was never updated
+func (p *RirManager) GetStatus() (*UpdateStatus, error) {
+	value, err := p.getOption("status")
+	if err != nil || value == "" {
+		return nil, err
 	}
+	return ParseUpdateStatus(value)
+}
 
-	ipRanges := make([]common.IpRange, 0, 10000)
-	for {
-		line, err = reader.ReadString('\n')
-		if errors.Is(err, io.EOF) {
-			break
-		} else if err != nil {
-			return nil, err
-		}
-		line = strings.TrimSpace(line)
-		if line == "" || line[0] == '#' {
-			continue
-		}
+func (p *RirManager) GetSerial() (string, error) {
+	return p.getOption("serial")
+}
 
-		valArray := strings.Split(line, "|")
+// Serial rejected by guardrails, it is not parsed again until upstream publishes another one
+func (p *RirManager) GetQuarantinedSerial() (string, error) {
+	return p.getOption("quarantinedSerial")
+}
 
-		if valArray[2] == "asn" {
-			continue
-		}
+func (p *RirManager) RefreshQuarantinedSerial(serial string) error {
+	return p.db.UpdateOption(p.NewOptionName("quarantinedSerial"), serial, p.ctx)
+}
 
-		rirId := FindRirByDbName(valArray[0])
-		if rirId == -1 {
-			return nil, fmt.Errorf("can't parse rirId from line: %s", line)
-		}
+// Dry run of update: data is fetched, verified, parsed and checked by guardrails, nothing is written
+func (p *RirManager) Verify() (*DelegatedHeader, *GuardReport, error) {
+	data, err := p.Source.Fetch(p.ctx, &FetchState{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch: %w", err)
+	}
+	defer data.Close()
 
-		versionIpId := slices.Index(IpVersions[:], valArray[2])
-		if versionIpId == -1 {
-			return nil, fmt.Errorf("can't parse versionIpId = '%s' from line: %s", valArray[2], line)
+	previousRows, err := p.db.GetRirRowCount(p.Rir.DbName, p.ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("row count: %w", err)
+	}
+	guard := NewGuard(p.guardConfig, previousRows)
+	stream := p.Source.Parse(data)
+	for _, err := range guard.Records(stream.Records()) {
+		if err != nil {
+			return stream.Header(), guard.Report(), err
 		}
+	}
+	return stream.Header(), guard.Report(), nil
+}
 
-		// better uint64 or big.Int
-		quantity, err := strconv.ParseUint(valArray[4], 10, 32)
-		if err != nil {
-			return nil, fmt.Errorf("can't parse quantity: %w", err)
+// Returns false when source data is not modified since previous update
+func (p *RirManager) Update() (bool, error) {
+	status := &UpdateStatus{
+		Source:    p.Source.Name(),
+		CheckedAt: time.Now().UTC(),
+	}
+	err := p.update(status)
+	if err != nil {
+		status.Error = err.Error()
+		if status.State == "" {
+			status.State = FailedUpdateState
 		}
+	}
+	if statusErr := p.RefreshStatus(status); statusErr != nil {
+		slog.Warn("refresh status", "rir", p.Rir.DbName, "error", statusErr)
+	}
+	return status.State == PublishedUpdateState, err
+}
 
-		addrStart, err := netip.ParseAddr(valArray[3])
-		if err != nil {
-			return nil, fmt.Errorf("can't parse ip: %w", err)
+func (p *RirManager) update(status *UpdateStatus) error {
+	slog.Info("updating", "rir", p.Rir.DbName, "source", p.Source.Name(), "location", p.Source.Location())
+	state, err := p.GetFetchState()
+	if err != nil {
+		return fmt.Errorf("fetch state: %w", err)
+	}
+	lastSerial, err := p.GetSerial()
+	if err != nil {
+		return err
+	}
+	quarantinedSerial, err := p.GetQuarantinedSerial()
+	if err != nil {
+		return err
+	}
+	if p.Force {
+		state = &FetchState{}
+		lastSerial = ""
+		quarantinedSerial = ""
+	}
+
+	data, err := p.Source.Fetch(p.ctx, state)
+	if errors.Is(err, ErrNotModified) {
+		status.State = UnchangedUpdateState
+		status.Serial = lastSerial
+		return nil
+	}
+	if errors.Is(err, ErrVerification) {
+		slog.Error("refusing to replace data", "rir", p.Rir.DbName, "source", p.Source.Name(), "location", p.Source.Location(), "error", err)
+	}
+	if err != nil {
+		return fmt.Errorf("fetch: %w", err)
+	}
+	defer data.Close()
+
+	serial, reader, err := p.Source.Serial(data)
+	if err != nil {
+		return fmt.Errorf("read serial: %w", err)
+	}
+	status.Serial = serial
+	if serial != "" && serial == lastSerial {
+		slog.Info("serial not changed", "rir", p.Rir.DbName, "serial", serial)
+		if err = p.RefreshFetchState(state); err != nil {
+			return fmt.Errorf("refresh fetch state: %w", err)
 		}
-		var addrEnd *netip.Addr
-		if addrStart.Is4() {
-			addrEnd = NewEndRangeIpAddressV4(addrStart, uint32(quantity))
-		} else if addrStart.Is6() {
-			addrEnd, err = NewEndRangeIpAddressV6(addrStart, quantity)
-			if err != nil {
-				return nil, fmt.Errorf("can't compute addrEnd: %w", err)
-			}
-		} else {
-			return nil, fmt.Errorf("unknown ip format = '%s' from line: %s", addrStart.String(), line)
+		status.State = UnchangedUpdateState
+		return nil
+	}
+	if serial != "" && serial == quarantinedSerial {
+		slog.Warn("serial quarantined, waiting for the next one", "rir", p.Rir.DbName, "serial", serial)
+		// Previous status keeps report and path of quarantined snapshot
+		if previous, statusErr := p.GetStatus(); statusErr == nil && previous != nil && previous.State == QuarantinedUpdateState {
+			status.Report = previous.Report
+			status.QuarantinePath = previous.QuarantinePath
+			status.Error = previous.Error
 		}
+		status.State = QuarantinedUpdateState
+		return nil
+	}
 
-		var statusChangedAt sql.NullTime
-		if valArray[5] == "" {
-			statusChangedAt = sql.NullTime{Valid: false}
-		} else {
-			date, err := time.Parse("20060102", valArray[5])
-			if err != nil {
-				return nil, fmt.Errorf("can't parse date = '%s' from line: %s", valArray[5], line)
-			}
-			statusChangedAt = sql.NullTime{Time: date, Valid: true}
+	previousRows, err := p.db.GetRirRowCount(p.Rir.DbName, p.ctx)
+	if err != nil {
+		return fmt.Errorf("row count: %w", err)
+	}
+	quarantine, err := NewQuarantine(p.guardConfig.QuarantinePath, p.Rir)
+	if err != nil {
+		return fmt.Errorf("quarantine: %w", err)
+	}
+	guard := NewGuard(p.guardConfig, previousRows)
+	status.Report = guard.Report()
+
+	stream := p.Source.Parse(quarantine.Wrap(reader))
+	changes, err := p.Upload(serial, guard.Records(stream.Records()))
+	if errors.Is(err, ErrGuardrail) {
+		path, quarantineErr := quarantine.Keep(guard.Report())
+		if quarantineErr != nil {
+			slog.Warn("keep quarantined snapshot", "rir", p.Rir.DbName, "error", quarantineErr)
 		}
-
-		statusId := slices.Index(Statuses[:], valArray[6])
-		if statusId == -1 {
-			slog.Info("unknown status = '%s' from line: %s", valArray[6], line)
-			statusId = UnknownStatus
+		status.State = QuarantinedUpdateState
+		status.QuarantinePath = path
+		slog.Error("snapshot quarantined", "rir", p.Rir.DbName, "source", p.Source.Name(), "serial", serial, "path", path, "report", guard.Report(), "error", err)
+		if serial != "" {
+			if serialErr := p.RefreshQuarantinedSerial(serial); serialErr != nil {
+				slog.Warn("refresh quarantined serial", "rir", p.Rir.DbName, "error", serialErr)
+			}
 		}
+		// The same snapshot fails again, so retry waits for the next planned run
+		return fmt.Errorf("update: %w: %w", ErrPermanent, err)
+	}
+	if discardErr := quarantine.Discard(); discardErr != nil {
+		slog.Warn("discard snapshot copy", "rir", p.Rir.DbName, "error", discardErr)
+	}
+	if errors.Is(err, ErrCountMismatch) || errors.Is(err, ErrRegistryMismatch) {
+		slog.Error("refusing to replace data", "rir", p.Rir.DbName, "source", p.Source.Name(), "location", p.Source.Location(), "error", err)
+	}
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+	header := stream.Header()
+	status.Changes = changes
+	slog.Info("published", "rir", p.Rir.DbName, "serial", serial, "baseline", changes.Baseline, "added", changes.Added, "removed", changes.Removed, "changed", changes.Changed)
 
-		ipRanges = append(ipRanges, common.IpRange{
-			RirId:           rirId + 1,
-			CountryCode:     valArray[1],
-			IpVersionId:     versionIpId + 1,
-			StartIp:         valArray[3],
-			EndIp:           addrEnd.String(),
-			Quantity:        quantity,
-			StatusId:        statusId + 1,
-			StatusChangedAt: statusChangedAt,
-		})
+	if err = p.RefreshDataVersion(); err != nil {
+		return fmt.Errorf("refresh data version: %w", err)
 	}
 
-	return ipRanges, nil
-}
+	// Cached lookups may point to replaced ranges
+	if err = p.cache.FlushIpInfo(p.ctx); err != nil {
+		slog.Warn("flush cache", "rir", p.Rir.DbName, "error", err)
+	}
 
-func (p *RirManager) Download() (io.ReadCloser, error) {
-	cli := http.Client{
-		Timeout: 600 * time.Second,
+	if err = p.RefreshHeader(header); err != nil {
+		return fmt.Errorf("refresh header: %w", err)
 	}
-	response, err := cli.Get(newDownloadUrl(p.Rir))
-	if err != nil {
-		return nil, err
+	if err = p.RefreshFetchState(state); err != nil {
+		return fmt.Errorf("refresh fetch state: %w", err)
 	}
-
-	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status code: %s", response.Status)
+	if err = p.RefreshLastPublished(); err != nil {
+		return fmt.Errorf("refresh last published: %w", err)
 	}
+	status.State = PublishedUpdateState
 
-	return response.Body, nil
+	// Data is already published, failed notification doesn't fail update
+	if err = p.notifier.Published(p.ctx, p.Rir.DbName, serial, changes); err != nil {
+		slog.Error("enqueue webhooks", "rir", p.Rir.DbName, "serial", serial, "error", err)
+	}
+	return nil
 }
 
-func (p *RirManager) Upload(data []common.IpRange) error {
-	return p.db.UpdateRirData(p.Rir.DbName, data, p.ctx)
+// Changes compared with previous data are stored with serial as a snapshot
+func (p *RirManager) Upload(serial string, records iter.Seq2[common.RirRecord, error]) (*common.RirDataChanges, error) {
+	return p.db.UpdateRirData(p.Rir.DbName, serial, records, p.ctx)
 }
 
-func NewRirManager(rir *Rir, db database.Database, ctx context.Context, timeToUpdate time.Time) *RirManager {
+func NewRirManager(source Source, guardConfig *GuardConfig, db database.Database, cache cache.Cache, notifier *webhook.Notifier, ctx context.Context) *RirManager {
 	return &RirManager{
-		Rir:          rir,
-		db:           db,
-		ctx:          ctx,
-		timeToUpdate: timeToUpdate,
+		Rir:         source.Rir(),
+		Source:      source,
+		guardConfig: guardConfig,
+		db:          db,
+		cache:       cache,
+		notifier:    notifier,
+		ctx:         ctx,
 	}
 }
@@ -0,0 +1,286 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source of time for scheduler, replaced in tests to run schedule without sleeping
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now().UTC()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func NewRealClock() Clock {
+	return realClock{}
+}
+
+type Schedule interface {
+	// First planned time strictly after given one, zero time if there is none
+	Next(after time.Time) time.Time
+}
+
+type IntervalSchedule struct {
+	Interval time.Duration
+}
+
+func (p *IntervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(p.Interval)
+}
+
+var cronAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Standard 5 fields: minute hour day-of-month month day-of-week, evaluated in UTC.
+// Fields support *, lists, ranges and steps; day of week 7 is sunday too.
+type CronSchedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// When both days are restricted, any of them matches, same as cron.
+	// Day field starting with * (like */2) is not restricted, both days have to match then
+	domAny bool
+	dowAny bool
+}
+
+func (p *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := p.dom&(1<<uint(t.Day())) != 0
+	dowMatch := p.dow&(1<<uint(t.Weekday())) != 0
+	if p.domAny || p.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func (p *CronSchedule) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	// Impossible dates like 30 february are given up after a few leap years
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if p.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !p.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if p.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if p.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, ok := strings.Cut(part, "/"); ok {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("bad step: %s", part)
+			}
+			part = rangePart
+		}
+
+		start, end := min, max
+		if part != "*" {
+			startPart, endPart, isRange := strings.Cut(part, "-")
+			var err error
+			if start, err = strconv.Atoi(startPart); err != nil {
+				return 0, fmt.Errorf("bad value: %s", part)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(endPart); err != nil {
+					return 0, fmt.Errorf("bad value: %s", part)
+				}
+			} else if step != 1 {
+				// a/n means from a to max with step n
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("value out of range %d-%d: %s", min, max, part)
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func ParseCronSchedule(expression string) (*CronSchedule, error) {
+	if alias, ok := cronAliases[expression]; ok {
+		expression = alias
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields: %s", expression)
+	}
+
+	var err error
+	schedule := &CronSchedule{
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}
+	if schedule.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if schedule.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	if schedule.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	return schedule, nil
+}
+
+// Cron expression, alias like @daily or interval like @every 6h
+func ParseSchedule(value string) (Schedule, error) {
+	if interval, ok := strings.CutPrefix(value, "@every "); ok {
+		duration, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil {
+			return nil, err
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("interval must be positive: %s", value)
+		}
+		return &IntervalSchedule{Interval: duration}, nil
+	}
+	return ParseCronSchedule(value)
+}
+
+// Failure which retry won't fix, wrapped by job to wait for the next planned run
+var ErrPermanent = errors.New("permanent failure")
+
+type SchedulerOptions struct {
+	// Random delay up to jitter is added to every planned run, so rirs don't start at the same second
+	Jitter time.Duration
+	// Pause after first failure, doubled after every next one up to RetryMax
+	RetryInitial time.Duration
+	RetryMax     time.Duration
+	// After so many failed retries in a row the next planned run is awaited
+	RetryLimit int
+}
+
+type Scheduler struct {
+	name     string
+	schedule Schedule
+	options  SchedulerOptions
+	clock    Clock
+	// Random duration in [0, n)
+	random func(n time.Duration) time.Duration
+}
+
+func (p *Scheduler) backoff(failures int) time.Duration {
+	pause := p.options.RetryInitial
+	for i := 1; i < failures && pause < p.options.RetryMax; i++ {
+		pause *= 2
+	}
+	return min(pause, p.options.RetryMax)
+}
+
+// Returns time of next run and whether it is a retry
+func (p *Scheduler) Next(now time.Time, failures int) (time.Time, bool) {
+	if failures > 0 && failures <= p.options.RetryLimit {
+		return now.Add(p.backoff(failures)), true
+	}
+
+	next := p.schedule.Next(now)
+	if !next.IsZero() && p.options.Jitter > 0 {
+		next = next.Add(p.random(p.options.Jitter))
+	}
+	return next, false
+}
+
+// Runs job by schedule until ctx is done. Run missed since lastRun is started immediately.
+func (p *Scheduler) Run(ctx context.Context, lastRun time.Time, job func() error) {
+	next := p.schedule.Next(lastRun)
+	failures := 0
+	for ctx.Err() == nil {
+		if next.IsZero() {
+			slog.Error("no next run in schedule", "name", p.name)
+			return
+		}
+		if wait := next.Sub(p.clock.Now()); wait > 0 {
+			slog.Info("next run", "name", p.name, "at", next.Format(time.RFC3339))
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.clock.After(wait):
+			}
+		}
+
+		err := job()
+		if err != nil && !errors.Is(err, ErrPermanent) {
+			failures++
+		} else {
+			failures = 0
+		}
+
+		var retry bool
+		next, retry = p.Next(p.clock.Now(), failures)
+		if err != nil {
+			slog.Error("scheduled run", "name", p.name, "error", err, "failures", failures, "retry", retry, "next", next.Format(time.RFC3339))
+		}
+		if !retry {
+			failures = 0
+		}
+	}
+}
+
+func NewScheduler(name string, schedule Schedule, options SchedulerOptions, clock Clock) *Scheduler {
+	return &Scheduler{
+		name:     name,
+		schedule: schedule,
+		options:  options,
+		clock:    clock,
+		random: func(n time.Duration) time.Duration {
+			return rand.N(n)
+		},
+	}
+}
@@ -0,0 +1,266 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Time moves only when scheduler waits, every wait ends at once
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (p *fakeClock) Now() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.now
+}
+
+func (p *fakeClock) After(d time.Duration) <-chan time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.now = p.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- p.now
+	return ch
+}
+
+func parseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	at, err := time.Parse(time.DateTime, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return at
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	tests := []struct {
+		expression string
+		after      string
+		want       string
+	}{
+		{"0 4 * * *", "2024-01-31 03:59:30", "2024-01-31 04:00:00"},
+		// Planned time itself is not returned
+		{"0 4 * * *", "2024-01-31 04:00:00", "2024-02-01 04:00:00"},
+		{"*/15 * * * *", "2024-01-31 10:07:00", "2024-01-31 10:15:00"},
+		{"@hourly", "2024-01-31 23:30:00", "2024-02-01 00:00:00"},
+		{"@monthly", "2024-12-15 00:00:00", "2025-01-01 00:00:00"},
+		{"@yearly", "2024-06-01 00:00:00", "2025-01-01 00:00:00"},
+		// Months without 31st are skipped
+		{"0 0 31 * *", "2024-04-01 00:00:00", "2024-05-31 00:00:00"},
+		{"0 0 29 2 *", "2025-01-01 00:00:00", "2028-02-29 00:00:00"},
+		{"0 0 1 3,9 *", "2024-03-01 00:00:00", "2024-09-01 00:00:00"},
+		// Saturday to monday
+		{"30 12 * * 1-5", "2024-06-01 08:00:00", "2024-06-03 12:30:00"},
+		// Day of week 7 is sunday
+		{"0 0 * * 7", "2024-06-03 00:00:00", "2024-06-09 00:00:00"},
+		{"@weekly", "2024-06-03 00:00:00", "2024-06-09 00:00:00"},
+		// Both days restricted: friday or 13th, whichever comes first
+		{"0 0 13 * 5", "2024-09-01 00:00:00", "2024-09-06 00:00:00"},
+		{"0 0 13 * 5", "2024-09-10 00:00:00", "2024-09-13 00:00:00"},
+		{"0 0 13 * 5", "2024-09-13 00:00:00", "2024-09-20 00:00:00"},
+		// Stepped star is not a restriction, so odd day has to be monday
+		{"0 0 */2 * 1", "2024-09-01 00:00:00", "2024-09-09 00:00:00"},
+		// 13th has to be sunday, tuesday, thursday or saturday
+		{"0 0 13 * */2", "2024-09-01 00:00:00", "2024-10-13 00:00:00"},
+		// Restricted day of week in restricted month
+		{"0 6 * 2 1", "2024-01-01 00:00:00", "2024-02-05 06:00:00"},
+	}
+	for _, test := range tests {
+		schedule, err := ParseSchedule(test.expression)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", test.expression, err)
+		}
+		got := schedule.Next(parseTime(t, test.after))
+		if want := parseTime(t, test.want); !got.Equal(want) {
+			t.Errorf("Next(%q, %s) = %s, want %s", test.expression, test.after, got, want)
+		}
+	}
+}
+
+func TestCronScheduleImpossibleDate(t *testing.T) {
+	schedule, err := ParseSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := schedule.Next(parseTime(t, "2024-01-01 00:00:00")); !next.IsZero() {
+		t.Fatalf("Next of 30 february = %s, want zero", next)
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expression := range []string{
+		"", "0 4 * *", "0 4 * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *",
+		"* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@every", "@every 0s", "@every -1h", "@every 1x",
+	} {
+		if _, err := ParseSchedule(expression); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded", expression)
+		}
+	}
+}
+
+func TestIntervalSchedule(t *testing.T) {
+	schedule, err := ParseSchedule("@every 6h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := parseTime(t, "2024-01-31 22:10:00")
+	if got, want := schedule.Next(after), parseTime(t, "2024-02-01 04:10:00"); !got.Equal(want) {
+		t.Fatalf("Next = %s, want %s", got, want)
+	}
+}
+
+func newTestScheduler(options SchedulerOptions, clock Clock) *Scheduler {
+	return NewScheduler("test", &IntervalSchedule{Interval: time.Hour}, options, clock)
+}
+
+func TestSchedulerBackoff(t *testing.T) {
+	scheduler := newTestScheduler(SchedulerOptions{RetryInitial: time.Minute, RetryMax: 10 * time.Minute, RetryLimit: 6}, &fakeClock{})
+	now := parseTime(t, "2024-01-31 04:00:00")
+
+	for failures, want := range map[int]time.Duration{
+		1: time.Minute,
+		2: 2 * time.Minute,
+		3: 4 * time.Minute,
+		4: 8 * time.Minute,
+		5: 10 * time.Minute,
+		6: 10 * time.Minute,
+	} {
+		next, retry := scheduler.Next(now, failures)
+		if !retry || next.Sub(now) != want {
+			t.Errorf("Next after %d failures = +%s, %v, want +%s retry", failures, next.Sub(now), retry, want)
+		}
+	}
+
+	// Retries are over, planned run is awaited
+	next, retry := scheduler.Next(now, 7)
+	if retry || next.Sub(now) != time.Hour {
+		t.Fatalf("Next after retry limit = +%s, %v, want +1h planned", next.Sub(now), retry)
+	}
+	if next, retry = scheduler.Next(now, 0); retry || next.Sub(now) != time.Hour {
+		t.Fatalf("Next after success = +%s, %v, want +1h planned", next.Sub(now), retry)
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	now := parseTime(t, "2024-01-31 04:00:00")
+	planned := now.Add(time.Hour)
+	options := SchedulerOptions{Jitter: 5 * time.Minute, RetryInitial: time.Minute, RetryMax: time.Minute, RetryLimit: 1}
+
+	scheduler := newTestScheduler(options, &fakeClock{})
+	for range 1000 {
+		next, _ := scheduler.Next(now, 0)
+		if next.Before(planned) || !next.Before(planned.Add(options.Jitter)) {
+			t.Fatalf("Next with jitter = %s, want in [%s, %s)", next, planned, planned.Add(options.Jitter))
+		}
+	}
+
+	// Retries are not delayed by jitter
+	scheduler.random = func(n time.Duration) time.Duration { return n - 1 }
+	if next, retry := scheduler.Next(now, 1); !retry || !next.Equal(now.Add(time.Minute)) {
+		t.Fatalf("retry with jitter = %s, want %s", next, now.Add(time.Minute))
+	}
+	if next, _ := scheduler.Next(now, 0); !next.Equal(planned.Add(options.Jitter - 1)) {
+		t.Fatalf("Next with max jitter = %s, want %s", next, planned.Add(options.Jitter-1))
+	}
+}
+
+// Job results are returned in turn, ctx is canceled after the last one
+func runScheduler(t *testing.T, scheduler *Scheduler, clock *fakeClock, lastRun time.Time, results ...error) []time.Time {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := make([]time.Time, 0, len(results))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scheduler.Run(ctx, lastRun, func() error {
+			runs = append(runs, clock.Now())
+			if len(runs) == len(results) {
+				cancel()
+			}
+			return results[len(runs)-1]
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler is not stopped")
+	}
+	return runs
+}
+
+func TestSchedulerRun(t *testing.T) {
+	clock := &fakeClock{now: parseTime(t, "2024-01-01 10:00:00")}
+	schedule, err := ParseSchedule("0 4 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler := NewScheduler("test", schedule, SchedulerOptions{RetryInitial: time.Minute, RetryMax: time.Hour, RetryLimit: 2}, clock)
+	failed := errors.New("failed")
+
+	runs := runScheduler(t, scheduler, clock, parseTime(t, "2024-01-01 04:00:00"), failed, failed, failed, nil, nil)
+	want := []string{
+		"2024-01-02 04:00:00",
+		// Two retries with backoff
+		"2024-01-02 04:01:00",
+		"2024-01-02 04:03:00",
+		// Retry limit is reached, failure waits for planned run
+		"2024-01-03 04:00:00",
+		"2024-01-04 04:00:00",
+	}
+	if len(runs) != len(want) {
+		t.Fatalf("runs = %v, want %v", runs, want)
+	}
+	for i := range want {
+		if !runs[i].Equal(parseTime(t, want[i])) {
+			t.Errorf("run %d at %s, want %s", i, runs[i], want[i])
+		}
+	}
+}
+
+func TestSchedulerRunMissed(t *testing.T) {
+	now := parseTime(t, "2024-01-01 10:00:00")
+	clock := &fakeClock{now: now}
+	scheduler := newTestScheduler(SchedulerOptions{RetryInitial: time.Minute, RetryMax: time.Hour}, clock)
+
+	// Planned run is long gone, it is started at once
+	runs := runScheduler(t, scheduler, clock, now.Add(-24*time.Hour), nil, nil)
+	if len(runs) != 2 || !runs[0].Equal(now) || !runs[1].Equal(now.Add(time.Hour)) {
+		t.Fatalf("runs = %v, want %s and an hour later", runs, now)
+	}
+}
+
+func TestSchedulerRunPermanentFailure(t *testing.T) {
+	clock := &fakeClock{now: parseTime(t, "2024-01-01 10:00:00")}
+	schedule, err := ParseSchedule("0 4 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler := NewScheduler("test", schedule, SchedulerOptions{RetryInitial: time.Minute, RetryMax: time.Hour, RetryLimit: 2}, clock)
+	rejected := fmt.Errorf("update: %w: %w", ErrPermanent, ErrGuardrail)
+
+	runs := runScheduler(t, scheduler, clock, parseTime(t, "2024-01-01 04:00:00"), rejected, errors.New("failed"), nil)
+	want := []string{
+		"2024-01-02 04:00:00",
+		// Not retried
+		"2024-01-03 04:00:00",
+		// Other failure is retried
+		"2024-01-03 04:01:00",
+	}
+	if len(runs) != len(want) {
+		t.Fatalf("runs = %v, want %v", runs, want)
+	}
+	for i := range want {
+		if !runs[i].Equal(parseTime(t, want[i])) {
+			t.Errorf("run %d at %s, want %s", i, runs[i], want[i])
+		}
+	}
+}
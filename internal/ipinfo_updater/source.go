@@ -0,0 +1,329 @@
+package app
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+)
+
+type SourceType string
+
+const (
+	// Official RIR ftp server over https, or mirror with the same layout
+	RirSourceType SourceType = "rir"
+	// Local directory with delegated files or one delegated file
+	FileSourceType SourceType = "file"
+	// Any url built from template
+	UrlSourceType SourceType = "url"
+)
+
+const (
+	defaultRirBaseUrl = "https://ftp.{domain}.net/pub/stats"
+	rirPathTemplate   = "/{path}/delegated-{file}-latest"
+)
+
+type Source interface {
+	// Unique name of source from config
+	Name() string
+	// Registry which data is replaced by this source, with feed of source config
+	Rir() *common.Rir
+	// Where data is fetched from, for logs
+	Location() string
+
+	// Returns ErrNotModified when data is the same as described by state,
+	// otherwise replaces state with validators of fetched data
+	Fetch(ctx context.Context, state *FetchState) (io.ReadCloser, error)
+	// Serial number of data, reader is returned from the start
+	Serial(data io.Reader) (string, io.Reader, error)
+	Parse(data io.Reader) *DelegatedStream
+}
+
+var ErrNotModified = errors.New("not modified")
+
+// Validators of previously fetched data
+type FetchState struct {
+	ETag         string
+	LastModified string
+}
+
+type baseSource struct {
+	DelegatedParser
+
+	name   string
+	rir    *common.Rir
+	verify VerifyOptions
+}
+
+func (p *baseSource) Name() string {
+	return p.name
+}
+
+func (p *baseSource) Rir() *common.Rir {
+	return p.rir
+}
+
+// Placeholders: {rir} registry name, {domain}, {path} and {file} parts of official RIR url
+func NewSourceUrl(template string, rir *common.Rir) string {
+	return strings.NewReplacer(
+		"{rir}", rir.DbName,
+		"{domain}", rir.Domain,
+		"{path}", rir.PathName,
+		"{file}", rir.FileName,
+	).Replace(template)
+}
+
+type UrlSource struct {
+	baseSource
+
+	url    string
+	client *http.Client
+}
+
+func (p *UrlSource) Location() string {
+	return p.url
+}
+
+func (p *UrlSource) get(ctx context.Context, url string, state *FetchState) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		if state.ETag != "" {
+			request.Header.Set("If-None-Match", state.ETag)
+		}
+		if state.LastModified != "" {
+			request.Header.Set("If-Modified-Since", state.LastModified)
+		}
+	}
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if state != nil && response.StatusCode == http.StatusNotModified {
+		response.Body.Close()
+		return nil, ErrNotModified
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("bad status code of %s: %s", url, response.Status)
+	}
+
+	if state != nil {
+		state.ETag = response.Header.Get("ETag")
+		state.LastModified = response.Header.Get("Last-Modified")
+	}
+	return response.Body, nil
+}
+
+// With verification data is downloaded to temporary file first and returned only when checks pass
+func (p *UrlSource) Fetch(ctx context.Context, state *FetchState) (io.ReadCloser, error) {
+	body, err := p.get(ctx, p.url, state)
+	if err != nil {
+		return nil, err
+	}
+	if !p.verify.Enabled() {
+		return body, nil
+	}
+
+	data, digest, err := NewTempFile("ipinfo-"+p.name+"-*", body)
+	body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+
+	if p.verify.Checksum {
+		if err = p.verifyChecksum(ctx, digest); err != nil {
+			data.Close()
+			return nil, err
+		}
+	}
+	if p.verify.Keyring != "" {
+		if err = p.verifySignature(ctx, data.Name()); err != nil {
+			data.Close()
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func (p *UrlSource) verifyChecksum(ctx context.Context, digest []byte) error {
+	checksumFile, err := p.get(ctx, p.url+checksumSuffix, nil)
+	if err != nil {
+		return fmt.Errorf("%w: download checksum: %w", ErrVerification, err)
+	}
+	defer checksumFile.Close()
+
+	expected, err := ReadChecksumFile(checksumFile)
+	if err != nil {
+		return fmt.Errorf("%w: read checksum: %w", ErrVerification, err)
+	}
+	return VerifyMd5(expected, digest)
+}
+
+func (p *UrlSource) verifySignature(ctx context.Context, dataPath string) error {
+	signatureFile, err := p.get(ctx, p.url+signatureSuffix, nil)
+	if err != nil {
+		return fmt.Errorf("%w: download signature: %w", ErrVerification, err)
+	}
+	signature, _, err := NewTempFile("ipinfo-"+p.name+"-*"+signatureSuffix, signatureFile)
+	signatureFile.Close()
+	if err != nil {
+		return fmt.Errorf("%w: download signature: %w", ErrVerification, err)
+	}
+	defer signature.Close()
+
+	return VerifySignature(ctx, p.verify.Keyring, signature.Name(), dataPath)
+}
+
+type FileSource struct {
+	baseSource
+
+	// Directory to search registry file in, or registry file itself
+	path string
+}
+
+func (p *FileSource) Location() string {
+	return p.path
+}
+
+// Modification time of file is used as Last-Modified, there is no ETag. It is stored apart from validators of remote sources
+func (p *FileSource) Fetch(ctx context.Context, state *FetchState) (io.ReadCloser, error) {
+	path := p.path
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		if path, err = FindRegistryFile(p.path, p.rir); err != nil {
+			return nil, err
+		}
+		if info, err = os.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+	lastModified := info.ModTime().UTC().Format(http.TimeFormat)
+	if state.LastModified == lastModified {
+		return nil, ErrNotModified
+	}
+	state.ETag = ""
+	state.LastModified = lastModified
+
+	slog.Info("reading registry file", "source", p.name, "path", path)
+	if err = p.verifyFile(ctx, path); err != nil {
+		return nil, err
+	}
+	return OpenRegistryFile(path)
+}
+
+// Companion files are expected next to registry file and cover it as is, compressed or not
+func (p *FileSource) verifyFile(ctx context.Context, path string) error {
+	if p.verify.Checksum {
+		checksumFile, err := os.Open(path + checksumSuffix)
+		if err != nil {
+			return fmt.Errorf("%w: open checksum: %w", ErrVerification, err)
+		}
+		expected, err := ReadChecksumFile(checksumFile)
+		checksumFile.Close()
+		if err != nil {
+			return fmt.Errorf("%w: read checksum: %w", ErrVerification, err)
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		hash := md5.New()
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		if err = VerifyMd5(expected, hash.Sum(nil)); err != nil {
+			return err
+		}
+	}
+	if p.verify.Keyring != "" {
+		return VerifySignature(ctx, p.verify.Keyring, path+signatureSuffix, path)
+	}
+	return nil
+}
+
+func NewHttpClient() *http.Client {
+	return &http.Client{
+		Timeout: 600 * time.Second,
+	}
+}
+
+func NewSource(cfg *SourceConfig, registryFilePath string) (Source, error) {
+	rirId := common.FindRirByDbName(cfg.Rir)
+	if rirId == -1 {
+		return nil, fmt.Errorf("unknown rir of source %s: %s", cfg.Name, cfg.Rir)
+	}
+	// Copy, so feed of one source doesn't change others of the same rir
+	rir := *common.Rirs[rirId]
+	if cfg.Domain != "" {
+		rir.Domain = cfg.Domain
+	}
+	if cfg.Directory != "" {
+		rir.PathName = cfg.Directory
+	}
+	if cfg.File != "" {
+		rir.FileName = cfg.File
+	}
+	base := baseSource{
+		DelegatedParser: DelegatedParser{Registry: rir.DbName},
+		name:            cfg.Name,
+		rir:             &rir,
+		verify: VerifyOptions{
+			Checksum: cfg.Checksum,
+			Keyring:  cfg.Keyring,
+		},
+	}
+
+	switch cfg.Type {
+	case RirSourceType:
+		baseUrl := defaultRirBaseUrl
+		if cfg.Url != "" {
+			baseUrl = strings.TrimSuffix(cfg.Url, "/")
+		}
+		return &UrlSource{
+			baseSource: base,
+			url:        NewSourceUrl(baseUrl+rirPathTemplate, base.rir),
+			client:     NewHttpClient(),
+		}, nil
+	case UrlSourceType:
+		if cfg.Url == "" {
+			return nil, fmt.Errorf("url template is required for source %s", cfg.Name)
+		}
+		return &UrlSource{
+			baseSource: base,
+			url:        NewSourceUrl(cfg.Url, base.rir),
+			client:     NewHttpClient(),
+		}, nil
+	case FileSourceType:
+		path := cfg.Path
+		if path == "" {
+			path = registryFilePath
+		}
+		if path == "" {
+			return nil, fmt.Errorf("path is required for file source %s", cfg.Name)
+		}
+		return &FileSource{
+			baseSource: base,
+			path:       path,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown type of source %s: %s", cfg.Name, cfg.Type)
+	}
+}
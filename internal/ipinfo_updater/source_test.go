@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testRegistryData = "2|ripencc|20240101|0|19700101|20240101|+0000\n"
+
+func newTestUrlSource(t *testing.T, checksums map[string]string) Source {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/delegated":
+			io.WriteString(w, testRegistryData)
+		case "/delegated" + checksumSuffix:
+			if checksum, ok := checksums[r.URL.Path]; ok {
+				io.WriteString(w, checksum)
+				return
+			}
+			http.NotFound(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	source, err := NewSource(&SourceConfig{Name: "test", Type: UrlSourceType, Rir: "ripencc", Url: server.URL + "/delegated", Checksum: true}, "")
+	if err != nil {
+		t.Fatalf("NewSource: %v", err)
+	}
+	return source
+}
+
+func TestUrlSourceChecksum(t *testing.T) {
+	digest := fmt.Sprintf("%x", md5.Sum([]byte(testRegistryData)))
+	tests := []struct {
+		name     string
+		checksum map[string]string
+		rejected bool
+	}{
+		{"valid", map[string]string{"/delegated.md5": "MD5 (delegated) = " + digest}, false},
+		{"missing checksum", nil, true},
+		{"no digest in checksum", map[string]string{"/delegated.md5": "not found"}, true},
+		{"mismatch", map[string]string{"/delegated.md5": fmt.Sprintf("%x", md5.Sum(nil))}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := newTestUrlSource(t, test.checksum).Fetch(context.Background(), &FetchState{})
+			if test.rejected {
+				if !errors.Is(err, ErrVerification) {
+					t.Fatalf("Fetch error = %v, want %v", err, ErrVerification)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			defer data.Close()
+			if content, _ := io.ReadAll(data); string(content) != testRegistryData {
+				t.Fatalf("Fetch = %q, want %q", content, testRegistryData)
+			}
+		})
+	}
+}
+
+func TestFileSourceMissingChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "delegated-ripencc-extended-latest")
+	if err := os.WriteFile(path, []byte(testRegistryData), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewSource(&SourceConfig{Name: "test", Type: FileSourceType, Rir: "ripencc", Path: path, Checksum: true}, "")
+	if err != nil {
+		t.Fatalf("NewSource: %v", err)
+	}
+	if _, err = source.Fetch(context.Background(), &FetchState{}); !errors.Is(err, ErrVerification) {
+		t.Fatalf("Fetch error = %v, want %v", err, ErrVerification)
+	}
+}
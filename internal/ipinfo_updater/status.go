@@ -0,0 +1,42 @@
+package app
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+)
+
+type UpdateState string
+
+const (
+	PublishedUpdateState   UpdateState = "published"
+	UnchangedUpdateState   UpdateState = "unchanged"
+	QuarantinedUpdateState UpdateState = "quarantined"
+	FailedUpdateState      UpdateState = "failed"
+)
+
+// Result of the last update of rir, stored in options as json
+type UpdateStatus struct {
+	State          UpdateState            `json:"state"`
+	Source         string                 `json:"source"`
+	CheckedAt      time.Time              `json:"checkedAt"`
+	Serial         string                 `json:"serial,omitempty"`
+	Error          string                 `json:"error,omitempty"`
+	Report         *GuardReport           `json:"report,omitempty"`
+	Changes        *common.RirDataChanges `json:"changes,omitempty"`
+	QuarantinePath string                 `json:"quarantinePath,omitempty"`
+}
+
+func (p *UpdateStatus) String() string {
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+func ParseUpdateStatus(value string) (*UpdateStatus, error) {
+	status := &UpdateStatus{}
+	if err := json.Unmarshal([]byte(value), status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	checksumSuffix  = ".md5"
+	signatureSuffix = ".asc"
+
+	// Companion files are a single line, anything bigger is not a checksum
+	maxChecksumFileSize = 4096
+)
+
+// Data is not verified and never replaces stored data: missing or unreadable companion file, mismatch of digest or signature
+var ErrVerification = errors.New("verification failed")
+
+type VerifyOptions struct {
+	// Compare md5 digest with companion .md5 file
+	Checksum bool
+	// Verify companion .asc signature with gpgv against keyring, empty to skip
+	Keyring string
+}
+
+func (p *VerifyOptions) Enabled() bool {
+	return p.Checksum || p.Keyring != ""
+}
+
+// Formats in use by RIRs:
+// MD5 (delegated-ripencc-extended-latest) = <digest>
+// <digest>  delegated-arin-extended-latest
+func ParseMd5Checksum(data []byte) (string, error) {
+	for _, field := range strings.Fields(string(data)) {
+		if len(field) != hex.EncodedLen(md5.Size) {
+			continue
+		}
+		if _, err := hex.DecodeString(field); err == nil {
+			return strings.ToLower(field), nil
+		}
+	}
+	return "", fmt.Errorf("no md5 digest in checksum file")
+}
+
+func VerifyMd5(expected string, actual []byte) error {
+	if hex.EncodeToString(actual) != expected {
+		return fmt.Errorf("%w: md5 mismatch, expected %s, actual %x", ErrVerification, expected, actual)
+	}
+	return nil
+}
+
+func VerifySignature(ctx context.Context, keyring, signaturePath, dataPath string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "gpgv", "--keyring", keyring, signaturePath, dataPath)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: gpgv: %w: %s", ErrVerification, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func ReadChecksumFile(reader io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, maxChecksumFileSize))
+	if err != nil {
+		return "", err
+	}
+	return ParseMd5Checksum(data)
+}
+
+// Removes file on close, data is parsed only after verification so it has to be kept somewhere
+type tempFile struct {
+	*os.File
+}
+
+func (p *tempFile) Close() error {
+	return errors.Join(p.File.Close(), os.Remove(p.Name()))
+}
+
+// Copies reader to temporary file and returns md5 digest of copied bytes
+func NewTempFile(pattern string, reader io.Reader) (*tempFile, []byte, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	temp := &tempFile{File: file}
+
+	hash := md5.New()
+	if _, err = io.Copy(io.MultiWriter(file, hash), reader); err != nil {
+		temp.Close()
+		return nil, nil, err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		temp.Close()
+		return nil, nil, err
+	}
+	return temp, hash.Sum(nil), nil
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/dao"
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+type AsnService interface {
+	GetAsn(asn uint32) (*entity.AsnInfo, error)
+	GetAsnAt(asn uint32, at time.Time) (*entity.AsnInfo, error)
+}
+
+type Asn struct {
+	Repository dao.AsnRepository
+}
+
+func (p *Asn) GetAsn(asn uint32) (*entity.AsnInfo, error) {
+	return p.Repository.GetAsn(asn)
+}
+
+func (p *Asn) GetAsnAt(asn uint32, at time.Time) (*entity.AsnInfo, error) {
+	return p.Repository.GetAsnAt(asn, at)
+}
+
+func NewAsn(repository dao.AsnRepository) *Asn {
+	return &Asn{
+		Repository: repository,
+	}
+}
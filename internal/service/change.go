@@ -0,0 +1,24 @@
+package service
+
+import (
+	"github.com/KeilWin/ipinfo/internal/dao"
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+type ChangeService interface {
+	GetChanges(query *entity.ChangeQuery) ([]*entity.RangeChange, error)
+}
+
+type Change struct {
+	Repository dao.ChangeRepository
+}
+
+func (p *Change) GetChanges(query *entity.ChangeQuery) ([]*entity.RangeChange, error) {
+	return p.Repository.GetChanges(query)
+}
+
+func NewChange(repository dao.ChangeRepository) *Change {
+	return &Change{
+		Repository: repository,
+	}
+}
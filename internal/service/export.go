@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/KeilWin/ipinfo/internal/dao"
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/export"
+)
+
+// Rendered lists of one data version kept in memory, queries past the limit are rendered every time
+const exportCacheSize = 64
+
+type ExportService interface {
+	GetDataVersion(ctx context.Context) (string, error)
+	GetList(ctx context.Context, query *entity.ExportQuery) (*entity.ExportList, error)
+}
+
+type Export struct {
+	Repository dao.ExportRepository
+
+	mu      sync.Mutex
+	version string
+	lists   map[string]*entity.ExportList
+}
+
+func (p *Export) GetDataVersion(ctx context.Context) (string, error) {
+	return p.Repository.GetDataVersion(ctx)
+}
+
+// List is rendered once per data version and query
+func (p *Export) GetList(ctx context.Context, query *entity.ExportQuery) (*entity.ExportList, error) {
+	key := newExportQueryKey(query)
+	version, err := p.Repository.GetDataVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	list, ok := p.lists[key]
+	if p.version != version {
+		ok = false
+	}
+	p.mu.Unlock()
+	if ok {
+		return list, nil
+	}
+
+	version, ranges, err := p.Repository.GetIpRanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body := &bytes.Buffer{}
+	if err = export.WriteList(body, ranges, query); err != nil {
+		return nil, err
+	}
+	list = &entity.ExportList{Version: version, Body: body.Bytes()}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.version != version {
+		p.version, p.lists = version, make(map[string]*entity.ExportList)
+	}
+	if len(p.lists) < exportCacheSize {
+		p.lists[key] = list
+	}
+	return list, nil
+}
+
+// Order of countries and statuses doesn't change list
+func newExportQueryKey(query *entity.ExportQuery) string {
+	countryCodes := slices.Sorted(slices.Values(query.CountryCodes))
+	statuses := slices.Sorted(slices.Values(query.Statuses))
+	return strings.Join([]string{query.Format, strings.Join(countryCodes, ","), query.Family, query.Name, strings.Join(statuses, ",")}, "|")
+}
+
+func NewExport(repository dao.ExportRepository) *Export {
+	return &Export{
+		Repository: repository,
+		lists:      make(map[string]*entity.ExportList),
+	}
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"github.com/KeilWin/ipinfo/internal/dao"
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+type HolderService interface {
+	GetHolder(opaqueId string) (*entity.HolderInfo, error)
+}
+
+type Holder struct {
+	Repository dao.HolderRepository
+}
+
+func (p *Holder) GetHolder(opaqueId string) (*entity.HolderInfo, error) {
+	return p.Repository.GetHolder(opaqueId)
+}
+
+func NewHolder(repository dao.HolderRepository) *Holder {
+	return &Holder{
+		Repository: repository,
+	}
+}
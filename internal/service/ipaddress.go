@@ -1,12 +1,20 @@
 package service
 
 import (
+	"fmt"
+	"net/netip"
+	"time"
+
 	"github.com/KeilWin/ipinfo/internal/dao"
 	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/utils"
 )
 
 type IpAddressService interface {
 	GetIpAddress(ipAddress string) (*entity.IpAddressInfo, error)
+	GetIpAddressAt(ipAddress string, at time.Time) (*entity.IpAddressInfo, error)
+	GetIpAddresses(ipAddresses []string) ([]*entity.IpAddressInfo, error)
+	GetIpRanges(query *entity.PrefixQuery) ([]*entity.IpRangeInfo, error)
 }
 
 type IpAddress struct {
@@ -17,6 +25,54 @@ func (p *IpAddress) GetIpAddress(ipAddress string) (*entity.IpAddressInfo, error
 	return p.Repository.GetIpAddress(ipAddress)
 }
 
+// History lookups are rare and cached data is always current, so they go straight to repository
+func (p *IpAddress) GetIpAddressAt(ipAddress string, at time.Time) (*entity.IpAddressInfo, error) {
+	return p.Repository.GetIpAddressAt(ipAddress, at)
+}
+
+// Batch lookups go straight to repository, caching them would evict hot single lookups
+func (p *IpAddress) GetIpAddresses(ipAddresses []string) ([]*entity.IpAddressInfo, error) {
+	return p.Repository.GetIpAddresses(ipAddresses)
+}
+
+func (p *IpAddress) GetIpRanges(query *entity.PrefixQuery) ([]*entity.IpRangeInfo, error) {
+	query.Prefix = query.Prefix.Masked()
+	ipRangeInfos, err := p.Repository.GetIpRanges(query)
+	if err != nil {
+		return nil, err
+	}
+	first, last := query.Prefix.Addr(), utils.LastAddr(query.Prefix)
+	for _, ipRangeInfo := range ipRangeInfos {
+		ipRangeInfo.Relation, err = newIpRangeRelation(ipRangeInfo, first, last)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ipRangeInfos, nil
+}
+
+func newIpRangeRelation(ipRangeInfo *entity.IpRangeInfo, first, last netip.Addr) (entity.IpRangeRelation, error) {
+	start, err := netip.ParseAddr(ipRangeInfo.IpRangeStart)
+	if err != nil {
+		return "", fmt.Errorf("parse range start: %w", err)
+	}
+	end, err := netip.ParseAddr(ipRangeInfo.IpRangeEnd)
+	if err != nil {
+		return "", fmt.Errorf("parse range end: %w", err)
+	}
+	// Range end is exclusive
+	end = end.Prev()
+
+	switch {
+	case start.Compare(first) <= 0 && end.Compare(last) >= 0:
+		return entity.IpRangeCovers, nil
+	case start.Compare(first) >= 0 && end.Compare(last) <= 0:
+		return entity.IpRangeContained, nil
+	default:
+		return entity.IpRangePartial, nil
+	}
+}
+
 func NewIpAddress(repository dao.IpAddressRepository) *IpAddress {
 	return &IpAddress{
 		Repository: repository,
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+
+	"github.com/KeilWin/ipinfo/internal/dao"
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/webhook"
+)
+
+type WebhookService interface {
+	CreateSubscription(subscription *entity.WebhookSubscription) (*entity.WebhookSubscription, error)
+	GetSubscription(id int64) (*entity.WebhookSubscription, error)
+	GetSubscriptions(ctx context.Context) ([]*entity.WebhookSubscription, error)
+	DeleteSubscription(id int64) (bool, error)
+	Ping(id int64, ctx context.Context) (bool, error)
+	GetDeadLetters(subscriptionId int64) ([]*entity.WebhookDeadLetter, error)
+	RetryDeadLetter(subscriptionId, id int64) (bool, error)
+}
+
+// Secret is generated when not given and is returned only on create
+type Webhook struct {
+	Repository dao.WebhookRepository
+}
+
+func (p *Webhook) CreateSubscription(subscription *entity.WebhookSubscription) (*entity.WebhookSubscription, error) {
+	if subscription.Secret == "" {
+		var err error
+		if subscription.Secret, err = webhook.NewSecret(); err != nil {
+			return nil, err
+		}
+	}
+	return p.Repository.CreateSubscription(subscription)
+}
+
+func (p *Webhook) GetSubscription(id int64) (*entity.WebhookSubscription, error) {
+	subscription, err := p.Repository.GetSubscription(id)
+	if err != nil || subscription == nil {
+		return nil, err
+	}
+	subscription.Secret = ""
+	return subscription, nil
+}
+
+func (p *Webhook) GetSubscriptions(ctx context.Context) ([]*entity.WebhookSubscription, error) {
+	subscriptions, err := p.Repository.GetSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, subscription := range subscriptions {
+		subscription.Secret = ""
+	}
+	return subscriptions, nil
+}
+
+func (p *Webhook) DeleteSubscription(id int64) (bool, error) {
+	return p.Repository.DeleteSubscription(id)
+}
+
+// Ping is sent by updater with other deliveries, false when subscription doesn't exist
+func (p *Webhook) Ping(id int64, ctx context.Context) (bool, error) {
+	subscription, err := p.Repository.GetSubscription(id)
+	if err != nil || subscription == nil {
+		return false, err
+	}
+	event, err := webhook.NewPingEvent(id)
+	if err != nil {
+		return false, err
+	}
+	delivery, err := event.NewDelivery(id)
+	if err != nil {
+		return false, err
+	}
+	if err = p.Repository.Enqueue([]*entity.WebhookDelivery{delivery}, ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Nil when subscription doesn't exist
+func (p *Webhook) GetDeadLetters(subscriptionId int64) ([]*entity.WebhookDeadLetter, error) {
+	subscription, err := p.Repository.GetSubscription(subscriptionId)
+	if err != nil || subscription == nil {
+		return nil, err
+	}
+	return p.Repository.GetDeadLetters(subscriptionId)
+}
+
+func (p *Webhook) RetryDeadLetter(subscriptionId, id int64) (bool, error) {
+	return p.Repository.RetryDeadLetter(subscriptionId, id)
+}
+
+func NewWebhook(repository dao.WebhookRepository) *Webhook {
+	return &Webhook{
+		Repository: repository,
+	}
+}
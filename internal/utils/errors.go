@@ -10,6 +10,10 @@ type ExitCodeType int
 const (
 	ExitSuccess ExitCodeType = 0
 	ExitError   ExitCodeType = 1
+	// Bad command line arguments
+	ExitUsage ExitCodeType = 2
+	// Data is rejected by checks, stored data is kept
+	ExitRejected ExitCodeType = 3
 )
 
 func CheckLoadConfigError(err error, name string, component string) bool {
@@ -0,0 +1,13 @@
+package utils
+
+import "net/netip"
+
+func LastAddr(prefix netip.Prefix) netip.Addr {
+	prefix = prefix.Masked()
+	buf := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(buf)*8; i++ {
+		buf[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(buf)
+	return addr
+}
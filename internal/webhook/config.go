@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+	"github.com/KeilWin/ipinfo/internal/utils"
+)
+
+const componentName = "WEBHOOK"
+
+// Every setting is optional, empty value is replaced by default
+type WebhookConfig struct {
+	common.Config
+
+	BasePrefix string
+
+	// Pause between checks of delivery queue
+	Interval time.Duration
+	Timeout  time.Duration
+	// Attempts before delivery is moved to dead letters
+	MaxAttempts int
+	// Pause after first failed attempt, doubled after every next failure
+	RetryInitial time.Duration
+	RetryMax     time.Duration
+	// Deliveries sent concurrently
+	BatchSize int
+	// Changes of one snapshot are split into events of this size
+	ChangesPerEvent int
+}
+
+func (p *WebhookConfig) NewVariableName(name string) string {
+	return fmt.Sprintf("%s_%s", p.BasePrefix, name)
+}
+
+func (p *WebhookConfig) Load() error {
+	var hasError bool
+
+	durations := []struct {
+		name         string
+		value        *time.Duration
+		defaultValue time.Duration
+	}{
+		{"INTERVAL", &p.Interval, 10 * time.Second},
+		{"TIMEOUT", &p.Timeout, 10 * time.Second},
+		{"RETRY_INITIAL", &p.RetryInitial, 30 * time.Second},
+		{"RETRY_MAX", &p.RetryMax, time.Hour},
+	}
+	for _, duration := range durations {
+		name := p.NewVariableName(duration.name)
+		seconds, err := parseOptionalInt(os.Getenv(name), int(duration.defaultValue/time.Second))
+		hasError = CheckLoadWebhookConfigError(err, name) || hasError
+		*duration.value = time.Duration(seconds) * time.Second
+	}
+
+	ints := []struct {
+		name         string
+		value        *int
+		defaultValue int
+	}{
+		{"MAX_ATTEMPTS", &p.MaxAttempts, 8},
+		{"BATCH_SIZE", &p.BatchSize, 10},
+		{"CHANGES_PER_EVENT", &p.ChangesPerEvent, 500},
+	}
+	for _, value := range ints {
+		name := p.NewVariableName(value.name)
+		var err error
+		*value.value, err = parseOptionalInt(os.Getenv(name), value.defaultValue)
+		hasError = CheckLoadWebhookConfigError(err, name) || hasError
+	}
+
+	if hasError {
+		return errors.New("loading webhook config")
+	}
+	return nil
+}
+
+func (p *WebhookConfig) Check() error {
+	if p.Interval <= 0 || p.Timeout <= 0 || p.RetryInitial <= 0 || p.RetryMax < p.RetryInitial {
+		return fmt.Errorf("webhook interval, timeout and retries must be positive, retry max not less than initial")
+	}
+	if p.MaxAttempts < 1 || p.BatchSize < 1 || p.ChangesPerEvent < 1 {
+		return errors.New("webhook max attempts, batch size and changes per event must be positive")
+	}
+	return nil
+}
+
+func parseOptionalInt(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func NewWebhookConfig(appPrefix string) *WebhookConfig {
+	return &WebhookConfig{
+		BasePrefix: common.NewBasePrefix(appPrefix, componentName),
+	}
+}
+
+func CheckLoadWebhookConfigError(err error, name string) bool {
+	return utils.CheckLoadConfigError(err, name, componentName)
+}
@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+)
+
+// Delivery queue, implemented by database
+type Queue interface {
+	ClaimWebhookDeliveries(limit int, lease time.Duration, ctx context.Context) ([]*database.WebhookDeliveryRow, error)
+	CompleteWebhookDelivery(id int64, ctx context.Context) error
+	RetryWebhookDelivery(id int64, nextAttemptAt time.Time, lastError string, ctx context.Context) error
+	DeadLetterWebhookDelivery(id int64, lastError string, ctx context.Context) error
+}
+
+// Sends queued deliveries, failed ones are retried with exponential backoff
+// and moved to dead letters after last attempt
+type Dispatcher struct {
+	queue  Queue
+	client *http.Client
+	config *WebhookConfig
+}
+
+func (p *Dispatcher) backoff(attempts int) time.Duration {
+	delay := p.config.RetryInitial
+	for range attempts - 1 {
+		if delay >= p.config.RetryMax {
+			break
+		}
+		delay *= 2
+	}
+	return min(delay, p.config.RetryMax)
+}
+
+// Sends due deliveries until there are none
+func (p *Dispatcher) Deliver(ctx context.Context) error {
+	// Lease outlives the slowest request of batch
+	lease := 2 * p.config.Timeout
+	for ctx.Err() == nil {
+		deliveries, err := p.queue.ClaimWebhookDeliveries(p.config.BatchSize, lease, ctx)
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(len(deliveries))
+		for _, delivery := range deliveries {
+			go func() {
+				defer wg.Done()
+				p.deliver(ctx, delivery)
+			}()
+		}
+		wg.Wait()
+	}
+	return ctx.Err()
+}
+
+func (p *Dispatcher) deliver(ctx context.Context, delivery *database.WebhookDeliveryRow) {
+	err := p.send(ctx, delivery)
+	// Claimed delivery is sent again after lease
+	if ctx.Err() != nil {
+		return
+	}
+	if err == nil {
+		slog.Info("webhook delivered", "delivery", delivery.Id, "event", delivery.EventType, "subscription", delivery.SubscriptionId)
+		if err = p.queue.CompleteWebhookDelivery(delivery.Id, ctx); err != nil {
+			slog.Error("complete webhook delivery", "delivery", delivery.Id, "error", err)
+		}
+		return
+	}
+
+	if delivery.Attempts >= p.config.MaxAttempts {
+		slog.Error("webhook dead lettered", "delivery", delivery.Id, "event", delivery.EventType, "subscription", delivery.SubscriptionId, "attempts", delivery.Attempts, "error", err)
+		if err = p.queue.DeadLetterWebhookDelivery(delivery.Id, err.Error(), ctx); err != nil {
+			slog.Error("dead letter webhook delivery", "delivery", delivery.Id, "error", err)
+		}
+		return
+	}
+	nextAttemptAt := time.Now().Add(p.backoff(delivery.Attempts))
+	slog.Warn("webhook failed", "delivery", delivery.Id, "event", delivery.EventType, "subscription", delivery.SubscriptionId, "attempts", delivery.Attempts, "next", nextAttemptAt, "error", err)
+	if err = p.queue.RetryWebhookDelivery(delivery.Id, nextAttemptAt, err.Error(), ctx); err != nil {
+		slog.Error("retry webhook delivery", "delivery", delivery.Id, "error", err)
+	}
+}
+
+// Any 2xx status is success
+func (p *Dispatcher) send(ctx context.Context, delivery *database.WebhookDeliveryRow) error {
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "ipinfo-webhook")
+	request.Header.Set(EventHeader, delivery.EventType)
+	request.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.Id, 10))
+	request.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), []byte(delivery.Payload)))
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", response.Status)
+	}
+	return nil
+}
+
+// Checks queue every interval until context is done
+func (p *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := p.Deliver(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("deliver webhooks", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func NewDispatcher(queue Queue, config *WebhookConfig) *Dispatcher {
+	return &Dispatcher{
+		queue: queue,
+		// Redirects are not followed, target is the registered url only
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		config: config,
+	}
+}
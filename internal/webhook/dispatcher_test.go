@@ -0,0 +1,246 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/dto/database"
+)
+
+// In memory queue, claim hands out due deliveries and counts attempt as database does
+type fakeQueue struct {
+	mu          sync.Mutex
+	now         time.Time
+	deliveries  []*database.WebhookDeliveryRow
+	nextAttempt map[int64]time.Time
+	completed   []int64
+	retries     []fakeRetry
+	deadLetters []int64
+}
+
+type fakeRetry struct {
+	id            int64
+	nextAttemptAt time.Time
+	lastError     string
+}
+
+func newFakeQueue(deliveries ...*database.WebhookDeliveryRow) *fakeQueue {
+	return &fakeQueue{
+		now:         time.Now(),
+		deliveries:  deliveries,
+		nextAttempt: make(map[int64]time.Time),
+	}
+}
+
+func (p *fakeQueue) ClaimWebhookDeliveries(limit int, lease time.Duration, ctx context.Context) ([]*database.WebhookDeliveryRow, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var claimed []*database.WebhookDeliveryRow
+	for _, delivery := range p.deliveries {
+		if len(claimed) == limit {
+			break
+		}
+		if p.nextAttempt[delivery.Id].After(p.now) {
+			continue
+		}
+		delivery.Attempts++
+		p.nextAttempt[delivery.Id] = p.now.Add(lease)
+		row := *delivery
+		claimed = append(claimed, &row)
+	}
+	return claimed, nil
+}
+
+func (p *fakeQueue) remove(id int64) {
+	for i, delivery := range p.deliveries {
+		if delivery.Id == id {
+			p.deliveries = append(p.deliveries[:i], p.deliveries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *fakeQueue) CompleteWebhookDelivery(id int64, ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completed = append(p.completed, id)
+	p.remove(id)
+	return nil
+}
+
+func (p *fakeQueue) RetryWebhookDelivery(id int64, nextAttemptAt time.Time, lastError string, ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retries = append(p.retries, fakeRetry{id, nextAttemptAt, lastError})
+	p.nextAttempt[id] = nextAttemptAt
+	return nil
+}
+
+func (p *fakeQueue) DeadLetterWebhookDelivery(id int64, lastError string, ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deadLetters = append(p.deadLetters, id)
+	p.remove(id)
+	return nil
+}
+
+// Moves queue clock to the next retry, so it is claimed by the next Deliver
+func (p *fakeQueue) advance() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.retries) != 0 {
+		p.now = p.retries[len(p.retries)-1].nextAttemptAt
+	}
+}
+
+const testSecret = "0123456789abcdef"
+
+// Receiver checks every request and answers with statuses in turn, the last one is repeated
+func newReceiver(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		if err = VerifySignature(testSecret, r.Header.Get(SignatureHeader), body, time.Now(), time.Minute); err != nil {
+			t.Errorf("VerifySignature = %v, want nil", err)
+		}
+		if event := r.Header.Get(EventHeader); event != string(PingEvent) {
+			t.Errorf("%s = %q, want %q", EventHeader, event, PingEvent)
+		}
+		if delivery := r.Header.Get(DeliveryHeader); delivery != "1" {
+			t.Errorf("%s = %q, want 1", DeliveryHeader, delivery)
+		}
+
+		count := int(requests.Add(1))
+		w.WriteHeader(statuses[min(count, len(statuses))-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newTestDelivery(url string) *database.WebhookDeliveryRow {
+	return &database.WebhookDeliveryRow{
+		Id:             1,
+		SubscriptionId: 1,
+		EventId:        "event",
+		EventType:      string(PingEvent),
+		Payload:        `{"id":"event","type":"ping"}`,
+		Url:            url,
+		Secret:         testSecret,
+	}
+}
+
+func newTestDispatcher(queue Queue, maxAttempts int) *Dispatcher {
+	return NewDispatcher(queue, &WebhookConfig{
+		Timeout:      5 * time.Second,
+		MaxAttempts:  maxAttempts,
+		RetryInitial: time.Minute,
+		RetryMax:     time.Hour,
+		BatchSize:    10,
+	})
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	dispatcher := NewDispatcher(nil, &WebhookConfig{RetryInitial: 30 * time.Second, RetryMax: 5 * time.Minute})
+	for attempts, want := range map[int]time.Duration{
+		1:   30 * time.Second,
+		2:   time.Minute,
+		3:   2 * time.Minute,
+		4:   4 * time.Minute,
+		5:   5 * time.Minute,
+		100: 5 * time.Minute,
+	} {
+		if got := dispatcher.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestDispatcherRetry(t *testing.T) {
+	server, requests := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent)
+	queue := newFakeQueue(newTestDelivery(server.URL))
+	dispatcher := newTestDispatcher(queue, 5)
+	ctx := context.Background()
+
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute} {
+		sentAt := time.Now()
+		if err := dispatcher.Deliver(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if len(queue.retries) != i+1 {
+			t.Fatalf("retries = %d, want %d", len(queue.retries), i+1)
+		}
+		// Failed delivery is not claimed again before backoff
+		if int(requests.Load()) != i+1 {
+			t.Fatalf("requests = %d, want %d", requests.Load(), i+1)
+		}
+		retry := queue.retries[i]
+		if delay := retry.nextAttemptAt.Sub(sentAt); delay < want || delay > want+time.Second {
+			t.Errorf("retry %d after %s, want %s", i+1, delay, want)
+		}
+		if !strings.Contains(retry.lastError, "50") {
+			t.Errorf("last error = %q, want status", retry.lastError)
+		}
+		queue.advance()
+	}
+
+	if err := dispatcher.Deliver(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 3 || len(queue.completed) != 1 || len(queue.deadLetters) != 0 {
+		t.Fatalf("requests = %d, completed = %v, dead letters = %v, want 3, [1], []", requests.Load(), queue.completed, queue.deadLetters)
+	}
+}
+
+func TestDispatcherDeadLetter(t *testing.T) {
+	server, requests := newReceiver(t, http.StatusServiceUnavailable)
+	queue := newFakeQueue(newTestDelivery(server.URL))
+	dispatcher := newTestDispatcher(queue, 3)
+	ctx := context.Background()
+
+	for range 3 {
+		if err := dispatcher.Deliver(ctx); err != nil {
+			t.Fatal(err)
+		}
+		queue.advance()
+	}
+	if requests.Load() != 3 || len(queue.retries) != 2 || len(queue.completed) != 0 {
+		t.Fatalf("requests = %d, retries = %d, completed = %v, want 3, 2, []", requests.Load(), len(queue.retries), queue.completed)
+	}
+	if len(queue.deadLetters) != 1 || queue.deadLetters[0] != 1 {
+		t.Fatalf("dead letters = %v, want [1]", queue.deadLetters)
+	}
+
+	// Nothing is left to send
+	if err := dispatcher.Deliver(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 3 {
+		t.Fatalf("requests after dead letter = %d, want 3", requests.Load())
+	}
+}
+
+func TestDispatcherRedirectFails(t *testing.T) {
+	target, requests := newReceiver(t, http.StatusNoContent)
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	t.Cleanup(server.Close)
+	queue := newFakeQueue(newTestDelivery(server.URL))
+
+	if err := newTestDispatcher(queue, 5).Deliver(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 0 || len(queue.retries) != 1 || !strings.Contains(queue.retries[0].lastError, strconv.Itoa(http.StatusTemporaryRedirect)) {
+		t.Fatalf("requests = %d, retries = %v, want redirect not followed", requests.Load(), queue.retries)
+	}
+}
@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+type EventType string
+
+const (
+	// Rir data is published
+	ImportCompletedEvent EventType = "import.completed"
+	// Ranges matching filters of subscription are added, removed or changed in country or status
+	RangesChangedEvent EventType = "ranges.changed"
+	// Sent on request of admin api to check receiver, regardless of filters
+	PingEvent EventType = "ping"
+)
+
+// Events subscription can be filtered by, all of them when subscription has no events
+var EventTypes = []EventType{ImportCompletedEvent, RangesChangedEvent}
+
+type Event struct {
+	Id        string    `json:"id"`
+	Type      EventType `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+type ImportCompletedData struct {
+	RirName string                 `json:"rirName"`
+	Serial  string                 `json:"serial,omitempty"`
+	Changes *common.RirDataChanges `json:"changes"`
+}
+
+// Changes of one snapshot can be split into several events
+type RangesChangedData struct {
+	RirName    string                `json:"rirName"`
+	Serial     string                `json:"serial,omitempty"`
+	SnapshotId int64                 `json:"snapshotId"`
+	Changes    []*entity.RangeChange `json:"changes"`
+}
+
+type PingData struct {
+	SubscriptionId int64 `json:"subscriptionId"`
+}
+
+func (p *Event) NewDelivery(subscriptionId int64) (*entity.WebhookDelivery, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.WebhookDelivery{
+		SubscriptionId: subscriptionId,
+		EventId:        p.Id,
+		EventType:      string(p.Type),
+		Payload:        string(payload),
+	}, nil
+}
+
+func NewEvent(eventType EventType, data any) (*Event, error) {
+	id, err := newRandomHex(16)
+	if err != nil {
+		return nil, fmt.Errorf("event id: %w", err)
+	}
+	return &Event{
+		Id:        id,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}, nil
+}
+
+func NewImportCompletedEvent(rirName, serial string, changes *common.RirDataChanges) (*Event, error) {
+	return NewEvent(ImportCompletedEvent, &ImportCompletedData{
+		RirName: rirName,
+		Serial:  serial,
+		Changes: changes,
+	})
+}
+
+func NewRangesChangedEvent(rirName, serial string, snapshotId int64, changes []*entity.RangeChange) (*Event, error) {
+	return NewEvent(RangesChangedEvent, &RangesChangedData{
+		RirName:    rirName,
+		Serial:     serial,
+		SnapshotId: snapshotId,
+		Changes:    changes,
+	})
+}
+
+func NewPingEvent(subscriptionId int64) (*Event, error) {
+	return NewEvent(PingEvent, &PingData{
+		SubscriptionId: subscriptionId,
+	})
+}
+
+// Key of HMAC signatures of subscription
+func NewSecret() (string, error) {
+	secret, err := newRandomHex(32)
+	if err != nil {
+		return "", fmt.Errorf("secret: %w", err)
+	}
+	return secret, nil
+}
+
+// Predictable ids and secrets are worse than none, so failed read of random source is an error
+func newRandomHex(size int) (string, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
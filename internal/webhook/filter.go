@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"net/netip"
+	"slices"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+	"github.com/KeilWin/ipinfo/internal/utils"
+)
+
+// Filters of subscription, every non empty filter has to match
+type Filter struct {
+	Subscription *entity.WebhookSubscription
+	prefixes     []netip.Prefix
+}
+
+func (p *Filter) MatchesEvent(eventType EventType) bool {
+	return len(p.Subscription.Events) == 0 || slices.Contains(p.Subscription.Events, string(eventType))
+}
+
+func (p *Filter) MatchesRir(rirName string) bool {
+	return len(p.Subscription.Rirs) == 0 || slices.Contains(p.Subscription.Rirs, rirName)
+}
+
+// Country matches when range had or got it, asn ranges never match prefixes
+func (p *Filter) MatchesChange(change *entity.RangeChange) bool {
+	if !p.MatchesRir(change.RirName) {
+		return false
+	}
+	countryCodes := p.Subscription.CountryCodes
+	if len(countryCodes) != 0 && !slices.Contains(countryCodes, change.OldCountryCode) && !slices.Contains(countryCodes, change.NewCountryCode) {
+		return false
+	}
+	if len(p.Subscription.Prefixes) == 0 {
+		return true
+	}
+	if change.RangeType != "ip" {
+		return false
+	}
+	start, err := netip.ParseAddr(change.RangeStart)
+	if err != nil {
+		return false
+	}
+	end, err := netip.ParseAddr(change.RangeEnd)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(p.prefixes, func(prefix netip.Prefix) bool {
+		return overlaps(prefix, start, end)
+	})
+}
+
+// Range end is exclusive as in storage
+func overlaps(prefix netip.Prefix, start, end netip.Addr) bool {
+	if prefix.Addr().BitLen() != start.BitLen() {
+		return false
+	}
+	first := prefix.Masked().Addr()
+	return start.Compare(utils.LastAddr(prefix)) <= 0 && end.Compare(first) > 0
+}
+
+// Prefixes are validated on create, invalid ones are skipped
+func NewFilter(subscription *entity.WebhookSubscription) *Filter {
+	prefixes := make([]netip.Prefix, 0, len(subscription.Prefixes))
+	for _, value := range subscription.Prefixes {
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		}
+	}
+	return &Filter{
+		Subscription: subscription,
+		prefixes:     prefixes,
+	}
+}
@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+func TestFilterMatchesEvent(t *testing.T) {
+	all := NewFilter(&entity.WebhookSubscription{})
+	if !all.MatchesEvent(ImportCompletedEvent) || !all.MatchesEvent(RangesChangedEvent) {
+		t.Fatal("MatchesEvent without filter = false, want true")
+	}
+	changes := NewFilter(&entity.WebhookSubscription{Events: []string{string(RangesChangedEvent)}})
+	if changes.MatchesEvent(ImportCompletedEvent) || !changes.MatchesEvent(RangesChangedEvent) {
+		t.Fatal("MatchesEvent matches other event types")
+	}
+}
+
+func TestFilterMatchesChange(t *testing.T) {
+	ipChange := func(rirName, start, end, oldCountry, newCountry string) *entity.RangeChange {
+		return &entity.RangeChange{
+			RirName:        rirName,
+			RangeType:      "ip",
+			RangeStart:     start,
+			RangeEnd:       end,
+			OldCountryCode: oldCountry,
+			NewCountryCode: newCountry,
+		}
+	}
+	asnChange := &entity.RangeChange{RirName: "ripencc", RangeType: "asn", RangeStart: "100", RangeEnd: "101", NewCountryCode: "DE"}
+
+	tests := []struct {
+		name         string
+		subscription entity.WebhookSubscription
+		change       *entity.RangeChange
+		want         bool
+	}{
+		{"no filters", entity.WebhookSubscription{}, asnChange, true},
+		{"rir", entity.WebhookSubscription{Rirs: []string{"ripencc"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "", "DE"), true},
+		{"other rir", entity.WebhookSubscription{Rirs: []string{"arin"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "", "DE"), false},
+		{"new country", entity.WebhookSubscription{CountryCodes: []string{"DE"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "FR", "DE"), true},
+		{"old country", entity.WebhookSubscription{CountryCodes: []string{"FR"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "FR", "DE"), true},
+		{"other country", entity.WebhookSubscription{CountryCodes: []string{"NL"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "FR", "DE"), false},
+		{"prefix contains range", entity.WebhookSubscription{Prefixes: []string{"1.0.0.0/8"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "", "DE"), true},
+		{"range contains prefix", entity.WebhookSubscription{Prefixes: []string{"1.0.0.128/25"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "", "DE"), true},
+		// Range end is exclusive, 1.0.1.0 is not in range
+		{"prefix at range end", entity.WebhookSubscription{Prefixes: []string{"1.0.1.0/24"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "", "DE"), false},
+		{"prefix before range", entity.WebhookSubscription{Prefixes: []string{"0.255.255.0/24"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "", "DE"), false},
+		{"one of prefixes", entity.WebhookSubscription{Prefixes: []string{"2.0.0.0/8", "1.0.0.0/16"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "", "DE"), true},
+		{"ipv6 prefix", entity.WebhookSubscription{Prefixes: []string{"2001:db8::/32"}}, ipChange("ripencc", "2001:db8:1::", "2001:db8:2::", "", "DE"), true},
+		{"family mismatch", entity.WebhookSubscription{Prefixes: []string{"::/0"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "", "DE"), false},
+		{"asn with prefixes", entity.WebhookSubscription{Prefixes: []string{"0.0.0.0/0"}}, asnChange, false},
+		{"all filters", entity.WebhookSubscription{Rirs: []string{"ripencc"}, CountryCodes: []string{"DE"}, Prefixes: []string{"1.0.0.0/24"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "", "DE"), true},
+		{"one filter fails", entity.WebhookSubscription{Rirs: []string{"ripencc"}, CountryCodes: []string{"NL"}, Prefixes: []string{"1.0.0.0/24"}}, ipChange("ripencc", "1.0.0.0", "1.0.1.0", "", "DE"), false},
+	}
+	for _, test := range tests {
+		if got := NewFilter(&test.subscription).MatchesChange(test.change); got != test.want {
+			t.Errorf("%s: MatchesChange = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/KeilWin/ipinfo/internal/common"
+	"github.com/KeilWin/ipinfo/internal/dao"
+	"github.com/KeilWin/ipinfo/internal/entity"
+)
+
+const changesPageSize = 10000
+
+// Enqueues events of published rir data for matching subscriptions, they are sent by Dispatcher
+type Notifier struct {
+	repository      dao.WebhookRepository
+	changes         dao.ChangeRepository
+	changesPerEvent int
+}
+
+func (p *Notifier) Published(ctx context.Context, rirName, serial string, changes *common.RirDataChanges) error {
+	subscriptions, err := p.repository.GetSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("get subscriptions: %w", err)
+	}
+
+	deliveries := make([]*entity.WebhookDelivery, 0)
+	importEvent, err := NewImportCompletedEvent(rirName, serial, changes)
+	if err != nil {
+		return err
+	}
+	changeFilters := make([]*Filter, 0)
+	for _, subscription := range subscriptions {
+		filter := NewFilter(subscription)
+		if !filter.MatchesRir(rirName) {
+			continue
+		}
+		if filter.MatchesEvent(ImportCompletedEvent) {
+			delivery, err := importEvent.NewDelivery(subscription.Id)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, delivery)
+		}
+		if filter.MatchesEvent(RangesChangedEvent) {
+			changeFilters = append(changeFilters, filter)
+		}
+	}
+
+	if len(changeFilters) != 0 && !changes.Baseline && changes.Added+changes.Removed+changes.Changed != 0 {
+		changeDeliveries, err := p.newChangeDeliveries(ctx, rirName, serial, changes.SnapshotId, changeFilters)
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, changeDeliveries...)
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return p.repository.Enqueue(deliveries, ctx)
+}
+
+func (p *Notifier) newChangeDeliveries(ctx context.Context, rirName, serial string, snapshotId int64, filters []*Filter) ([]*entity.WebhookDelivery, error) {
+	matched := make([][]*entity.RangeChange, len(filters))
+	query := &entity.ChangeQuery{
+		RirName:    rirName,
+		SnapshotId: snapshotId,
+		Limit:      changesPageSize,
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		page, err := p.changes.GetChanges(query)
+		if err != nil {
+			return nil, fmt.Errorf("get changes: %w", err)
+		}
+		for _, change := range page {
+			for i, filter := range filters {
+				if filter.MatchesChange(change) {
+					matched[i] = append(matched[i], change)
+				}
+			}
+		}
+		if len(page) < query.Limit {
+			break
+		}
+		query.After = page[len(page)-1].Id
+	}
+
+	deliveries := make([]*entity.WebhookDelivery, 0)
+	for i, filter := range filters {
+		for changes := range slices.Chunk(matched[i], p.changesPerEvent) {
+			event, err := NewRangesChangedEvent(rirName, serial, snapshotId, changes)
+			if err != nil {
+				return nil, err
+			}
+			delivery, err := event.NewDelivery(filter.Subscription.Id)
+			if err != nil {
+				return nil, err
+			}
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func NewNotifier(repository dao.WebhookRepository, changes dao.ChangeRepository, config *WebhookConfig) *Notifier {
+	return &Notifier{
+		repository:      repository,
+		changes:         changes,
+		changesPerEvent: config.ChangesPerEvent,
+	}
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Ipinfo-Signature"
+	EventHeader     = "X-Ipinfo-Event"
+	DeliveryHeader  = "X-Ipinfo-Delivery"
+)
+
+var ErrSignature = errors.New("invalid webhook signature")
+
+// HMAC-SHA256 of "<unix timestamp>.<body>" in form "t=<unix timestamp>,v1=<hex>",
+// timestamp is signed too, so receiver can reject replayed old deliveries
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), newSignature(secret, timestamp.Unix(), body))
+}
+
+// Zero tolerance skips check of timestamp
+func VerifySignature(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp int64
+	var signatures []string
+	for _, item := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+		switch key {
+		case "t":
+			var err error
+			if timestamp, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return fmt.Errorf("%w: timestamp: %w", ErrSignature, err)
+			}
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return fmt.Errorf("%w: malformed header", ErrSignature)
+	}
+	if tolerance > 0 {
+		if age := now.Sub(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+			return fmt.Errorf("%w: timestamp is out of tolerance: %s", ErrSignature, age)
+		}
+	}
+
+	expected := newSignature(secret, timestamp, body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: signature mismatch", ErrSignature)
+}
+
+func newSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
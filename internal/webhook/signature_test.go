@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignatureRoundTrip(t *testing.T) {
+	secret := "0123456789abcdef"
+	body := []byte(`{"id":"1","type":"ping"}`)
+	now := time.Unix(1700000000, 0)
+	header := Sign(secret, now, body)
+	if !strings.HasPrefix(header, "t=1700000000,v1=") {
+		t.Fatalf("Sign = %s, want t=1700000000,v1=<hex>", header)
+	}
+
+	if err := VerifySignature(secret, header, body, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Fatalf("VerifySignature: %v", err)
+	}
+	// Old secret is kept next to new one while receiver rotates it
+	rotated := header + ",v1=" + newSignature("old secret", now.Unix(), body)
+	if err := VerifySignature(secret, rotated, body, now, 5*time.Minute); err != nil {
+		t.Fatalf("VerifySignature of rotated: %v", err)
+	}
+}
+
+func TestSignatureRejected(t *testing.T) {
+	secret := "0123456789abcdef"
+	body := []byte(`{"id":"1","type":"ping"}`)
+	now := time.Unix(1700000000, 0)
+	header := Sign(secret, now, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+	}{
+		{"tampered body", secret, header, []byte(`{"id":"2","type":"ping"}`), now},
+		{"wrong secret", "fedcba9876543210", header, body, now},
+		{"tampered timestamp", secret, strings.Replace(header, "t=1700000000", "t=1700000001", 1), body, now},
+		{"old timestamp", secret, header, body, now.Add(6 * time.Minute)},
+		{"future timestamp", secret, header, body, now.Add(-6 * time.Minute)},
+		{"no signature", secret, "t=1700000000", body, now},
+		{"no timestamp", secret, strings.TrimPrefix(header, "t=1700000000,"), body, now},
+		{"bad timestamp", secret, strings.Replace(header, "t=1700000000", "t=now", 1), body, now},
+		{"empty", secret, "", body, now},
+	}
+	for _, test := range tests {
+		err := VerifySignature(test.secret, test.header, test.body, test.now, 5*time.Minute)
+		if !errors.Is(err, ErrSignature) {
+			t.Errorf("%s: VerifySignature = %v, want ErrSignature", test.name, err)
+		}
+	}
+}
+
+func TestSignatureZeroTolerance(t *testing.T) {
+	body := []byte("{}")
+	header := Sign("0123456789abcdef", time.Unix(1700000000, 0), body)
+	if err := VerifySignature("0123456789abcdef", header, body, time.Unix(1800000000, 0), 0); err != nil {
+		t.Fatalf("VerifySignature without tolerance: %v", err)
+	}
+}
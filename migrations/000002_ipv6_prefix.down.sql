@@ -0,0 +1,52 @@
+DROP MATERIALIZED VIEW IF EXISTS ip_ranges;
+
+ALTER TABLE apnic
+    DROP COLUMN prefix_length,
+    ALTER COLUMN quantity TYPE INT USING LEAST(quantity, 2147483647)::INT;
+
+ALTER TABLE arin
+    DROP COLUMN prefix_length,
+    ALTER COLUMN quantity TYPE INT USING LEAST(quantity, 2147483647)::INT;
+
+ALTER TABLE afrinic
+    DROP COLUMN prefix_length,
+    ALTER COLUMN quantity TYPE INT USING LEAST(quantity, 2147483647)::INT;
+
+ALTER TABLE lacnic
+    DROP COLUMN prefix_length,
+    ALTER COLUMN quantity TYPE INT USING LEAST(quantity, 2147483647)::INT;
+
+ALTER TABLE ripencc
+    DROP COLUMN prefix_length,
+    ALTER COLUMN quantity TYPE INT USING LEAST(quantity, 2147483647)::INT;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS ip_ranges AS
+    SELECT 'apnic_' || apnic.id as id, rirs.name as rir_name, apnic.country_code, ip_versions.name as ip_version_name, apnic.start_ip, apnic.end_ip, apnic.quantity, ip_range_statuses.name as status_name, apnic.status_changed_at
+    FROM apnic
+        JOIN rirs ON rirs.id = 1
+        JOIN ip_versions ON ip_versions.id = apnic.ip_version_id
+        JOIN ip_range_statuses ON apnic.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'arin_' || arin.id as id, rirs.name as rir_name, arin.country_code, ip_versions.name as ip_version_name, arin.start_ip, arin.end_ip, arin.quantity, ip_range_statuses.name as status_name, arin.status_changed_at
+    FROM arin
+        JOIN rirs ON rirs.id = 2
+        JOIN ip_versions ON ip_versions.id = arin.ip_version_id
+        JOIN ip_range_statuses ON arin.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'afrinic_' || afrinic.id as id, rirs.name as rir_name, afrinic.country_code, ip_versions.name as ip_version_name, afrinic.start_ip, afrinic.end_ip, afrinic.quantity, ip_range_statuses.name as status_name, afrinic.status_changed_at
+    FROM afrinic
+        JOIN rirs ON rirs.id = 3
+        JOIN ip_versions ON ip_versions.id = afrinic.ip_version_id
+        JOIN ip_range_statuses ON afrinic.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'lacnic_' || lacnic.id as id, rirs.name as rir_name, lacnic.country_code, ip_versions.name as ip_version_name, lacnic.start_ip, lacnic.end_ip, lacnic.quantity, ip_range_statuses.name as status_name, lacnic.status_changed_at
+    FROM lacnic
+        JOIN rirs ON rirs.id = 4
+        JOIN ip_versions ON ip_versions.id = lacnic.ip_version_id
+        JOIN ip_range_statuses ON lacnic.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'ripencc_' || ripencc.id as id, rirs.name as rir_name, ripencc.country_code, ip_versions.name as ip_version_name, ripencc.start_ip, ripencc.end_ip, ripencc.quantity, ip_range_statuses.name as status_name, ripencc.status_changed_at
+    FROM ripencc
+        JOIN rirs ON rirs.id = 5
+        JOIN ip_versions ON ip_versions.id = ripencc.ip_version_id
+        JOIN ip_range_statuses ON ripencc.status_id = ip_range_statuses.id;
@@ -0,0 +1,6 @@
+DROP MATERIALIZED VIEW IF EXISTS asn_ranges;
+DROP TABLE IF EXISTS apnic_asn;
+DROP TABLE IF EXISTS arin_asn;
+DROP TABLE IF EXISTS afrinic_asn;
+DROP TABLE IF EXISTS lacnic_asn;
+DROP TABLE IF EXISTS ripencc_asn;
@@ -0,0 +1,100 @@
+CREATE TABLE apnic_asn (
+    id SERIAL PRIMARY KEY,
+    country_code CHAR(2),
+    start_asn BIGINT NOT NULL,
+    end_asn BIGINT NOT NULL,
+    quantity BIGINT NOT NULL,
+    status_id INT NOT NULL REFERENCES ip_range_statuses(id) ON DELETE RESTRICT,
+    status_changed_at DATE,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(start_asn, end_asn)
+);
+CREATE INDEX idx_apnic_asn_start_asn ON apnic_asn (start_asn);
+CREATE INDEX idx_apnic_asn_end_asn ON apnic_asn (end_asn);
+CREATE INDEX idx_apnic_asn_status_id ON apnic_asn (status_id);
+
+CREATE TABLE arin_asn (
+    id SERIAL PRIMARY KEY,
+    country_code CHAR(2),
+    start_asn BIGINT NOT NULL,
+    end_asn BIGINT NOT NULL,
+    quantity BIGINT NOT NULL,
+    status_id INT NOT NULL REFERENCES ip_range_statuses(id) ON DELETE RESTRICT,
+    status_changed_at DATE,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(start_asn, end_asn)
+);
+CREATE INDEX idx_arin_asn_start_asn ON arin_asn (start_asn);
+CREATE INDEX idx_arin_asn_end_asn ON arin_asn (end_asn);
+CREATE INDEX idx_arin_asn_status_id ON arin_asn (status_id);
+
+CREATE TABLE afrinic_asn (
+    id SERIAL PRIMARY KEY,
+    country_code CHAR(2),
+    start_asn BIGINT NOT NULL,
+    end_asn BIGINT NOT NULL,
+    quantity BIGINT NOT NULL,
+    status_id INT NOT NULL REFERENCES ip_range_statuses(id) ON DELETE RESTRICT,
+    status_changed_at DATE,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(start_asn, end_asn)
+);
+CREATE INDEX idx_afrinic_asn_start_asn ON afrinic_asn (start_asn);
+CREATE INDEX idx_afrinic_asn_end_asn ON afrinic_asn (end_asn);
+CREATE INDEX idx_afrinic_asn_status_id ON afrinic_asn (status_id);
+
+CREATE TABLE lacnic_asn (
+    id SERIAL PRIMARY KEY,
+    country_code CHAR(2),
+    start_asn BIGINT NOT NULL,
+    end_asn BIGINT NOT NULL,
+    quantity BIGINT NOT NULL,
+    status_id INT NOT NULL REFERENCES ip_range_statuses(id) ON DELETE RESTRICT,
+    status_changed_at DATE,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(start_asn, end_asn)
+);
+CREATE INDEX idx_lacnic_asn_start_asn ON lacnic_asn (start_asn);
+CREATE INDEX idx_lacnic_asn_end_asn ON lacnic_asn (end_asn);
+CREATE INDEX idx_lacnic_asn_status_id ON lacnic_asn (status_id);
+
+CREATE TABLE ripencc_asn (
+    id SERIAL PRIMARY KEY,
+    country_code CHAR(2),
+    start_asn BIGINT NOT NULL,
+    end_asn BIGINT NOT NULL,
+    quantity BIGINT NOT NULL,
+    status_id INT NOT NULL REFERENCES ip_range_statuses(id) ON DELETE RESTRICT,
+    status_changed_at DATE,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(start_asn, end_asn)
+);
+CREATE INDEX idx_ripencc_asn_start_asn ON ripencc_asn (start_asn);
+CREATE INDEX idx_ripencc_asn_end_asn ON ripencc_asn (end_asn);
+CREATE INDEX idx_ripencc_asn_status_id ON ripencc_asn (status_id);
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS asn_ranges AS
+    SELECT 'apnic_asn_' || apnic_asn.id as id, rirs.name as rir_name, apnic_asn.country_code, apnic_asn.start_asn, apnic_asn.end_asn, apnic_asn.quantity, ip_range_statuses.name as status_name, apnic_asn.status_changed_at
+    FROM apnic_asn
+        JOIN rirs ON rirs.id = 1
+        JOIN ip_range_statuses ON apnic_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'arin_asn_' || arin_asn.id as id, rirs.name as rir_name, arin_asn.country_code, arin_asn.start_asn, arin_asn.end_asn, arin_asn.quantity, ip_range_statuses.name as status_name, arin_asn.status_changed_at
+    FROM arin_asn
+        JOIN rirs ON rirs.id = 2
+        JOIN ip_range_statuses ON arin_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'afrinic_asn_' || afrinic_asn.id as id, rirs.name as rir_name, afrinic_asn.country_code, afrinic_asn.start_asn, afrinic_asn.end_asn, afrinic_asn.quantity, ip_range_statuses.name as status_name, afrinic_asn.status_changed_at
+    FROM afrinic_asn
+        JOIN rirs ON rirs.id = 3
+        JOIN ip_range_statuses ON afrinic_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'lacnic_asn_' || lacnic_asn.id as id, rirs.name as rir_name, lacnic_asn.country_code, lacnic_asn.start_asn, lacnic_asn.end_asn, lacnic_asn.quantity, ip_range_statuses.name as status_name, lacnic_asn.status_changed_at
+    FROM lacnic_asn
+        JOIN rirs ON rirs.id = 4
+        JOIN ip_range_statuses ON lacnic_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'ripencc_asn_' || ripencc_asn.id as id, rirs.name as rir_name, ripencc_asn.country_code, ripencc_asn.start_asn, ripencc_asn.end_asn, ripencc_asn.quantity, ip_range_statuses.name as status_name, ripencc_asn.status_changed_at
+    FROM ripencc_asn
+        JOIN rirs ON rirs.id = 5
+        JOIN ip_range_statuses ON ripencc_asn.status_id = ip_range_statuses.id;
@@ -0,0 +1,70 @@
+DROP MATERIALIZED VIEW IF EXISTS ip_ranges;
+DROP MATERIALIZED VIEW IF EXISTS asn_ranges;
+
+ALTER TABLE apnic DROP COLUMN opaque_id;
+ALTER TABLE apnic_asn DROP COLUMN opaque_id;
+ALTER TABLE arin DROP COLUMN opaque_id;
+ALTER TABLE arin_asn DROP COLUMN opaque_id;
+ALTER TABLE afrinic DROP COLUMN opaque_id;
+ALTER TABLE afrinic_asn DROP COLUMN opaque_id;
+ALTER TABLE lacnic DROP COLUMN opaque_id;
+ALTER TABLE lacnic_asn DROP COLUMN opaque_id;
+ALTER TABLE ripencc DROP COLUMN opaque_id;
+ALTER TABLE ripencc_asn DROP COLUMN opaque_id;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS ip_ranges AS
+    SELECT 'apnic_' || apnic.id as id, rirs.name as rir_name, apnic.country_code, ip_versions.name as ip_version_name, apnic.start_ip, apnic.end_ip, apnic.quantity, apnic.prefix_length, ip_range_statuses.name as status_name, apnic.status_changed_at
+    FROM apnic
+        JOIN rirs ON rirs.id = 1
+        JOIN ip_versions ON ip_versions.id = apnic.ip_version_id
+        JOIN ip_range_statuses ON apnic.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'arin_' || arin.id as id, rirs.name as rir_name, arin.country_code, ip_versions.name as ip_version_name, arin.start_ip, arin.end_ip, arin.quantity, arin.prefix_length, ip_range_statuses.name as status_name, arin.status_changed_at
+    FROM arin
+        JOIN rirs ON rirs.id = 2
+        JOIN ip_versions ON ip_versions.id = arin.ip_version_id
+        JOIN ip_range_statuses ON arin.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'afrinic_' || afrinic.id as id, rirs.name as rir_name, afrinic.country_code, ip_versions.name as ip_version_name, afrinic.start_ip, afrinic.end_ip, afrinic.quantity, afrinic.prefix_length, ip_range_statuses.name as status_name, afrinic.status_changed_at
+    FROM afrinic
+        JOIN rirs ON rirs.id = 3
+        JOIN ip_versions ON ip_versions.id = afrinic.ip_version_id
+        JOIN ip_range_statuses ON afrinic.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'lacnic_' || lacnic.id as id, rirs.name as rir_name, lacnic.country_code, ip_versions.name as ip_version_name, lacnic.start_ip, lacnic.end_ip, lacnic.quantity, lacnic.prefix_length, ip_range_statuses.name as status_name, lacnic.status_changed_at
+    FROM lacnic
+        JOIN rirs ON rirs.id = 4
+        JOIN ip_versions ON ip_versions.id = lacnic.ip_version_id
+        JOIN ip_range_statuses ON lacnic.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'ripencc_' || ripencc.id as id, rirs.name as rir_name, ripencc.country_code, ip_versions.name as ip_version_name, ripencc.start_ip, ripencc.end_ip, ripencc.quantity, ripencc.prefix_length, ip_range_statuses.name as status_name, ripencc.status_changed_at
+    FROM ripencc
+        JOIN rirs ON rirs.id = 5
+        JOIN ip_versions ON ip_versions.id = ripencc.ip_version_id
+        JOIN ip_range_statuses ON ripencc.status_id = ip_range_statuses.id;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS asn_ranges AS
+    SELECT 'apnic_asn_' || apnic_asn.id as id, rirs.name as rir_name, apnic_asn.country_code, apnic_asn.start_asn, apnic_asn.end_asn, apnic_asn.quantity, ip_range_statuses.name as status_name, apnic_asn.status_changed_at
+    FROM apnic_asn
+        JOIN rirs ON rirs.id = 1
+        JOIN ip_range_statuses ON apnic_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'arin_asn_' || arin_asn.id as id, rirs.name as rir_name, arin_asn.country_code, arin_asn.start_asn, arin_asn.end_asn, arin_asn.quantity, ip_range_statuses.name as status_name, arin_asn.status_changed_at
+    FROM arin_asn
+        JOIN rirs ON rirs.id = 2
+        JOIN ip_range_statuses ON arin_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'afrinic_asn_' || afrinic_asn.id as id, rirs.name as rir_name, afrinic_asn.country_code, afrinic_asn.start_asn, afrinic_asn.end_asn, afrinic_asn.quantity, ip_range_statuses.name as status_name, afrinic_asn.status_changed_at
+    FROM afrinic_asn
+        JOIN rirs ON rirs.id = 3
+        JOIN ip_range_statuses ON afrinic_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'lacnic_asn_' || lacnic_asn.id as id, rirs.name as rir_name, lacnic_asn.country_code, lacnic_asn.start_asn, lacnic_asn.end_asn, lacnic_asn.quantity, ip_range_statuses.name as status_name, lacnic_asn.status_changed_at
+    FROM lacnic_asn
+        JOIN rirs ON rirs.id = 4
+        JOIN ip_range_statuses ON lacnic_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'ripencc_asn_' || ripencc_asn.id as id, rirs.name as rir_name, ripencc_asn.country_code, ripencc_asn.start_asn, ripencc_asn.end_asn, ripencc_asn.quantity, ip_range_statuses.name as status_name, ripencc_asn.status_changed_at
+    FROM ripencc_asn
+        JOIN rirs ON rirs.id = 5
+        JOIN ip_range_statuses ON ripencc_asn.status_id = ip_range_statuses.id;
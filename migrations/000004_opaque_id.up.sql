@@ -0,0 +1,83 @@
+DROP MATERIALIZED VIEW IF EXISTS ip_ranges;
+DROP MATERIALIZED VIEW IF EXISTS asn_ranges;
+
+ALTER TABLE apnic ADD COLUMN opaque_id TEXT;
+CREATE INDEX idx_apnic_opaque_id ON apnic (opaque_id);
+ALTER TABLE apnic_asn ADD COLUMN opaque_id TEXT;
+CREATE INDEX idx_apnic_asn_opaque_id ON apnic_asn (opaque_id);
+ALTER TABLE arin ADD COLUMN opaque_id TEXT;
+CREATE INDEX idx_arin_opaque_id ON arin (opaque_id);
+ALTER TABLE arin_asn ADD COLUMN opaque_id TEXT;
+CREATE INDEX idx_arin_asn_opaque_id ON arin_asn (opaque_id);
+ALTER TABLE afrinic ADD COLUMN opaque_id TEXT;
+CREATE INDEX idx_afrinic_opaque_id ON afrinic (opaque_id);
+ALTER TABLE afrinic_asn ADD COLUMN opaque_id TEXT;
+CREATE INDEX idx_afrinic_asn_opaque_id ON afrinic_asn (opaque_id);
+ALTER TABLE lacnic ADD COLUMN opaque_id TEXT;
+CREATE INDEX idx_lacnic_opaque_id ON lacnic (opaque_id);
+ALTER TABLE lacnic_asn ADD COLUMN opaque_id TEXT;
+CREATE INDEX idx_lacnic_asn_opaque_id ON lacnic_asn (opaque_id);
+ALTER TABLE ripencc ADD COLUMN opaque_id TEXT;
+CREATE INDEX idx_ripencc_opaque_id ON ripencc (opaque_id);
+ALTER TABLE ripencc_asn ADD COLUMN opaque_id TEXT;
+CREATE INDEX idx_ripencc_asn_opaque_id ON ripencc_asn (opaque_id);
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS ip_ranges AS
+    SELECT 'apnic_' || apnic.id as id, rirs.name as rir_name, apnic.country_code, ip_versions.name as ip_version_name, apnic.start_ip, apnic.end_ip, apnic.quantity, apnic.prefix_length, ip_range_statuses.name as status_name, apnic.status_changed_at, apnic.opaque_id
+    FROM apnic
+        JOIN rirs ON rirs.id = 1
+        JOIN ip_versions ON ip_versions.id = apnic.ip_version_id
+        JOIN ip_range_statuses ON apnic.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'arin_' || arin.id as id, rirs.name as rir_name, arin.country_code, ip_versions.name as ip_version_name, arin.start_ip, arin.end_ip, arin.quantity, arin.prefix_length, ip_range_statuses.name as status_name, arin.status_changed_at, arin.opaque_id
+    FROM arin
+        JOIN rirs ON rirs.id = 2
+        JOIN ip_versions ON ip_versions.id = arin.ip_version_id
+        JOIN ip_range_statuses ON arin.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'afrinic_' || afrinic.id as id, rirs.name as rir_name, afrinic.country_code, ip_versions.name as ip_version_name, afrinic.start_ip, afrinic.end_ip, afrinic.quantity, afrinic.prefix_length, ip_range_statuses.name as status_name, afrinic.status_changed_at, afrinic.opaque_id
+    FROM afrinic
+        JOIN rirs ON rirs.id = 3
+        JOIN ip_versions ON ip_versions.id = afrinic.ip_version_id
+        JOIN ip_range_statuses ON afrinic.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'lacnic_' || lacnic.id as id, rirs.name as rir_name, lacnic.country_code, ip_versions.name as ip_version_name, lacnic.start_ip, lacnic.end_ip, lacnic.quantity, lacnic.prefix_length, ip_range_statuses.name as status_name, lacnic.status_changed_at, lacnic.opaque_id
+    FROM lacnic
+        JOIN rirs ON rirs.id = 4
+        JOIN ip_versions ON ip_versions.id = lacnic.ip_version_id
+        JOIN ip_range_statuses ON lacnic.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'ripencc_' || ripencc.id as id, rirs.name as rir_name, ripencc.country_code, ip_versions.name as ip_version_name, ripencc.start_ip, ripencc.end_ip, ripencc.quantity, ripencc.prefix_length, ip_range_statuses.name as status_name, ripencc.status_changed_at, ripencc.opaque_id
+    FROM ripencc
+        JOIN rirs ON rirs.id = 5
+        JOIN ip_versions ON ip_versions.id = ripencc.ip_version_id
+        JOIN ip_range_statuses ON ripencc.status_id = ip_range_statuses.id;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS asn_ranges AS
+    SELECT 'apnic_asn_' || apnic_asn.id as id, rirs.name as rir_name, apnic_asn.country_code, apnic_asn.start_asn, apnic_asn.end_asn, apnic_asn.quantity, ip_range_statuses.name as status_name, apnic_asn.status_changed_at, apnic_asn.opaque_id
+    FROM apnic_asn
+        JOIN rirs ON rirs.id = 1
+        JOIN ip_range_statuses ON apnic_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'arin_asn_' || arin_asn.id as id, rirs.name as rir_name, arin_asn.country_code, arin_asn.start_asn, arin_asn.end_asn, arin_asn.quantity, ip_range_statuses.name as status_name, arin_asn.status_changed_at, arin_asn.opaque_id
+    FROM arin_asn
+        JOIN rirs ON rirs.id = 2
+        JOIN ip_range_statuses ON arin_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'afrinic_asn_' || afrinic_asn.id as id, rirs.name as rir_name, afrinic_asn.country_code, afrinic_asn.start_asn, afrinic_asn.end_asn, afrinic_asn.quantity, ip_range_statuses.name as status_name, afrinic_asn.status_changed_at, afrinic_asn.opaque_id
+    FROM afrinic_asn
+        JOIN rirs ON rirs.id = 3
+        JOIN ip_range_statuses ON afrinic_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'lacnic_asn_' || lacnic_asn.id as id, rirs.name as rir_name, lacnic_asn.country_code, lacnic_asn.start_asn, lacnic_asn.end_asn, lacnic_asn.quantity, ip_range_statuses.name as status_name, lacnic_asn.status_changed_at, lacnic_asn.opaque_id
+    FROM lacnic_asn
+        JOIN rirs ON rirs.id = 4
+        JOIN ip_range_statuses ON lacnic_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'ripencc_asn_' || ripencc_asn.id as id, rirs.name as rir_name, ripencc_asn.country_code, ripencc_asn.start_asn, ripencc_asn.end_asn, ripencc_asn.quantity, ip_range_statuses.name as status_name, ripencc_asn.status_changed_at, ripencc_asn.opaque_id
+    FROM ripencc_asn
+        JOIN rirs ON rirs.id = 5
+        JOIN ip_range_statuses ON ripencc_asn.status_id = ip_range_statuses.id;
+
+CREATE INDEX idx_ip_ranges_opaque_id ON ip_ranges (opaque_id);
+CREATE INDEX idx_asn_ranges_opaque_id ON asn_ranges (opaque_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_ip_ranges_id;
+DROP INDEX IF EXISTS idx_asn_ranges_id;
@@ -0,0 +1,3 @@
+-- REFRESH MATERIALIZED VIEW CONCURRENTLY requires unique index without WHERE clause
+CREATE UNIQUE INDEX idx_ip_ranges_id ON ip_ranges (id);
+CREATE UNIQUE INDEX idx_asn_ranges_id ON asn_ranges (id);
@@ -0,0 +1,5 @@
+DROP VIEW IF EXISTS ip_ranges_history;
+DROP VIEW IF EXISTS asn_ranges_history;
+
+DROP TABLE IF EXISTS ip_range_history;
+DROP TABLE IF EXISTS asn_range_history;
@@ -0,0 +1,76 @@
+CREATE TABLE ip_range_history (
+    id BIGSERIAL PRIMARY KEY,
+    rir_id INT NOT NULL REFERENCES rirs(id) ON DELETE RESTRICT,
+    country_code CHAR(2),
+    ip_version_id INT NOT NULL REFERENCES ip_versions(id) ON DELETE RESTRICT,
+    start_ip INET NOT NULL,
+    end_ip INET NOT NULL,
+    quantity NUMERIC(39, 0) NOT NULL,
+    prefix_length SMALLINT CHECK (prefix_length BETWEEN 0 AND 128),
+    status_id INT NOT NULL REFERENCES ip_range_statuses(id) ON DELETE RESTRICT,
+    status_changed_at DATE,
+    opaque_id TEXT,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_to TIMESTAMPTZ,
+    CHECK (valid_to IS NULL OR valid_to > valid_from)
+);
+CREATE INDEX idx_ip_range_history_start_ip ON ip_range_history (start_ip);
+CREATE INDEX idx_ip_range_history_end_ip ON ip_range_history (end_ip);
+-- Lookup at time walks down from address and stops at the first version valid at that time
+CREATE INDEX idx_ip_range_history_lookup ON ip_range_history (start_ip DESC, valid_from DESC) INCLUDE (end_ip, valid_to);
+-- Current records of rir, used to diff new snapshot
+CREATE INDEX idx_ip_range_history_current ON ip_range_history (rir_id, start_ip, end_ip) WHERE valid_to IS NULL;
+
+CREATE TABLE asn_range_history (
+    id BIGSERIAL PRIMARY KEY,
+    rir_id INT NOT NULL REFERENCES rirs(id) ON DELETE RESTRICT,
+    country_code CHAR(2),
+    start_asn BIGINT NOT NULL,
+    end_asn BIGINT NOT NULL,
+    quantity BIGINT NOT NULL,
+    status_id INT NOT NULL REFERENCES ip_range_statuses(id) ON DELETE RESTRICT,
+    status_changed_at DATE,
+    opaque_id TEXT,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_to TIMESTAMPTZ,
+    CHECK (valid_to IS NULL OR valid_to > valid_from)
+);
+CREATE INDEX idx_asn_range_history_start_asn ON asn_range_history (start_asn);
+CREATE INDEX idx_asn_range_history_end_asn ON asn_range_history (end_asn);
+CREATE INDEX idx_asn_range_history_lookup ON asn_range_history (start_asn DESC, valid_from DESC) INCLUDE (end_asn, valid_to);
+CREATE INDEX idx_asn_range_history_current ON asn_range_history (rir_id, start_asn, end_asn) WHERE valid_to IS NULL;
+
+-- Current data is the first version of history
+INSERT INTO ip_range_history (rir_id, country_code, ip_version_id, start_ip, end_ip, quantity, prefix_length, status_id, status_changed_at, opaque_id, valid_from)
+    SELECT 1, country_code, ip_version_id, start_ip, end_ip, quantity, prefix_length, status_id, status_changed_at, opaque_id, NOW() FROM apnic;
+INSERT INTO asn_range_history (rir_id, country_code, start_asn, end_asn, quantity, status_id, status_changed_at, opaque_id, valid_from)
+    SELECT 1, country_code, start_asn, end_asn, quantity, status_id, status_changed_at, opaque_id, NOW() FROM apnic_asn;
+INSERT INTO ip_range_history (rir_id, country_code, ip_version_id, start_ip, end_ip, quantity, prefix_length, status_id, status_changed_at, opaque_id, valid_from)
+    SELECT 2, country_code, ip_version_id, start_ip, end_ip, quantity, prefix_length, status_id, status_changed_at, opaque_id, NOW() FROM arin;
+INSERT INTO asn_range_history (rir_id, country_code, start_asn, end_asn, quantity, status_id, status_changed_at, opaque_id, valid_from)
+    SELECT 2, country_code, start_asn, end_asn, quantity, status_id, status_changed_at, opaque_id, NOW() FROM arin_asn;
+INSERT INTO ip_range_history (rir_id, country_code, ip_version_id, start_ip, end_ip, quantity, prefix_length, status_id, status_changed_at, opaque_id, valid_from)
+    SELECT 3, country_code, ip_version_id, start_ip, end_ip, quantity, prefix_length, status_id, status_changed_at, opaque_id, NOW() FROM afrinic;
+INSERT INTO asn_range_history (rir_id, country_code, start_asn, end_asn, quantity, status_id, status_changed_at, opaque_id, valid_from)
+    SELECT 3, country_code, start_asn, end_asn, quantity, status_id, status_changed_at, opaque_id, NOW() FROM afrinic_asn;
+INSERT INTO ip_range_history (rir_id, country_code, ip_version_id, start_ip, end_ip, quantity, prefix_length, status_id, status_changed_at, opaque_id, valid_from)
+    SELECT 4, country_code, ip_version_id, start_ip, end_ip, quantity, prefix_length, status_id, status_changed_at, opaque_id, NOW() FROM lacnic;
+INSERT INTO asn_range_history (rir_id, country_code, start_asn, end_asn, quantity, status_id, status_changed_at, opaque_id, valid_from)
+    SELECT 4, country_code, start_asn, end_asn, quantity, status_id, status_changed_at, opaque_id, NOW() FROM lacnic_asn;
+INSERT INTO ip_range_history (rir_id, country_code, ip_version_id, start_ip, end_ip, quantity, prefix_length, status_id, status_changed_at, opaque_id, valid_from)
+    SELECT 5, country_code, ip_version_id, start_ip, end_ip, quantity, prefix_length, status_id, status_changed_at, opaque_id, NOW() FROM ripencc;
+INSERT INTO asn_range_history (rir_id, country_code, start_asn, end_asn, quantity, status_id, status_changed_at, opaque_id, valid_from)
+    SELECT 5, country_code, start_asn, end_asn, quantity, status_id, status_changed_at, opaque_id, NOW() FROM ripencc_asn;
+
+CREATE VIEW ip_ranges_history AS
+    SELECT 'history_' || h.id as id, rirs.name as rir_name, h.country_code, ip_versions.name as ip_version_name, h.start_ip, h.end_ip, h.quantity, h.prefix_length, ip_range_statuses.name as status_name, h.status_changed_at, h.opaque_id, h.valid_from, h.valid_to
+    FROM ip_range_history h
+        JOIN rirs ON rirs.id = h.rir_id
+        JOIN ip_versions ON ip_versions.id = h.ip_version_id
+        JOIN ip_range_statuses ON h.status_id = ip_range_statuses.id;
+
+CREATE VIEW asn_ranges_history AS
+    SELECT 'asn_history_' || h.id as id, rirs.name as rir_name, h.country_code, h.start_asn, h.end_asn, h.quantity, ip_range_statuses.name as status_name, h.status_changed_at, h.opaque_id, h.valid_from, h.valid_to
+    FROM asn_range_history h
+        JOIN rirs ON rirs.id = h.rir_id
+        JOIN ip_range_statuses ON h.status_id = ip_range_statuses.id;
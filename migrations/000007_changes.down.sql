@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS range_changes;
+DROP TABLE IF EXISTS rir_snapshots;
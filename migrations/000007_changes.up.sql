@@ -0,0 +1,28 @@
+-- Every published upload of rir data, first upload of rir is a baseline without changes
+CREATE TABLE rir_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    rir_id INT NOT NULL REFERENCES rirs(id) ON DELETE RESTRICT,
+    serial TEXT,
+    published_at TIMESTAMPTZ NOT NULL,
+    baseline BOOLEAN NOT NULL DEFAULT FALSE,
+    added BIGINT NOT NULL DEFAULT 0,
+    removed BIGINT NOT NULL DEFAULT 0,
+    changed BIGINT NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_rir_snapshots_rir_serial ON rir_snapshots (rir_id, serial);
+CREATE INDEX idx_rir_snapshots_published_at ON rir_snapshots (published_at);
+
+-- Ranges added, removed or changed in country or status by snapshot
+CREATE TABLE range_changes (
+    id BIGSERIAL PRIMARY KEY,
+    snapshot_id BIGINT NOT NULL REFERENCES rir_snapshots(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('added', 'removed', 'changed')),
+    range_type TEXT NOT NULL CHECK (range_type IN ('ip', 'asn')),
+    range_start TEXT NOT NULL,
+    range_end TEXT NOT NULL,
+    old_country_code CHAR(2),
+    new_country_code CHAR(2),
+    old_status_id INT REFERENCES ip_range_statuses(id) ON DELETE RESTRICT,
+    new_status_id INT REFERENCES ip_range_statuses(id) ON DELETE RESTRICT
+);
+CREATE INDEX idx_range_changes_snapshot_id ON range_changes (snapshot_id);
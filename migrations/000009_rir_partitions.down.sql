@@ -0,0 +1,98 @@
+DROP MATERIALIZED VIEW IF EXISTS ip_ranges;
+DROP MATERIALIZED VIEW IF EXISTS asn_ranges;
+
+ALTER TABLE rir_ip_ranges DETACH PARTITION apnic;
+ALTER TABLE apnic DROP COLUMN rir_id;
+ALTER SEQUENCE apnic_id_seq OWNED BY apnic.id;
+ALTER TABLE rir_asn_ranges DETACH PARTITION apnic_asn;
+ALTER TABLE apnic_asn DROP COLUMN rir_id;
+ALTER SEQUENCE apnic_asn_id_seq OWNED BY apnic_asn.id;
+ALTER TABLE rir_ip_ranges DETACH PARTITION arin;
+ALTER TABLE arin DROP COLUMN rir_id;
+ALTER SEQUENCE arin_id_seq OWNED BY arin.id;
+ALTER TABLE rir_asn_ranges DETACH PARTITION arin_asn;
+ALTER TABLE arin_asn DROP COLUMN rir_id;
+ALTER SEQUENCE arin_asn_id_seq OWNED BY arin_asn.id;
+ALTER TABLE rir_ip_ranges DETACH PARTITION afrinic;
+ALTER TABLE afrinic DROP COLUMN rir_id;
+ALTER SEQUENCE afrinic_id_seq OWNED BY afrinic.id;
+ALTER TABLE rir_asn_ranges DETACH PARTITION afrinic_asn;
+ALTER TABLE afrinic_asn DROP COLUMN rir_id;
+ALTER SEQUENCE afrinic_asn_id_seq OWNED BY afrinic_asn.id;
+ALTER TABLE rir_ip_ranges DETACH PARTITION lacnic;
+ALTER TABLE lacnic DROP COLUMN rir_id;
+ALTER SEQUENCE lacnic_id_seq OWNED BY lacnic.id;
+ALTER TABLE rir_asn_ranges DETACH PARTITION lacnic_asn;
+ALTER TABLE lacnic_asn DROP COLUMN rir_id;
+ALTER SEQUENCE lacnic_asn_id_seq OWNED BY lacnic_asn.id;
+ALTER TABLE rir_ip_ranges DETACH PARTITION ripencc;
+ALTER TABLE ripencc DROP COLUMN rir_id;
+ALTER SEQUENCE ripencc_id_seq OWNED BY ripencc.id;
+ALTER TABLE rir_asn_ranges DETACH PARTITION ripencc_asn;
+ALTER TABLE ripencc_asn DROP COLUMN rir_id;
+ALTER SEQUENCE ripencc_asn_id_seq OWNED BY ripencc_asn.id;
+
+DROP TABLE IF EXISTS rir_ip_ranges;
+DROP TABLE IF EXISTS rir_asn_ranges;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS ip_ranges AS
+    SELECT 'apnic_' || apnic.id as id, rirs.name as rir_name, apnic.country_code, ip_versions.name as ip_version_name, apnic.start_ip, apnic.end_ip, apnic.quantity, apnic.prefix_length, ip_range_statuses.name as status_name, apnic.status_changed_at, apnic.opaque_id
+    FROM apnic
+        JOIN rirs ON rirs.id = 1
+        JOIN ip_versions ON ip_versions.id = apnic.ip_version_id
+        JOIN ip_range_statuses ON apnic.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'arin_' || arin.id as id, rirs.name as rir_name, arin.country_code, ip_versions.name as ip_version_name, arin.start_ip, arin.end_ip, arin.quantity, arin.prefix_length, ip_range_statuses.name as status_name, arin.status_changed_at, arin.opaque_id
+    FROM arin
+        JOIN rirs ON rirs.id = 2
+        JOIN ip_versions ON ip_versions.id = arin.ip_version_id
+        JOIN ip_range_statuses ON arin.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'afrinic_' || afrinic.id as id, rirs.name as rir_name, afrinic.country_code, ip_versions.name as ip_version_name, afrinic.start_ip, afrinic.end_ip, afrinic.quantity, afrinic.prefix_length, ip_range_statuses.name as status_name, afrinic.status_changed_at, afrinic.opaque_id
+    FROM afrinic
+        JOIN rirs ON rirs.id = 3
+        JOIN ip_versions ON ip_versions.id = afrinic.ip_version_id
+        JOIN ip_range_statuses ON afrinic.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'lacnic_' || lacnic.id as id, rirs.name as rir_name, lacnic.country_code, ip_versions.name as ip_version_name, lacnic.start_ip, lacnic.end_ip, lacnic.quantity, lacnic.prefix_length, ip_range_statuses.name as status_name, lacnic.status_changed_at, lacnic.opaque_id
+    FROM lacnic
+        JOIN rirs ON rirs.id = 4
+        JOIN ip_versions ON ip_versions.id = lacnic.ip_version_id
+        JOIN ip_range_statuses ON lacnic.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'ripencc_' || ripencc.id as id, rirs.name as rir_name, ripencc.country_code, ip_versions.name as ip_version_name, ripencc.start_ip, ripencc.end_ip, ripencc.quantity, ripencc.prefix_length, ip_range_statuses.name as status_name, ripencc.status_changed_at, ripencc.opaque_id
+    FROM ripencc
+        JOIN rirs ON rirs.id = 5
+        JOIN ip_versions ON ip_versions.id = ripencc.ip_version_id
+        JOIN ip_range_statuses ON ripencc.status_id = ip_range_statuses.id;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS asn_ranges AS
+    SELECT 'apnic_asn_' || apnic_asn.id as id, rirs.name as rir_name, apnic_asn.country_code, apnic_asn.start_asn, apnic_asn.end_asn, apnic_asn.quantity, ip_range_statuses.name as status_name, apnic_asn.status_changed_at, apnic_asn.opaque_id
+    FROM apnic_asn
+        JOIN rirs ON rirs.id = 1
+        JOIN ip_range_statuses ON apnic_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'arin_asn_' || arin_asn.id as id, rirs.name as rir_name, arin_asn.country_code, arin_asn.start_asn, arin_asn.end_asn, arin_asn.quantity, ip_range_statuses.name as status_name, arin_asn.status_changed_at, arin_asn.opaque_id
+    FROM arin_asn
+        JOIN rirs ON rirs.id = 2
+        JOIN ip_range_statuses ON arin_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'afrinic_asn_' || afrinic_asn.id as id, rirs.name as rir_name, afrinic_asn.country_code, afrinic_asn.start_asn, afrinic_asn.end_asn, afrinic_asn.quantity, ip_range_statuses.name as status_name, afrinic_asn.status_changed_at, afrinic_asn.opaque_id
+    FROM afrinic_asn
+        JOIN rirs ON rirs.id = 3
+        JOIN ip_range_statuses ON afrinic_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'lacnic_asn_' || lacnic_asn.id as id, rirs.name as rir_name, lacnic_asn.country_code, lacnic_asn.start_asn, lacnic_asn.end_asn, lacnic_asn.quantity, ip_range_statuses.name as status_name, lacnic_asn.status_changed_at, lacnic_asn.opaque_id
+    FROM lacnic_asn
+        JOIN rirs ON rirs.id = 4
+        JOIN ip_range_statuses ON lacnic_asn.status_id = ip_range_statuses.id
+UNION ALL
+    SELECT 'ripencc_asn_' || ripencc_asn.id as id, rirs.name as rir_name, ripencc_asn.country_code, ripencc_asn.start_asn, ripencc_asn.end_asn, ripencc_asn.quantity, ip_range_statuses.name as status_name, ripencc_asn.status_changed_at, ripencc_asn.opaque_id
+    FROM ripencc_asn
+        JOIN rirs ON rirs.id = 5
+        JOIN ip_range_statuses ON ripencc_asn.status_id = ip_range_statuses.id;
+
+CREATE INDEX idx_ip_ranges_opaque_id ON ip_ranges (opaque_id);
+CREATE INDEX idx_asn_ranges_opaque_id ON asn_ranges (opaque_id);
+CREATE UNIQUE INDEX idx_ip_ranges_id ON ip_ranges (id);
+CREATE UNIQUE INDEX idx_asn_ranges_id ON asn_ranges (id);
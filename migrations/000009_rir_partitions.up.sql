@@ -0,0 +1,82 @@
+-- Rir tables become partitions of one table per range type. Views read the partitioned table,
+-- so a table built aside is attached in place of the live one without rebuilding views
+DROP MATERIALIZED VIEW IF EXISTS ip_ranges;
+DROP MATERIALIZED VIEW IF EXISTS asn_ranges;
+
+CREATE TABLE rir_ip_ranges (
+    id INT NOT NULL,
+    rir_id INT NOT NULL REFERENCES rirs(id) ON DELETE RESTRICT,
+    country_code CHAR(2),
+    ip_version_id INT NOT NULL REFERENCES ip_versions(id) ON DELETE RESTRICT,
+    start_ip INET NOT NULL,
+    end_ip INET NOT NULL,
+    quantity NUMERIC(39, 0) NOT NULL,
+    prefix_length SMALLINT,
+    status_id INT NOT NULL REFERENCES ip_range_statuses(id) ON DELETE RESTRICT,
+    status_changed_at DATE,
+    opaque_id TEXT,
+    updated_at TIMESTAMP DEFAULT NOW()
+) PARTITION BY LIST (rir_id);
+
+CREATE TABLE rir_asn_ranges (
+    id INT NOT NULL,
+    rir_id INT NOT NULL REFERENCES rirs(id) ON DELETE RESTRICT,
+    country_code CHAR(2),
+    start_asn BIGINT NOT NULL,
+    end_asn BIGINT NOT NULL,
+    quantity BIGINT NOT NULL,
+    status_id INT NOT NULL REFERENCES ip_range_statuses(id) ON DELETE RESTRICT,
+    status_changed_at DATE,
+    opaque_id TEXT,
+    updated_at TIMESTAMP DEFAULT NOW()
+) PARTITION BY LIST (rir_id);
+
+-- Check of rir id lets attach skip the scan. Sequences outlive swapped tables, so ids of new ranges keep growing
+ALTER TABLE apnic ADD COLUMN rir_id INT NOT NULL DEFAULT 1 CHECK (rir_id = 1);
+ALTER SEQUENCE apnic_id_seq OWNED BY NONE;
+ALTER TABLE rir_ip_ranges ATTACH PARTITION apnic FOR VALUES IN (1);
+ALTER TABLE apnic_asn ADD COLUMN rir_id INT NOT NULL DEFAULT 1 CHECK (rir_id = 1);
+ALTER SEQUENCE apnic_asn_id_seq OWNED BY NONE;
+ALTER TABLE rir_asn_ranges ATTACH PARTITION apnic_asn FOR VALUES IN (1);
+ALTER TABLE arin ADD COLUMN rir_id INT NOT NULL DEFAULT 2 CHECK (rir_id = 2);
+ALTER SEQUENCE arin_id_seq OWNED BY NONE;
+ALTER TABLE rir_ip_ranges ATTACH PARTITION arin FOR VALUES IN (2);
+ALTER TABLE arin_asn ADD COLUMN rir_id INT NOT NULL DEFAULT 2 CHECK (rir_id = 2);
+ALTER SEQUENCE arin_asn_id_seq OWNED BY NONE;
+ALTER TABLE rir_asn_ranges ATTACH PARTITION arin_asn FOR VALUES IN (2);
+ALTER TABLE afrinic ADD COLUMN rir_id INT NOT NULL DEFAULT 3 CHECK (rir_id = 3);
+ALTER SEQUENCE afrinic_id_seq OWNED BY NONE;
+ALTER TABLE rir_ip_ranges ATTACH PARTITION afrinic FOR VALUES IN (3);
+ALTER TABLE afrinic_asn ADD COLUMN rir_id INT NOT NULL DEFAULT 3 CHECK (rir_id = 3);
+ALTER SEQUENCE afrinic_asn_id_seq OWNED BY NONE;
+ALTER TABLE rir_asn_ranges ATTACH PARTITION afrinic_asn FOR VALUES IN (3);
+ALTER TABLE lacnic ADD COLUMN rir_id INT NOT NULL DEFAULT 4 CHECK (rir_id = 4);
+ALTER SEQUENCE lacnic_id_seq OWNED BY NONE;
+ALTER TABLE rir_ip_ranges ATTACH PARTITION lacnic FOR VALUES IN (4);
+ALTER TABLE lacnic_asn ADD COLUMN rir_id INT NOT NULL DEFAULT 4 CHECK (rir_id = 4);
+ALTER SEQUENCE lacnic_asn_id_seq OWNED BY NONE;
+ALTER TABLE rir_asn_ranges ATTACH PARTITION lacnic_asn FOR VALUES IN (4);
+ALTER TABLE ripencc ADD COLUMN rir_id INT NOT NULL DEFAULT 5 CHECK (rir_id = 5);
+ALTER SEQUENCE ripencc_id_seq OWNED BY NONE;
+ALTER TABLE rir_ip_ranges ATTACH PARTITION ripencc FOR VALUES IN (5);
+ALTER TABLE ripencc_asn ADD COLUMN rir_id INT NOT NULL DEFAULT 5 CHECK (rir_id = 5);
+ALTER SEQUENCE ripencc_asn_id_seq OWNED BY NONE;
+ALTER TABLE rir_asn_ranges ATTACH PARTITION ripencc_asn FOR VALUES IN (5);
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS ip_ranges AS
+    SELECT rirs.name || '_' || r.id as id, rirs.name as rir_name, r.country_code, ip_versions.name as ip_version_name, r.start_ip, r.end_ip, r.quantity, r.prefix_length, ip_range_statuses.name as status_name, r.status_changed_at, r.opaque_id
+    FROM rir_ip_ranges r
+        JOIN rirs ON rirs.id = r.rir_id
+        JOIN ip_versions ON ip_versions.id = r.ip_version_id
+        JOIN ip_range_statuses ON r.status_id = ip_range_statuses.id;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS asn_ranges AS
+    SELECT rirs.name || '_asn_' || r.id as id, rirs.name as rir_name, r.country_code, r.start_asn, r.end_asn, r.quantity, ip_range_statuses.name as status_name, r.status_changed_at, r.opaque_id
+    FROM rir_asn_ranges r
+        JOIN rirs ON rirs.id = r.rir_id
+        JOIN ip_range_statuses ON r.status_id = ip_range_statuses.id;
+
+CREATE INDEX idx_ip_ranges_opaque_id ON ip_ranges (opaque_id);
+CREATE INDEX idx_asn_ranges_opaque_id ON asn_ranges (opaque_id);
+CREATE UNIQUE INDEX idx_ip_ranges_id ON ip_ranges (id);
+CREATE UNIQUE INDEX idx_asn_ranges_id ON asn_ranges (id);
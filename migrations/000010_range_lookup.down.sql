@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_ip_range_history_lookup;
+DROP INDEX IF EXISTS idx_asn_range_history_lookup;
+CREATE INDEX idx_ip_range_history_lookup ON ip_range_history (start_ip DESC, valid_from DESC) INCLUDE (end_ip, valid_to);
+CREATE INDEX idx_asn_range_history_lookup ON asn_range_history (start_asn DESC, valid_from DESC) INCLUDE (end_asn, valid_to);
+
+DROP INDEX IF EXISTS idx_ip_ranges_range;
+DROP INDEX IF EXISTS idx_asn_ranges_range;
+
+DROP TYPE IF EXISTS inetrange;
@@ -0,0 +1,12 @@
+-- Lookups find ranges containing address by GiST instead of walking btree down from address,
+-- the walk scans every range below address when it is not delegated
+CREATE TYPE inetrange AS RANGE (subtype = inet);
+
+CREATE INDEX idx_ip_ranges_range ON ip_ranges USING GIST (inetrange(start_ip, end_ip));
+CREATE INDEX idx_asn_ranges_range ON asn_ranges USING GIST (int8range(start_asn, end_asn));
+
+DROP INDEX IF EXISTS idx_ip_range_history_lookup;
+DROP INDEX IF EXISTS idx_asn_range_history_lookup;
+-- valid_to NULL is unbounded, so the current version contains any time after valid_from
+CREATE INDEX idx_ip_range_history_lookup ON ip_range_history USING GIST (inetrange(start_ip, end_ip), tstzrange(valid_from, valid_to));
+CREATE INDEX idx_asn_range_history_lookup ON asn_range_history USING GIST (int8range(start_asn, end_asn), tstzrange(valid_from, valid_to));